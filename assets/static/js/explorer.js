@@ -179,9 +179,44 @@ var _process = function(text, status, xhr, fullhost) {
     $('#response_code').text( xhr.status + ' ' + xhr.statusText );
     $('#response_headers').html( hljs.highlight( 'http', xhr.getAllResponseHeaders() ).value );
 
+    _show_contract( xhr );
+
     $('#exploreButton').removeAttr('disabled');
 }
 
+// --------------------------------------------------------------------------------------
+// Proxied requests and responses that depart from their documentation are reported by
+// DapperDox in the X-DapperDox-Contract header, as URL escaped JSON.
+
+var _show_contract = function( xhr ) {
+    var contract = $('#response_contract');
+    if( contract.length == 0 )
+    {
+        contract = $('<div id="response_contract" class="contractProblems"></div>').insertAfter('#response_code');
+    }
+    contract.empty().hide();
+
+    var header = xhr.getResponseHeader('X-DapperDox-Contract');
+    if( header == null ) {
+        return;
+    }
+
+    var problems;
+    try {
+        problems = JSON.parse( decodeURIComponent( header ) );
+    }
+    catch(err) {
+        return;
+    }
+
+    var list = $('<ul></ul>');
+    for( var i = 0; i < problems.length; i++ )
+    {
+        list.append( $('<li></li>').append( $('<code></code>').text( problems[i].location ), ' ' + problems[i].message ) );
+    }
+    contract.append( $('<p></p>').text( "Not as documented:" ), list ).show();
+}
+
 // --------------------------------------------------------------------------------------
 
 var _set_headers = function(request, headers ) {
@@ -192,6 +227,23 @@ var _set_headers = function(request, headers ) {
     }
 }
 
+// --------------------------------------------------------------------------------------
+// The CSRF token DapperDox gives readers in the csrf_token cookie. It is sent, as the
+// X-CSRF-Token header, only with requests to DapperDox itself, such as to proxied paths.
+
+var _set_csrf_header = function( request, url ) {
+
+    var a = document.createElement('a');
+    a.href = url;
+    if( a.protocol !== window.location.protocol || a.host !== window.location.host ) {
+        return;
+    }
+    var match = document.cookie.match( /(?:^|;\s*)csrf_token=([^;]*)/ );
+    if( match ) {
+        request.setRequestHeader( 'X-CSRF-Token', decodeURIComponent( match[1] ) );
+    }
+}
+
 // --------------------------------------------------------------------------------------
 
 var _get_header_text = function( headers ) {
@@ -433,6 +485,7 @@ apiExplorer.go = function( method, url ){
         error:    function( xhr,  status, text) { _process(xhr.responseText,  status, xhr, constructed_request.fullhost) },
         beforeSend: function( request ) {
             _set_headers( request, headers );
+            _set_csrf_header( request, constructed_request.fullUrl );
             $('#progress').stop(1,0).hide().delay(800).fadeIn();
             $('#response').stop(1,0).delay(10).hide();
         },
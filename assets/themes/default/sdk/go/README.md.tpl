@@ -0,0 +1,21 @@
+# [: .Title :] Go SDK
+
+A Go client of the [: .Title :] API, version [: .Version :].
+
+```go
+import "[: kebab .ID :]"
+
+client := [: template "package" . :].NewClient()
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+client.[: pascal .Name :] = "<[: .ParamName :]>"
+[:- else if eq .Type "oauth2" :]
+client.[: pascal .Name :]Token = "<access token>"
+[:- end :]
+[:- end :]
+```
+
+Each API is a service of the client:
+[: range .APIs :]
+* `client.[: pascal .Name :]`, [: .Name :]
+[:- end :]
@@ -0,0 +1,82 @@
+package [: template "package" . :]
+
+import (
+	"context"
+	"io"
+)
+
+var _ io.Reader // For file parameters
+
+// [: pascal .API.Name :]Service calls the operations of [: .API.Name :].[: if .API.Description :]
+//
+// [: .API.Description :][: end :]
+type [: pascal .API.Name :]Service struct {
+	client *Client
+}
+[: range .API.Functions :]
+[:- if or .Query .Headers .Form :]
+
+// [: template "params" . :] are the query, header and form parameters of [: pascal .Name :].
+// Those left unset are not sent.
+type [: template "params" . :] struct {
+[:- range .Query :]
+	[: pascal .Name :] [: template "type" .Type :][: if .Description :] // [: .Description :][: end :]
+[:- end :]
+[:- range .Headers :]
+	[: pascal .Name :] [: template "type" .Type :][: if .Description :] // [: .Description :][: end :]
+[:- end :]
+[:- range .Form :]
+	[: pascal .Name :] [: template "type" .Type :][: if .Description :] // [: .Description :][: end :]
+[:- end :]
+}
+[:- end :]
+
+// [: pascal .Name :] calls [: or .Summary .Key :].[: if .Description :]
+//
+// [: .Description :][: end :]
+//
+// [: .Method :] [: .Path :]
+[:- if .Deprecated :]
+//
+// Deprecated: the operation is deprecated.
+[:- end :]
+func (s *[: pascal $.API.Name :]Service) [: pascal .Name :][: mark .Key (printf "client.%s.%s" (pascal $.API.Name) (pascal .Name)) :](ctx context.Context
+[:- range .PathParams :], [: safe (camel .Name) :] [: template "type" .Type :][: end :]
+[:- with .Body :], body [: template "type" .Type :][: end :]
+[:- if or .Query .Headers .Form :], params *[: template "params" . :][: end :]) [: with .Result :]([: template "type" . :], error)[: else :]error[: end :] {
+	r := newRequest([: quote .Method :], [: quote .Path :])
+[:- range .PathParams :]
+	r.pathParam([: quote .Name :], [: safe (camel .Name) :])
+[:- end :]
+[:- with .Body :]
+	r.body = body
+[:- end :]
+[:- if .ContentType :]
+	r.contentType = [: quote .ContentType :]
+[:- end :]
+[:- if or .Query .Headers .Form :]
+	if params != nil {
+[:- range .Query :]
+		param(r.query, [: quote .Name :], params.[: pascal .Name :], [: quote .CollectionFormat :])
+[:- end :]
+[:- range .Headers :]
+		param(r.header, [: quote .Name :], params.[: pascal .Name :], [: quote .CollectionFormat :])
+[:- end :]
+[:- if .Form :]
+		r.form = map[string]interface{}{
+[:- range .Form :]
+			[: quote .Name :]: params.[: pascal .Name :],
+[:- end :]
+		}
+[:- end :]
+	}
+[:- end :]
+[:- with .Result :]
+	var result [: template "type" . :]
+	err := s.client.do(ctx, r, &result)
+	return result, err
+[:- else :]
+	return s.client.do(ctx, r, nil)
+[:- end :]
+}
+[: end :]
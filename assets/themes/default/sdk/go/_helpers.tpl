@@ -0,0 +1,16 @@
+[:- define "package" -:][: lc (pascal .ID) :][:- end -:]
+
+[:- define "type" -:]
+[:- if eq .Kind "array" -:][][: template "type" .Item :]
+[:- else if eq .Kind "map" -:]map[string][: template "type" .Item :]
+[:- else if eq .Kind "model" -:]*[: pascal .Model :]
+[:- else if eq .Kind "integer" -:][: if eq .Format "int32" :]int32[: else :]int64[: end :]
+[:- else if eq .Kind "number" -:][: if eq .Format "float" :]float32[: else :]float64[: end :]
+[:- else if eq .Kind "boolean" -:]bool
+[:- else if eq .Kind "file" -:]io.Reader
+[:- else if eq .Kind "string" -:]string
+[:- else -:]interface{}
+[:- end -:]
+[:- end -:]
+
+[:- define "params" -:][: pascal .Name :]Params[:- end -:]
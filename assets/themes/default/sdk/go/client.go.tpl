@@ -0,0 +1,214 @@
+// Package [: template "package" . :] is a client of the [: .Title :] API, version [: .Version :].
+//
+// It is generated from the API's documentation, and should not be edited.
+package [: template "package" . :]
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// DefaultBaseURL is the scheme and host the API is documented at.
+const DefaultBaseURL = [: quote .BaseURL :]
+
+// Client calls the operations of the API.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+[: range .Security :]
+[:- if eq .Type "apiKey" :]
+	[: pascal .Name :] string // Sent as the [: .ParamName :] [: .ParamLocation :] parameter
+[:- else if eq .Type "basic" :]
+	[: pascal .Name :]Username string
+	[: pascal .Name :]Password string
+[:- else if eq .Type "oauth2" :]
+	[: pascal .Name :]Token string // An OAuth2 access token, sent as a bearer token
+[:- end :]
+[:- end :]
+[: range .APIs :]
+	[: pascal .Name :] *[: pascal .Name :]Service
+[:- end :]
+}
+
+// NewClient returns a client of the API at DefaultBaseURL.
+func NewClient() *Client {
+	c := &Client{BaseURL: DefaultBaseURL, HTTPClient: http.DefaultClient}
+[:- range .APIs :]
+	c.[: pascal .Name :] = &[: pascal .Name :]Service{client: c}
+[:- end :]
+	return c
+}
+
+// ResponseError is the response to a request that did not succeed.
+type ResponseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// request is a request to an operation.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	contentType string
+	body        interface{}
+	form        map[string]interface{}
+}
+
+func newRequest(method string, path string) *request {
+	return &request{method: method, path: path, query: make(url.Values), header: make(http.Header)}
+}
+
+// pathParam replaces a parameter of the request's path.
+func (r *request) pathParam(name string, value interface{}) {
+	r.path = strings.Replace(r.path, "{"+name+"}", url.PathEscape(fmt.Sprint(value)), 1)
+}
+
+// param adds a parameter to values, unless it has its zero value. The items of
+// arrays are joined as their collection format gives.
+func param(values map[string][]string, name string, value interface{}, collectionFormat string) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() {
+		return
+	}
+	if v.Kind() != reflect.Slice {
+		values[name] = append(values[name], fmt.Sprint(value))
+		return
+	}
+	var items []string
+	for i := 0; i < v.Len(); i++ {
+		items = append(items, fmt.Sprint(v.Index(i).Interface()))
+	}
+	switch collectionFormat {
+	case "multi":
+		values[name] = append(values[name], items...)
+	case "ssv":
+		values[name] = append(values[name], strings.Join(items, " "))
+	case "tsv":
+		values[name] = append(values[name], strings.Join(items, "\t"))
+	case "pipes":
+		values[name] = append(values[name], strings.Join(items, "|"))
+	default:
+		values[name] = append(values[name], strings.Join(items, ","))
+	}
+}
+
+// do sends a request, decoding the response body into result, if given.
+func (c *Client) do(ctx context.Context, r *request, result interface{}) error {
+	var body io.Reader
+	contentType := r.contentType
+	switch {
+	case r.body != nil:
+		buf, err := json.Marshal(r.body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+		if len(contentType) == 0 {
+			contentType = "application/json"
+		}
+	case r.form != nil && strings.HasPrefix(contentType, "multipart/form-data"):
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for name, value := range r.form {
+			if f, ok := value.(io.Reader); ok && f != nil {
+				part, err := w.CreateFormFile(name, name)
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(part, f); err != nil {
+					return err
+				}
+				continue
+			}
+			values := make(url.Values)
+			param(values, name, value, "multi")
+			for _, v := range values[name] {
+				w.WriteField(name, v)
+			}
+		}
+		w.Close()
+		body, contentType = &buf, w.FormDataContentType()
+	case r.form != nil:
+		values := make(url.Values)
+		for name, value := range r.form {
+			param(values, name, value, "multi")
+		}
+		body, contentType = strings.NewReader(values.Encode()), "application/x-www-form-urlencoded"
+	}
+
+	u := strings.TrimSuffix(c.BaseURL, "/") + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequest(r.method, u, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authenticate(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &ResponseError{StatusCode: resp.StatusCode, Body: buf}
+	}
+	if result == nil || len(buf) == 0 {
+		return nil
+	}
+	return json.Unmarshal(buf, result)
+}
+
+// authenticate adds the credentials the client is given to a request.
+func (c *Client) authenticate(req *http.Request) {
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+	if len(c.[: pascal .Name :]) > 0 {
+[:- if eq .ParamLocation "query" :]
+		q := req.URL.Query()
+		q.Set([: quote .ParamName :], c.[: pascal .Name :])
+		req.URL.RawQuery = q.Encode()
+[:- else :]
+		req.Header.Set([: quote .ParamName :], c.[: pascal .Name :])
+[:- end :]
+	}
+[:- else if eq .Type "basic" :]
+	if len(c.[: pascal .Name :]Username) > 0 {
+		req.SetBasicAuth(c.[: pascal .Name :]Username, c.[: pascal .Name :]Password)
+	}
+[:- else if eq .Type "oauth2" :]
+	if len(c.[: pascal .Name :]Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.[: pascal .Name :]Token)
+	}
+[:- end :]
+[:- end :]
+}
@@ -0,0 +1,3 @@
+module [: kebab .ID :]
+
+go 1.13
@@ -0,0 +1,13 @@
+package [: template "package" . :]
+
+import "io"
+
+var _ io.Reader // For file fields
+[: range .Models :]
+// [: pascal .Name :][: if .Description :] [: .Description :][: else :] is a resource of the API.[: end :]
+type [: pascal .Name :] struct {
+[:- range .Fields :]
+	[: pascal .Name :] [: template "type" .Type :] `json:"[: .Name :][: if not .Required :],omitempty[: end :]"`[: if .Description :] // [: .Description :][: end :]
+[:- end :]
+}
+[: end :]
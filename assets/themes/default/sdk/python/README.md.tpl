@@ -0,0 +1,21 @@
+# [: .Title :] Python SDK
+
+A Python client of the [: .Title :] API, version [: .Version :], using only the
+standard library.
+
+```python
+from [: snake .ID :] import [: template "client" . :]
+
+client = [: template "client" . :](
+[:- range $i, $s := .Security :]
+[:- if eq .Type "apiKey" :][: if $i :], [: end :][: snake .Name :]="<[: .ParamName :]>"
+[:- else if eq .Type "oauth2" :][: if $i :], [: end :][: snake .Name :]_token="<access token>"
+[:- else if eq .Type "basic" :][: if $i :], [: end :][: snake .Name :]_username="<username>", [: snake .Name :]_password="<password>"
+[:- end :]
+[:- end :])
+```
+
+Each API is an attribute of the client:
+[: range .APIs :]
+* `client.[: snake .Name :]`, [: .Name :]
+[:- end :]
@@ -0,0 +1,56 @@
+"""[: .API.Name :] of the [: .Title :] API, version [: .Version :].
+
+It is generated from the API's documentation, and should not be edited.
+"""
+
+from typing import Any, BinaryIO, Dict, List, Optional
+
+from .models import *  # noqa: F401,F403
+from .transport import Transport
+
+
+class [: pascal .API.Name :]Api:
+    """Calls the operations of [: .API.Name :].[: if .API.Description :] [: .API.Description :][: end :]"""
+
+    def __init__(self, transport: Transport):
+        self._transport = transport
+[:- range .API.Functions :]
+
+    def [: safe (snake .Name) :][: mark .Key (printf "client.%s.%s" (snake $.API.Name) (safe (snake .Name))) :](self
+[:- range .PathParams :], [: template "keyword" . :]: [: template "type" .Type :][: end :]
+[:- with .Body :], body: [: if .Required :][: template "type" .Type :][: else :]Optional[[: template "type" .Type :]] = None[: end :][: end :]
+[:- if or .Query .Headers .Form :], *[: template "keywords" .Query :][: template "keywords" .Headers :][: template "keywords" .Form :][: end :]) -> [: with .Result :][: template "type" . :][: else :]None[: end :]:
+        """[: or .Summary .Key :][: if .Description :]
+
+        [: .Description :][: end :]
+
+        [: .Method :] [: .Path :]
+[:- if .Deprecated :]
+
+        Deprecated: the operation is deprecated.
+[:- end :]
+        """
+        return self._transport.send(
+            [: quote .Method :],
+            [: quote .Path :],
+[:- if .PathParams :]
+            path_params=[: template "dict" .PathParams :],
+[:- end :]
+[:- if .Query :]
+            query=[: template "dict" .Query :],
+            collection_formats={[: range $i, $p := .Query :][: if $i :], [: end :][: quote .Name :]: [: quote .CollectionFormat :][: end :]},
+[:- end :]
+[:- if .Headers :]
+            headers=[: template "dict" .Headers :],
+[:- end :]
+[:- if .Form :]
+            form=[: template "dict" .Form :],
+[:- end :]
+[:- if .ContentType :]
+            content_type=[: quote .ContentType :],
+[:- end :]
+[:- with .Body :]
+            body=body,
+[:- end :]
+        )
+[:- end :]
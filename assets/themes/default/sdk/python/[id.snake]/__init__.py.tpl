@@ -0,0 +1,24 @@
+"""A client of the [: .Title :] API, version [: .Version :].
+
+It is generated from the API's documentation, and should not be edited.
+"""
+
+from typing import Any
+
+from .models import *  # noqa: F401,F403
+from .transport import DEFAULT_BASE_URL, ResponseError, Transport  # noqa: F401
+[:- range .APIs :]
+from .[: snake .Name :] import [: pascal .Name :]Api
+[:- end :]
+
+__version__ = [: quote .Version :]
+
+
+class [: template "client" . :]:
+    """Calls the operations of each API. Its arguments are those of Transport."""
+
+    def __init__(self, *args: Any, **kwargs: Any):
+        transport = Transport(*args, **kwargs)
+[:- range .APIs :]
+        self.[: snake .Name :] = [: pascal .Name :]Api(transport)
+[:- end :]
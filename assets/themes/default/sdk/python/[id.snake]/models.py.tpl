@@ -0,0 +1,15 @@
+"""The resources of the [: .Title :] API, version [: .Version :].
+
+They are generated from the API's documentation, and should not be edited.
+"""
+
+from typing import Any, BinaryIO, Dict, List, TypedDict
+[: range .Models :]
+
+[: with .Description :]# [: . :]
+[: end :][: pascal .Name :] = TypedDict([: quote (pascal .Name) :], {
+[:- range .Fields :]
+    [: quote .Name :]: [: template "type" .Type :],
+[:- end :]
+}, total=False)
+[: end :]
@@ -0,0 +1,142 @@
+"""Sends requests to the [: .Title :] API, version [: .Version :].
+
+It is generated from the API's documentation, and should not be edited.
+"""
+
+import json
+import uuid
+from typing import Any, Dict, List, Optional, Tuple
+from urllib.error import HTTPError
+from urllib.parse import quote, urlencode
+from urllib.request import Request, urlopen
+
+DEFAULT_BASE_URL = [: quote .BaseURL :]
+
+_SEPARATORS = {"csv": ",", "ssv": " ", "tsv": "\t", "pipes": "|"}
+
+
+class ResponseError(Exception):
+    """The response to a request that did not succeed."""
+
+    def __init__(self, status: int, body: bytes):
+        super().__init__("%d: %s" % (status, body.decode("utf-8", "replace")))
+        self.status = status
+        self.body = body
+
+
+class Transport:
+    """Sends requests to the API, with the credentials it is given."""
+
+    def __init__(self, base_url: Optional[str] = None, timeout: float = 60
+[:- range .Security :]
+[:- if eq .Type "apiKey" :], [: snake .Name :]: Optional[str] = None
+[:- else if eq .Type "basic" :], [: snake .Name :]_username: Optional[str] = None, [: snake .Name :]_password: Optional[str] = None
+[:- else if eq .Type "oauth2" :], [: snake .Name :]_token: Optional[str] = None
+[:- end :]
+[:- end :]):
+        self.base_url = (base_url or DEFAULT_BASE_URL).rstrip("/")
+        self.timeout = timeout
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+        self.[: snake .Name :] = [: snake .Name :]
+[:- else if eq .Type "basic" :]
+        self.[: snake .Name :]_username = [: snake .Name :]_username
+        self.[: snake .Name :]_password = [: snake .Name :]_password
+[:- else if eq .Type "oauth2" :]
+        self.[: snake .Name :]_token = [: snake .Name :]_token
+[:- end :]
+[:- end :]
+
+    def send(self, method: str, path: str, path_params: Optional[Dict[str, Any]] = None,
+             query: Optional[Dict[str, Any]] = None, headers: Optional[Dict[str, Any]] = None,
+             collection_formats: Optional[Dict[str, str]] = None, content_type: Optional[str] = None,
+             body: Any = None, form: Optional[Dict[str, Any]] = None) -> Any:
+        """Sends a request, returning its decoded response body."""
+        formats = collection_formats or {}
+        for name, value in (path_params or {}).items():
+            path = path.replace("{" + name + "}", quote(str(value), safe=""))
+
+        query_list = _params(query, formats)
+        header_dict = {"Accept": "application/json"}
+        header_dict.update(_params(headers, formats))
+
+        data = None
+        if body is not None:
+            data = json.dumps(body).encode("utf-8")
+            header_dict["Content-Type"] = content_type or "application/json"
+        elif form is not None and (content_type or "").startswith("multipart/form-data"):
+            data, header_dict["Content-Type"] = _multipart(form)
+        elif form is not None:
+            data = urlencode(_params(form, {})).encode("utf-8")
+            header_dict["Content-Type"] = "application/x-www-form-urlencoded"
+        self._authenticate(header_dict, query_list)
+
+        url = self.base_url + path
+        if query_list:
+            url += "?" + urlencode(query_list)
+        try:
+            with urlopen(Request(url, data=data, headers=header_dict, method=method), timeout=self.timeout) as response:
+                content = response.read()
+        except HTTPError as e:
+            raise ResponseError(e.code, e.read())
+        return json.loads(content) if content else None
+
+    def _authenticate(self, headers: Dict[str, str], query: List[Tuple[str, str]]) -> None:
+        """Adds the credentials the transport is given to a request."""
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+        if self.[: snake .Name :]:
+[:- if eq .ParamLocation "query" :]
+            query.append(([: quote .ParamName :], self.[: snake .Name :]))
+[:- else :]
+            headers[[: quote .ParamName :]] = self.[: snake .Name :]
+[:- end :]
+[:- else if eq .Type "basic" :]
+        if self.[: snake .Name :]_username:
+            import base64
+            credentials = "%s:%s" % (self.[: snake .Name :]_username, self.[: snake .Name :]_password or "")
+            headers["Authorization"] = "Basic " + base64.b64encode(credentials.encode("utf-8")).decode("ascii")
+[:- else if eq .Type "oauth2" :]
+        if self.[: snake .Name :]_token:
+            headers["Authorization"] = "Bearer " + self.[: snake .Name :]_token
+[:- end :]
+[:- end :]
+        return None
+
+
+def _params(values: Optional[Dict[str, Any]], formats: Dict[str, str]) -> List[Tuple[str, str]]:
+    """Returns the parameters that are set, joining lists as their collection format gives."""
+    params = []
+    for name, value in (values or {}).items():
+        if value is None:
+            continue
+        if isinstance(value, bool):
+            params.append((name, "true" if value else "false"))
+        elif not isinstance(value, (list, tuple)):
+            params.append((name, str(value)))
+        elif formats.get(name) == "multi":
+            params.extend((name, str(v)) for v in value)
+        else:
+            params.append((name, _SEPARATORS.get(formats.get(name, "csv"), ",").join(str(v) for v in value)))
+    return params
+
+
+def _multipart(form: Dict[str, Any]) -> Tuple[bytes, str]:
+    """Returns a multipart/form-data body of a form, whose files are readable objects."""
+    boundary = uuid.uuid4().hex
+    parts = []
+    for name, value in form.items():
+        if value is None:
+            continue
+        if hasattr(value, "read"):
+            filename = getattr(value, "name", name)
+            head = 'Content-Disposition: form-data; name="%s"; filename="%s"\r\nContent-Type: application/octet-stream' % (name, filename)
+            content = value.read()
+        else:
+            head = 'Content-Disposition: form-data; name="%s"' % name
+            content = str(value)
+        if isinstance(content, str):
+            content = content.encode("utf-8")
+        parts.append(b"--" + boundary.encode() + b"\r\n" + head.encode("utf-8") + b"\r\n\r\n" + content + b"\r\n")
+    body = b"".join(parts) + b"--" + boundary.encode() + b"--\r\n"
+    return body, "multipart/form-data; boundary=" + boundary
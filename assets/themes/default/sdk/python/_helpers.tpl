@@ -0,0 +1,22 @@
+[:- define "client" -:][: pascal .ID :]Client[:- end -:]
+
+[:- define "type" -:]
+[:- if eq .Kind "array" -:]List[[: template "type" .Item :]]
+[:- else if eq .Kind "map" -:]Dict[str, [: template "type" .Item :]]
+[:- else if eq .Kind "model" -:][: quote (pascal .Model) :]
+[:- else if eq .Kind "integer" -:]int
+[:- else if eq .Kind "number" -:]float
+[:- else if eq .Kind "boolean" -:]bool
+[:- else if eq .Kind "file" -:]BinaryIO
+[:- else if eq .Kind "string" -:]str
+[:- else -:]Any
+[:- end -:]
+[:- end -:]
+
+[:- define "keyword" -:][: safe (snake .Name) :][:- end -:]
+
+[:- define "keywords" -:]
+[:- range . :], [: template "keyword" . :]: [: if .Required :][: template "type" .Type :][: else :]Optional[[: template "type" .Type :]] = None[: end :][: end :]
+[:- end -:]
+
+[:- define "dict" -:]{[: range $i, $p := . :][: if $i :], [: end :][: quote .Name :]: [: template "keyword" . :][: end :]}[:- end -:]
@@ -0,0 +1,9 @@
+[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = [: quote (kebab .ID) :]
+version = [: quote .Version :]
+description = [: quote (printf "A client of the %s API" .Title) :]
+requires-python = ">=3.8"
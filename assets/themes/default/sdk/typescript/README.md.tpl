@@ -0,0 +1,23 @@
+# [: .Title :] TypeScript SDK
+
+A TypeScript client of the [: .Title :] API, version [: .Version :], which sends
+requests with `fetch`.
+
+```typescript
+import { [: template "client" . :] } from "[: kebab .ID :]";
+
+const client = new [: template "client" . :]({
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+  [: camel .Name :]: "<[: .ParamName :]>",
+[:- else if eq .Type "oauth2" :]
+  [: camel .Name :]Token: "<access token>",
+[:- end :]
+[:- end :]
+});
+```
+
+Each API is a property of the client:
+[: range .APIs :]
+* `client.[: camel .Name :]`, [: .Name :]
+[:- end :]
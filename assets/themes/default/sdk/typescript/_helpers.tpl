@@ -0,0 +1,26 @@
+[:- define "client" -:][: pascal .ID :]Client[:- end -:]
+
+[:- define "type" -:]
+[:- if eq .Kind "array" -:]Array<[: template "type" .Item :]>
+[:- else if eq .Kind "map" -:]Record<string, [: template "type" .Item :]>
+[:- else if eq .Kind "model" -:][: pascal .Model :]
+[:- else if or (eq .Kind "integer") (eq .Kind "number") -:]number
+[:- else if eq .Kind "boolean" -:]boolean
+[:- else if eq .Kind "file" -:]Blob
+[:- else if eq .Kind "string" -:]string
+[:- else -:]unknown
+[:- end -:]
+[:- end -:]
+
+[:- define "params" -:][: pascal .Name :]Params[:- end -:]
+
+[:- define "models" -:]
+[:- range $i, $m := .Models :][: if $i :], [: end :][: pascal .Name :][: end :]
+[:- end -:]
+
+[:- define "param" -:]
+[:- if .Description :]
+  /** [: .Description :] */
+[:- end :]
+  [: camel .Name :][: if not .Required :]?[: end :]: [: template "type" .Type :];
+[:- end -:]
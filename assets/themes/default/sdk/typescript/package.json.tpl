@@ -0,0 +1,13 @@
+{
+  "name": [: quote (kebab .ID) :],
+  "version": [: quote .Version :],
+  "description": [: quote (printf "A client of the %s API" .Title) :],
+  "main": "dist/index.js",
+  "types": "dist/index.d.ts",
+  "scripts": {
+    "build": "tsc"
+  },
+  "devDependencies": {
+    "typescript": "^4.0.0"
+  }
+}
@@ -0,0 +1,67 @@
+// [: .API.Name :] of the [: .Title :] API, version [: .Version :].
+//
+// It is generated from the API's documentation, and should not be edited.
+
+import { Transport } from "./client";
+[:- if .Models :]
+import { [: template "models" . :] } from "./models";
+[:- end :]
+[:- range .API.Functions :]
+[:- if or .Query .Headers .Form :]
+
+/** The query, header and form parameters of [: camel .Name :]. Those left undefined are not sent. */
+export interface [: template "params" . :] {
+[:- range .Query :][: template "param" . :][: end :]
+[:- range .Headers :][: template "param" . :][: end :]
+[:- range .Form :][: template "param" . :][: end :]
+}
+[:- end :]
+[:- end :]
+
+/** Calls the operations of [: .API.Name :].[: if .API.Description :] [: .API.Description :][: end :] */
+export class [: pascal .API.Name :]Api {
+  constructor(private readonly transport: Transport) {}
+[:- range .API.Functions :]
+
+  /**
+   * [: or .Summary .Key :][: if .Description :]
+   *
+   * [: .Description :][: end :]
+   *
+   * [: .Method :] [: .Path :]
+[:- if .Deprecated :]
+   *
+   * @deprecated The operation is deprecated.
+[:- end :]
+   */
+  [: camel .Name :][: mark .Key (printf "client.%s.%s" (camel $.API.Name) (camel .Name)) :](
+[:- range $i, $p := .PathParams :][: if $i :], [: end :][: safe (camel .Name) :]: [: template "type" .Type :][: end :]
+[:- if and .Body .PathParams :], [: end :][: with .Body :]body: [: template "type" .Type :][: end :]
+[:- if and (or .Query .Headers .Form) (or .Body .PathParams) :], [: end :]
+[:- if or .Query .Headers .Form :]params: [: template "params" . :][: if not .RequiresParams :] = {}[: end :][: end :]): Promise<[: with .Result :][: template "type" . :][: else :]void[: end :]> {
+    return this.transport.send({
+      method: [: quote .Method :],
+      path: [: quote .Path :],
+[:- if .PathParams :]
+      pathParams: {[: range $i, $p := .PathParams :][: if $i :],[: end :] [: quote .Name :]: [: safe (camel .Name) :][: end :] },
+[:- end :]
+[:- if .Query :]
+      query: {[: range $i, $p := .Query :][: if $i :],[: end :] [: quote .Name :]: params.[: camel .Name :][: end :] },
+      collectionFormats: {[: range $i, $p := .Query :][: if $i :],[: end :] [: quote .Name :]: [: quote .CollectionFormat :][: end :] },
+[:- end :]
+[:- if .Headers :]
+      headers: {[: range $i, $p := .Headers :][: if $i :],[: end :] [: quote .Name :]: params.[: camel .Name :][: end :] },
+[:- end :]
+[:- if .Form :]
+      form: {[: range $i, $p := .Form :][: if $i :],[: end :] [: quote .Name :]: params.[: camel .Name :][: end :] },
+[:- end :]
+[:- if .ContentType :]
+      contentType: [: quote .ContentType :],
+[:- end :]
+[:- with .Body :]
+      body,
+[:- end :]
+    });
+  }
+[:- end :]
+}
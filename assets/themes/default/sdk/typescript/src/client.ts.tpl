@@ -0,0 +1,147 @@
+// A client of the [: .Title :] API, version [: .Version :].
+//
+// It is generated from the API's documentation, and should not be edited.
+
+/** The scheme and host the API is documented at. */
+export const DEFAULT_BASE_URL = [: quote .BaseURL :];
+
+/** The options a client is created with. */
+export interface ClientOptions {
+  baseUrl?: string;
+  fetch?: typeof fetch;
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+  /** Sent as the [: .ParamName :] [: .ParamLocation :] parameter. */
+  [: camel .Name :]?: string;
+[:- else if eq .Type "basic" :]
+  [: camel .Name :]Username?: string;
+  [: camel .Name :]Password?: string;
+[:- else if eq .Type "oauth2" :]
+  /** An OAuth2 access token, sent as a bearer token. */
+  [: camel .Name :]Token?: string;
+[:- end :]
+[:- end :]
+}
+
+/** The response to a request that did not succeed. */
+export class ResponseError extends Error {
+  constructor(readonly status: number, readonly body: string) {
+    super(`${status}: ${body}`);
+  }
+}
+
+/** A request to an operation. */
+export interface OperationRequest {
+  method: string;
+  path: string;
+  pathParams?: Record<string, unknown>;
+  query?: Record<string, unknown>;
+  headers?: Record<string, unknown>;
+  collectionFormats?: Record<string, string>;
+  contentType?: string;
+  body?: unknown;
+  form?: Record<string, unknown>;
+}
+
+const separators: Record<string, string> = { csv: ",", ssv: " ", tsv: "\t", pipes: "|" };
+
+/** Sends requests to the API. */
+export class Transport {
+  private readonly baseUrl: string;
+  private readonly fetch: typeof fetch;
+
+  constructor(private readonly options: ClientOptions = {}) {
+    this.baseUrl = (options.baseUrl || DEFAULT_BASE_URL).replace(/\/$/, "");
+    this.fetch = options.fetch || fetch;
+  }
+
+  /** Sends a request, returning its decoded response body. */
+  async send<T>(r: OperationRequest): Promise<T> {
+    let path = r.path;
+    for (const [name, value] of Object.entries(r.pathParams || {})) {
+      path = path.replace(`{${name}}`, encodeURIComponent(String(value)));
+    }
+
+    const query = new URLSearchParams();
+    for (const [name, value] of this.params(r.query, r.collectionFormats)) {
+      query.append(name, value);
+    }
+    const headers: Record<string, string> = { Accept: "application/json" };
+    for (const [name, value] of this.params(r.headers, r.collectionFormats)) {
+      headers[name] = value;
+    }
+
+    let body: BodyInit | undefined;
+    if (r.body !== undefined) {
+      body = JSON.stringify(r.body);
+      headers["Content-Type"] = r.contentType || "application/json";
+    } else if (r.form && (r.contentType || "").startsWith("multipart/form-data")) {
+      const form = new FormData();
+      for (const [name, value] of Object.entries(r.form)) {
+        if (value instanceof Blob) {
+          form.append(name, value);
+        } else if (value !== undefined && value !== null) {
+          form.append(name, String(value));
+        }
+      }
+      body = form;
+    } else if (r.form) {
+      const form = new URLSearchParams();
+      for (const [name, value] of this.params(r.form, {})) {
+        form.append(name, value);
+      }
+      body = form;
+    }
+    this.authenticate(headers, query);
+
+    const url = this.baseUrl + path + (Array.from(query).length ? "?" + query.toString() : "");
+    const response = await this.fetch(url, { method: r.method, headers, body });
+    const text = await response.text();
+    if (!response.ok) {
+      throw new ResponseError(response.status, text);
+    }
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+
+  /** Returns the parameters that are set, joining arrays as their collection format gives. */
+  private params(values: Record<string, unknown> = {}, formats: Record<string, string> = {}): Array<[string, string]> {
+    const list: Array<[string, string]> = [];
+    for (const [name, value] of Object.entries(values)) {
+      if (value === undefined || value === null) {
+        continue;
+      }
+      if (!Array.isArray(value)) {
+        list.push([name, String(value)]);
+      } else if (formats[name] === "multi") {
+        value.forEach((v) => list.push([name, String(v)]));
+      } else {
+        list.push([name, value.map(String).join(separators[formats[name]] || ",")]);
+      }
+    }
+    return list;
+  }
+
+  /** Adds the credentials the client is given to a request. */
+  private authenticate(headers: Record<string, string>, query: URLSearchParams): void {
+    const options = this.options;
+[:- range .Security :]
+[:- if eq .Type "apiKey" :]
+    if (options.[: camel .Name :]) {
+[:- if eq .ParamLocation "query" :]
+      query.set([: quote .ParamName :], options.[: camel .Name :]);
+[:- else :]
+      headers[[: quote .ParamName :]] = options.[: camel .Name :];
+[:- end :]
+    }
+[:- else if eq .Type "basic" :]
+    if (options.[: camel .Name :]Username) {
+      headers["Authorization"] = "Basic " + btoa(`${options.[: camel .Name :]Username}:${options.[: camel .Name :]Password || ""}`);
+    }
+[:- else if eq .Type "oauth2" :]
+    if (options.[: camel .Name :]Token) {
+      headers["Authorization"] = "Bearer " + options.[: camel .Name :]Token;
+    }
+[:- end :]
+[:- end :]
+  }
+}
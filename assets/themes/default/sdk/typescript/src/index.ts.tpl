@@ -0,0 +1,28 @@
+// A client of the [: .Title :] API, version [: .Version :].
+//
+// It is generated from the API's documentation, and should not be edited.
+
+import { ClientOptions, Transport } from "./client";
+[:- range .APIs :]
+import { [: pascal .Name :]Api } from "./[: camel .Name :]";
+[:- end :]
+
+export * from "./client";
+export * from "./models";
+[:- range .APIs :]
+export * from "./[: camel .Name :]";
+[:- end :]
+
+/** Calls the operations of each API. */
+export class [: template "client" . :] {
+[:- range .APIs :]
+  readonly [: camel .Name :]: [: pascal .Name :]Api;
+[:- end :]
+
+  constructor(options: ClientOptions = {}) {
+    const transport = new Transport(options);
+[:- range .APIs :]
+    this.[: camel .Name :] = new [: pascal .Name :]Api(transport);
+[:- end :]
+  }
+}
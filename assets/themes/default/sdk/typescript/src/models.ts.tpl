@@ -0,0 +1,14 @@
+// The resources of the [: .Title :] API, version [: .Version :].
+//
+// They are generated from the API's documentation, and should not be edited.
+[: range .Models :]
+/** [: if .Description :][: .Description :][: else :]A resource of the API.[: end :] */
+export interface [: pascal .Name :] {
+[:- range .Fields :]
+[:- if .Description :]
+  /** [: .Description :] */
+[:- end :]
+  [: if .ReadOnly :]readonly [: end :][: quote .Name :][: if not .Required :]?[: end :]: [: if and .Enum (eq .Type.Kind "string") :][: range $i, $e := .Enum :][: if $i :] | [: end :][: quote $e :][: end :][: else :][: template "type" .Type :][: end :];
+[:- end :]
+}
+[: end :]
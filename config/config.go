@@ -18,6 +18,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 
@@ -32,17 +33,66 @@ type config struct {
 	DefaultAssetsDir   string      `env:"DEFAULT_ASSETS_DIR" flag:"default-assets-dir" flagDesc:"Default assets."`
 	SpecDir            string      `env:"SPEC_DIR" flag:"spec-dir" flagDesc:"OpenAPI specification (swagger) directory"`
 	SpecFilename       []string    `env:"SPEC_FILENAME" flag:"spec-filename" flagDesc:"The filename of the OpenAPI specification file within the spec-dir. May be multiply defined. Defaults to spec/swagger.json"`
-	Theme              string      `env:"THEME" flag:"theme" flagDesc:"Theme to render documentation"`
-	ThemeDir           string      `env:"THEME_DIR" flag:"theme-dir" flagDesc:"Directory containing installed themes"`
+	Theme              string      `env:"THEME" flag:"theme" flagDesc:"Theme to render documentation. A theme is a directory or zip archive in the themes directory of assets-dir, theme-dir or the themes directory of default-assets-dir, layered over the parent theme named by its theme.json, or else the default theme."`
+	ThemeDir           string      `env:"THEME_DIR" flag:"theme-dir" flagDesc:"Directory containing installed themes, as directories or zip archives"`
+	ValidateTheme      bool        `env:"VALIDATE_THEME" flag:"validate-theme" flagDesc:"Compile the theme and the themes it is layered over, report templates that override no template of a parent theme or include a template that does not exist, and exit. Exits with status 1 if any are found."`
 	LogLevel           string      `env:"LOGLEVEL" flag:"log-level" flagDesc:"Log level"`
 	SiteURL            string      `env:"SITE_URL" flag:"site-url" flagDesc:"Public URL of the documentation service"`
 	SpecRewriteURL     []string    `env:"SPEC_REWRITE_URL" flag:"spec-rewrite-url" flagDesc:"The URLs in the swagger specifications to be rewritten as site-url"`
 	DocumentRewriteURL []string    `env:"DOCUMENT_REWRITE_URL" flag:"document-rewrite-url" flagDesc:"Specify a document URL that is to be rewritten. May be multiply defined. Format is from=to."`
 	ForceSpecList      bool        `env:"FORCE_SPECIFICATION_LIST" flag:"force-specification-list" flagDesc:"Force the homepage to be the summary list of available specifications. The default when serving a single OpenAPI specification is to make the homepage the API summary."`
 	ShowAssets         bool        `env:"AUTHOR_SHOW_ASSETS" flag:"author-show-assets" flagDesc:"Display at the foot of each page the overlay asset paths, in priority order, that DapperDox will check before rendering."`
-	ProxyPath          []string    `env:"PROXY_PATH" flag:"proxy-path" flagDesc:"Give a path to proxy though to another service. May be multiply defined. Format is local-path=scheme://host/dst-path."`
+	AuthorInspect      bool        `env:"AUTHOR_INSPECT" flag:"author-inspect" flagDesc:"Show authors in preview mode, at the foot of each page, the templates it was rendered from and where each was compiled from, the overlays looked for and found, the template data and any template errors."`
+	PageCache          bool        `env:"PAGE_CACHE" flag:"page-cache" flagDesc:"Cache rendered reference pages by URL, version and locale, serving them with an ETag and Last-Modified. Pages viewed in preview mode are not cached."`
+	Prerender          bool        `env:"PRERENDER" flag:"prerender" flagDesc:"Render every reference page into the page cache at startup, rather than when first requested. Requires page-cache."`
+	StaticURL          string      `env:"STATIC_URL" flag:"static-url" flagDesc:"Base URL, such as that of a CDN, that pages load static assets from rather than from DapperDox. The assets are written for upload by publish-static."`
+	PublishStatic      string      `env:"PUBLISH_STATIC" flag:"publish-static" flagDesc:"Write the compiled static assets, under both their plain and content hashed names, to this directory for upload to static-url, and exit."`
+	StaticIntegrity    bool        `env:"SUBRESOURCE_INTEGRITY" flag:"subresource-integrity" flagDesc:"Give the scripts and stylesheets of pages Subresource Integrity attributes, so that browsers refuse them if they are altered, such as by a CDN."`
+	ProxyPath          []string    `env:"PROXY_PATH" flag:"proxy-path" flagDesc:"Give a path to proxy though to another service. May be multiply defined. Format is local-path=scheme://host/dst-path. Routes needing more, such as credentials or several targets, are configured by the proxies section of the configuration file."`
+	Mock               bool        `env:"MOCK" flag:"mock" flagDesc:"Serve a mock of every operation of the specifications, which validates requests and answers with example responses built from the documented resources. The mock is offered to the explorer as a target environment."`
+	MockPath           string      `env:"MOCK_PATH" flag:"mock-path" flagDesc:"URL path under which the mock is served. An operation is mocked at <mock-path>/<specification-id><operation-path>. A response status may be chosen by the X-Mock-Status header or mock-status query parameter."`
+	MockLatency        string      `env:"MOCK_LATENCY" flag:"mock-latency" flagDesc:"Delay mock responses by a duration, such as 200ms, or by a random duration within a range, such as 100ms-2s."`
+	MockErrorRate      string      `env:"MOCK_ERROR_RATE" flag:"mock-error-rate" flagDesc:"The fraction, from 0 to 1, of mock requests to fail with a documented 5xx response, or else a 500."`
+	ProxyValidate      bool        `env:"PROXY_VALIDATE" flag:"proxy-validate" flagDesc:"Check the requests and responses of every proxied route against the operations of the specifications they match, reporting differences to the explorer and logging them as CONTRACT-DRIFT. Routes of the proxies section may instead be checked individually."`
+	RecordDir          string      `env:"RECORD_DIR" flag:"record-dir" flagDesc:"Record the exchanges of proxied routes with the operations they match beneath this directory, by specification, operation and status, keeping the latest 20 of each. Recorded responses may be promoted to documented examples from the admin recordings report."`
+	RecordRedact       []string    `env:"RECORD_REDACT" flag:"record-redact" flagDesc:"A header, query parameter or JSON field to redact from recordings. May be multiply defined. Authorization, Cookie, Set-Cookie, Proxy-Authorization and X-CSRF-Token headers are always redacted."`
+	Replay             string      `env:"REPLAY" flag:"replay" flagDesc:"Send every request recorded in record-dir to an environment, given by name or URL, report those whose response differs in status, content type or body structure from that recorded, and exit. Exits with status 1 if any differ. Redacted headers and query parameters are not sent. Only GET, HEAD and OPTIONS requests are sent unless replay-unsafe is set."`
+	ReplayUnsafe       bool        `env:"REPLAY_UNSAFE" flag:"replay-unsafe" flagDesc:"Replay recorded requests of every method, including those that change state, such as POST and DELETE."`
+	SDK                []string    `env:"SDK" flag:"sdk" flagDesc:"A language to generate a client SDK of each specification in, such as go, typescript or python, from the templates in the sdk/<language> directory of the theme and assets. May be multiply defined. Archives are downloaded from the specification summary page, and method pages link to their operation's function."`
+	SDKDir             string      `env:"SDK_DIR" flag:"sdk-dir" flagDesc:"Keep the archive of each SDK in this directory, by specification, language and the specification's info.version, so that those of earlier versions may still be downloaded."`
+	ProxyNoCSRF        bool        `env:"PROXY_NO_CSRF" flag:"proxy-no-csrf" flagDesc:"Do not require the CSRF token, which the explorer sends, on state-changing requests to proxy-path routes. It is required by default, so that other sites cannot make them with a reader's cookies."`
+	ContentPolicy      string      `env:"CONTENT_SECURITY_POLICY" flag:"content-security-policy" flagDesc:"The Content-Security-Policy of pages. Defaults to one allowing the scripts and stylesheets that the theme's templates load, and static-url. Give none to send no policy."`
+	FrameAncestors     []string    `env:"FRAME_ANCESTORS" flag:"frame-ancestors" flagDesc:"An origin allowed to frame pages, or 'self' or 'none'. May be multiply defined. Defaults to 'self'. Sent as the frame-ancestors of the Content-Security-Policy and, where it can express it, X-Frame-Options."`
+	ReferrerPolicy     string      `env:"REFERRER_POLICY" flag:"referrer-policy" flagDesc:"The Referrer-Policy of responses."`
+	CORSOrigin         []string    `env:"CORS_ORIGIN" flag:"cors-origin" flagDesc:"An origin allowed cross-origin access to a class of route: pages, specs, static, proxy or mock. May be multiply defined. Format is class=origin, where origin may be *. Defaults to specs=*, static=* and mock=*, and proxied services' own CORS headers."`
 	TLSCertificate     string      `env:"TLS_CERTIFICATE" flag:"tls-certificate" flagDesc:"The fully qualified path to the TLS certificate file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
 	TLSKey             string      `env:"TLS_KEY" flag:"tls-key" flagDesc:"The fully qualified path to the TLS private key file. For HTTP over TLS (HTTPS) both a certificate and a key must be provided."`
+	TLSCertificatePair []string    `env:"TLS_CERTIFICATE_PAIR" flag:"tls-certificate-pair" flagDesc:"An additional TLS certificate, selected by SNI server name. May be multiply defined. Format is certificate-file=key-file."`
+	TLSClientCA        string      `env:"TLS_CLIENT_CA" flag:"tls-client-ca" flagDesc:"The fully qualified path to a PEM bundle of CA certificates used to verify client certificates."`
+	TLSClientAuth      string      `env:"TLS_CLIENT_AUTH" flag:"tls-client-auth" flagDesc:"Client certificate policy: none, request, verify-if-given or require. Defaults to require when tls-client-ca is given."`
+	TLSClientSubject   []string    `env:"TLS_CLIENT_SUBJECT" flag:"tls-client-subject" flagDesc:"A regular expression matched against the verified client certificate subject. May be multiply defined. A client must match at least one to be granted access."`
+	TLSMinVersion      string      `env:"TLS_MIN_VERSION" flag:"tls-min-version" flagDesc:"Minimum TLS protocol version: 1.0, 1.1, 1.2 or 1.3."`
+	TLSCipherPolicy    string      `env:"TLS_CIPHER_POLICY" flag:"tls-cipher-policy" flagDesc:"Cipher suite policy: modern, intermediate, legacy, or a comma separated list of cipher suite names."`
+	TLSRedirectAddr    string      `env:"TLS_REDIRECT_ADDR" flag:"tls-redirect-addr" flagDesc:"Bind address of a plain HTTP listener that redirects all requests to HTTPS. Only used when TLS is enabled."`
+	RedirectFile       string      `env:"REDIRECT_FILE" flag:"redirect-file" flagDesc:"A JSON file recording the page URLs of each build, so that pages whose URL changes are 301 redirected from their old URL. Created if it does not exist."`
+	RedirectURL        []string    `env:"REDIRECT_URL" flag:"redirect-url" flagDesc:"A page URL to 301 redirect. May be multiply defined. Format is old-path=new-path. An old-path ending in / redirects everything beneath it."`
+	AdminPath          string      `env:"ADMIN_PATH" flag:"admin-path" flagDesc:"URL path under which the admin reports, such as the link check, are served. Admin reports are disabled unless given."`
+	AdminToken         string      `env:"ADMIN_TOKEN" flag:"admin-token" flagDesc:"A token required to view admin reports, given as a Bearer Authorization header or a token query parameter. Without one, admin reports are only served to loopback clients." redact:"true"`
+	CheckLinks         bool        `env:"CHECK_LINKS" flag:"check-links" flagDesc:"Render every page, report its broken links and anchors, and exit. Exits with status 1 if any are found that are not in link-check-baseline."`
+	LinkCheckExternal  []string    `env:"LINK_CHECK_EXTERNAL" flag:"link-check-external" flagDesc:"A host whose links are fetched by the link check. May be multiply defined. *.example.com matches any subdomain. Links to other hosts are not checked."`
+	LinkCheckBaseline  string      `env:"LINK_CHECK_BASELINE" flag:"link-check-baseline" flagDesc:"A JSON link check report, as written by link-check-report, of known broken links that are not to fail the check."`
+	LinkCheckReport    string      `env:"LINK_CHECK_REPORT" flag:"link-check-report" flagDesc:"A file to write the JSON link check report to."`
+	RequireApproval    bool        `env:"REQUIRE_APPROVAL" flag:"require-approval" flagDesc:"Hide specifications that are not approved (x-approved) from readers who are not in preview mode."`
+	PreviewToken       string      `env:"PREVIEW_TOKEN" flag:"preview-token" flagDesc:"A token that enters preview mode, showing unapproved specifications and draft guides, when given as ?preview=<token>. Without one, only loopback clients may preview." redact:"true"`
+	HideDeprecated     bool        `env:"HIDE_DEPRECATED" flag:"hide-deprecated" flagDesc:"Hide deprecated operations, parameters, headers and properties until the reader chooses to show them."`
+	Locale             []string    `env:"LOCALE" flag:"locale" flagDesc:"A locale the documentation is published in, such as en or de. May be multiply defined. The first is the default, whose guides and overlays are the unprefixed templates and sections trees. Others are read from locales/<locale> in assets-dir, falling back to the default."`
+	ConfigFile         string      `env:"CONFIG_FILE" flag:"config" flagDesc:"Path to a YAML (.yaml, .yml) or TOML (.toml) configuration file. Environment variables and flags take precedence over the file."`
+	Profile            string      `env:"PROFILE" flag:"profile" flagDesc:"Name of a profile in the configuration file to apply over the file's base settings."`
+	DumpConfig         bool        `env:"DUMP_CONFIG" flag:"dump-config" flagDesc:"Print the effective configuration, with secrets redacted, and exit."`
+
+	specs      []SpecConfig     // Per-specification sections, only settable from the configuration file
+	proxies    []ProxyConfig    // Proxied route sections, only settable from the configuration file
+	categories []CategoryConfig // Category tree, only settable from the configuration file
 }
 
 var cfg *config
@@ -60,17 +110,43 @@ func Get() (*config, error) {
 		LogLevel:         "info",
 		SiteURL:          "http://localhost:3123/",
 		ShowAssets:       false,
+		TLSMinVersion:    "1.2",
+		TLSCipherPolicy:  "intermediate",
+		ReferrerPolicy:   "strict-origin-when-cross-origin",
+		MockPath:         "/mock",
 	}
 
+	defaults := *cfg
+
 	err := gofigure.Gofigure(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	// Settings from the configuration file only apply to those members not
+	// already given by an environment variable or flag.
+	if len(cfg.ConfigFile) != 0 {
+		if err = cfg.loadFile(&defaults); err != nil {
+			return nil, err
+		}
+	} else if len(cfg.Profile) != 0 {
+		return nil, fmt.Errorf("profile '%s' given without a configuration file", cfg.Profile)
+	}
+
+	for _, s := range cfg.specs {
+		if !cfg.hasSpecFilename(s.Source) {
+			cfg.SpecFilename = append(cfg.SpecFilename, s.Source)
+		}
+	}
+
 	if len(cfg.SpecFilename) == 0 {
 		cfg.SpecFilename = append(cfg.SpecFilename, "/swagger.json")
 	}
 
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	cfg.print()
 
 	return cfg, nil
@@ -97,6 +173,18 @@ func (c *config) print() {
 		if !s.Field(i).CanSet() {
 			continue
 		}
-		logger.Printf(nil, "\t%s%s: %s\n", strings.Repeat(" ", ml-len(t.Field(i).Name)), t.Field(i).Name, f.Interface())
+		value := f.Interface()
+		secret := t.Field(i).Tag.Get("redact") == "true"
+		switch f.Kind() {
+		case reflect.String:
+			value = redactString(f.String(), secret)
+		case reflect.Slice:
+			var list []string
+			for _, e := range f.Interface().([]string) {
+				list = append(list, redactString(e, secret))
+			}
+			value = list
+		}
+		logger.Printf(nil, "\t%s%s: %s\n", strings.Repeat(" ", ml-len(t.Field(i).Name)), t.Field(i).Name, value)
 	}
 }
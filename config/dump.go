@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+const redacted = "REDACTED"
+
+// Any URL carrying credentials in its user-info
+var credentialURL = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s@]+@[^\s=]*`)
+
+// ---------------------------------------------------------------------------
+// Dump writes the effective configuration as YAML, in a form that can itself be
+// used as a configuration file. Members tagged redact:"true", proxy credentials, the
+// values of the request and response headers proxies set, and passwords in URLs,
+// are redacted.
+func (c *config) Dump(w io.Writer) error {
+	var doc yaml.MapSlice
+
+	s := reflect.ValueOf(c).Elem()
+	t := s.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("flag")
+		if len(name) == 0 || name == "dump-config" || name == "config" || name == "profile" {
+			continue
+		}
+
+		var value interface{}
+		switch f := s.Field(i); f.Kind() {
+		case reflect.String:
+			value = redactString(f.String(), t.Field(i).Tag.Get("redact") == "true")
+		case reflect.Slice:
+			var list []string
+			for _, e := range f.Interface().([]string) {
+				list = append(list, redactString(e, t.Field(i).Tag.Get("redact") == "true"))
+			}
+			value = list
+		default:
+			value = f.Interface()
+		}
+		doc = append(doc, yaml.MapItem{Key: name, Value: value})
+	}
+
+	if len(c.specs) > 0 {
+		specs := make([]SpecConfig, len(c.specs))
+		for i, sc := range c.specs {
+			specs[i] = sc
+			specs[i].Source = redactString(sc.Source, false)
+			specs[i].Environments = make([]Environment, len(sc.Environments))
+			for j, e := range sc.Environments {
+				specs[i].Environments[j] = Environment{Name: e.Name, URL: redactString(e.URL, false)}
+			}
+		}
+		doc = append(doc, yaml.MapItem{Key: "specs", Value: specs})
+	}
+
+	if len(c.proxies) > 0 {
+		proxies := make([]ProxyConfig, len(c.proxies))
+		for i, pc := range c.proxies {
+			proxies[i] = pc
+			proxies[i].Targets = make([]string, len(pc.Targets))
+			for j, t := range pc.Targets {
+				proxies[i].Targets[j] = redactString(t, false)
+			}
+			if pc.Credential != nil {
+				credential := *pc.Credential
+				credential.Value = redactString(credential.Value, true)
+				proxies[i].Credential = &credential
+			}
+			// Set headers are often credentials of their own
+			proxies[i].RequestHeaders.Set = redactHeaders(pc.RequestHeaders.Set)
+			proxies[i].ResponseHeaders.Set = redactHeaders(pc.ResponseHeaders.Set)
+		}
+		doc = append(doc, yaml.MapItem{Key: "proxies", Value: proxies})
+	}
+
+	if len(c.categories) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "categories", Value: c.categories})
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+
+func redactHeaders(set map[string]string) map[string]string {
+	if len(set) == 0 {
+		return set
+	}
+	headers := make(map[string]string, len(set))
+	for name, value := range set {
+		headers[name] = redactString(value, true)
+	}
+	return headers
+}
+
+// ---------------------------------------------------------------------------
+
+func redactString(s string, secret bool) string {
+	if secret {
+		if len(s) == 0 {
+			return s
+		}
+		return redacted
+	}
+	return credentialURL.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.User == nil {
+			return raw
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		} else {
+			u.User = url.User(redacted)
+		}
+		return u.String()
+	})
+}
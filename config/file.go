@@ -0,0 +1,456 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+// The configuration file takes the same settings as the command line flags,
+// keyed by flag name, plus a list of per-specification sections and a set of
+// named profiles. For example:
+//
+//   bind-addr: 0.0.0.0:3123
+//   spec-dir: examples/specifications
+//   specs:
+//     - source: /petstore/swagger.json
+//       id: petstore
+//       title: Pet Store
+//       category: core
+//       status: production
+//       sortMethodsBy: [path, method]
+//       rewrite:
+//         - from: petstore.swagger.io
+//           to: petstore.example.com
+//       environments:
+//         - name: Production
+//           url: https://petstore.example.com/v2
+//   proxies:
+//     - path: /api
+//       targets: [https://api1.example.com/v2, https://api2.example.com/v2]
+//       stripPrefix: true
+//       requestHeaders:
+//         remove: [Cookie]
+//       credential:
+//         type: bearer
+//         env: API_TOKEN
+//       timeout: 10s
+//       retries: 2
+//       validate: true
+//       healthCheck:
+//         path: /health
+//   categories:
+//     - id: commerce
+//       name: Commerce
+//       description: Selling online
+//       categories:
+//         - id: payments
+//           name: Payments
+//   profiles:
+//     production:
+//       log-level: warn
+//       tls-certificate: /etc/dapperdox/server.crt
+//       tls-key: /etc/dapperdox/server.key
+//
+// A profile may override any top level setting, including specs, proxies and
+// categories.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Pair is a from=to configuration value, as used by rewrite and proxy settings.
+type Pair struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+}
+
+// Environment is a named target environment for a specification's API, selectable
+// in the API explorer. Its URL includes the specification's basePath, if it has one,
+// as operation paths are appended to it without it.
+type Environment struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+// SpecConfig holds the configuration file section for a single specification.
+type SpecConfig struct {
+	Source                string        `json:"source" yaml:"source"`                                                   // Spec filename within spec-dir, or URL
+	Rewrite               []Pair        `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`                             // URL rewrites applied when serving the spec
+	Environments          []Environment `json:"environments,omitempty" yaml:"environments,omitempty"`                   // Explorer target environments
+	ID                    string        `json:"id,omitempty" yaml:"id,omitempty"`                                       // Overrides the ID derived from info.title
+	Title                 string        `json:"title,omitempty" yaml:"title,omitempty"`                                 // Overrides info.title
+	Description           string        `json:"description,omitempty" yaml:"description,omitempty"`                     // Overrides info.description (markdown)
+	Logo                  string        `json:"logo,omitempty" yaml:"logo,omitempty"`                                   // Overrides info.x-logo
+	Category              string        `json:"category,omitempty" yaml:"category,omitempty"`                           // Overrides x-category
+	Status                string        `json:"status,omitempty" yaml:"status,omitempty"`                               // Overrides x-status
+	Visible               *bool         `json:"visible,omitempty" yaml:"visible,omitempty"`                             // Overrides x-visible
+	Approved              *bool         `json:"approved,omitempty" yaml:"approved,omitempty"`                           // Overrides x-approved
+	SortMethodsBy         []string      `json:"sortMethodsBy,omitempty" yaml:"sortMethodsBy,omitempty"`                 // Overrides x-sortMethodsBy
+	NavigateMethodsByName *bool         `json:"navigateMethodsByName,omitempty" yaml:"navigateMethodsByName,omitempty"` // Overrides x-navigateMethodsByName
+}
+
+// ProxyConfig holds the configuration file section for a proxied route, which may
+// do more than a proxy-path setting.
+type ProxyConfig struct {
+	Path            string            `json:"path" yaml:"path"`                                           // Local path prefix
+	Targets         []string          `json:"targets" yaml:"targets"`                                     // Upstream URLs, load balanced
+	StripPrefix     bool              `json:"stripPrefix,omitempty" yaml:"stripPrefix,omitempty"`         // Forward the path without the prefix
+	Rewrite         []Pair            `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`                 // Regular expression path rewrites
+	RequestHeaders  ProxyHeaders      `json:"requestHeaders,omitempty" yaml:"requestHeaders,omitempty"`   // Applied before forwarding
+	ResponseHeaders ProxyHeaders      `json:"responseHeaders,omitempty" yaml:"responseHeaders,omitempty"` // Applied to the upstream's response
+	Credential      *ProxyCredential  `json:"credential,omitempty" yaml:"credential,omitempty"`           // Injected into every request
+	TLS             *ProxyTLS         `json:"tls,omitempty" yaml:"tls,omitempty"`                         // Upstream TLS options
+	Timeout         string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`                 // Of each attempt, such as 30s
+	Retries         int               `json:"retries,omitempty" yaml:"retries,omitempty"`                 // Of idempotent requests that fail
+	HealthCheck     *ProxyHealthCheck `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty"`         // Checks targets, taking failing ones out of use
+	Validate        bool              `json:"validate,omitempty" yaml:"validate,omitempty"`               // Check traffic against the specifications
+	Specification   string            `json:"specification,omitempty" yaml:"specification,omitempty"`     // ID of the specification to check against, else any
+}
+
+// ProxyHeaders are the headers to remove from, and then set on, a proxied request
+// or response.
+type ProxyHeaders struct {
+	Remove []string          `json:"remove,omitempty" yaml:"remove,omitempty"`
+	Set    map[string]string `json:"set,omitempty" yaml:"set,omitempty"`
+}
+
+// ProxyCredential is an API key or token added to proxied requests, so that it
+// never reaches the browser. Its value is given directly, or read from an
+// environment variable or a file.
+type ProxyCredential struct {
+	Type  string `json:"type" yaml:"type"`                     // bearer, basic (user:password), header or query
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"` // Header or query parameter name
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	Env   string `json:"env,omitempty" yaml:"env,omitempty"`
+	File  string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// ProxyTLS configures the TLS connections to a proxied route's targets.
+type ProxyTLS struct {
+	CA                 string `json:"ca,omitempty" yaml:"ca,omitempty"`                   // PEM bundle to verify targets with
+	Certificate        string `json:"certificate,omitempty" yaml:"certificate,omitempty"` // Client certificate
+	Key                string `json:"key,omitempty" yaml:"key,omitempty"`                 // Client certificate key
+	ServerName         string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+}
+
+// ProxyHealthCheck polls a path of each of a proxied route's targets. Targets that
+// fail, by error or 5xx status, are not used until they pass again.
+type ProxyHealthCheck struct {
+	Path     string `json:"path" yaml:"path"`
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"` // Defaults to 10s
+	Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty"`   // Defaults to 2s
+}
+
+// CategoryConfig is a node of the category tree that specifications are filed
+// under, by x-category or their configuration file section.
+type CategoryConfig struct {
+	ID          string           `json:"id" yaml:"id"`                                       // Referenced by x-category
+	Name        string           `json:"name" yaml:"name"`                                   // Display name
+	Description string           `json:"description,omitempty" yaml:"description,omitempty"` // Markdown
+	Order       int              `json:"order,omitempty" yaml:"order,omitempty"`             // Sibling order, then file order
+	Color       string           `json:"color,omitempty" yaml:"color,omitempty"`             // CSS colour used to theme the category
+	Logo        string           `json:"logo,omitempty" yaml:"logo,omitempty"`               // Icon image URL
+	Categories  []CategoryConfig `json:"categories,omitempty" yaml:"categories,omitempty"`   // Sub-categories
+}
+
+// ---------------------------------------------------------------------------
+// ParsePair splits a from=to configuration value. The to part may itself
+// contain an = character.
+func ParsePair(value string) (Pair, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Pair{}, fmt.Errorf("'%s' is not an = delimited from=to pair", value)
+	}
+	return Pair{From: parts[0], To: parts[1]}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Specs returns the per-specification configuration file sections.
+func (c *config) Specs() []SpecConfig {
+	return c.specs
+}
+
+// ---------------------------------------------------------------------------
+// Proxies returns the proxied route configuration file sections.
+func (c *config) Proxies() []ProxyConfig {
+	return c.proxies
+}
+
+// ---------------------------------------------------------------------------
+// Categories returns the configured category tree, which is empty if none was given.
+func (c *config) Categories() []CategoryConfig {
+	return c.categories
+}
+
+// ---------------------------------------------------------------------------
+// SpecSection returns the configuration file section for the specification
+// loaded from source, or nil if there is none.
+func (c *config) SpecSection(source string) *SpecConfig {
+	for i := range c.specs {
+		if normaliseSource(c.specs[i].Source) == normaliseSource(source) {
+			return &c.specs[i]
+		}
+	}
+	return nil
+}
+
+func normaliseSource(source string) string {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "/") {
+		return source
+	}
+	return "/" + source
+}
+
+func (c *config) hasSpecFilename(source string) bool {
+	for _, f := range c.SpecFilename {
+		if normaliseSource(f) == normaliseSource(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// loadFile reads the configuration file, applying its base settings and then
+// those of the selected profile. A setting is only taken from the file if the
+// member still holds its default value, so that the environment and flags win.
+func (c *config) loadFile(defaults *config) error {
+	values, err := readFile(c.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("configuration file %s: %s", c.ConfigFile, err)
+	}
+
+	var profiles map[string]interface{}
+	if p, ok := values["profiles"]; ok {
+		if profiles, ok = p.(map[string]interface{}); !ok {
+			return fmt.Errorf("configuration file %s: profiles must be a map of named profiles", c.ConfigFile)
+		}
+		delete(values, "profiles")
+	}
+
+	if len(c.Profile) != 0 {
+		profile, ok := profiles[c.Profile].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("configuration file %s: profile '%s' is not defined", c.ConfigFile, c.Profile)
+		}
+		for k, v := range profile {
+			values[k] = v
+		}
+	}
+
+	return c.apply(values, defaults)
+}
+
+// ---------------------------------------------------------------------------
+
+func readFile(filename string) (map[string]interface{}, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		var doc map[interface{}]interface{}
+		if err = yaml.Unmarshal(buf, &doc); err != nil {
+			return nil, err
+		}
+		for k, v := range doc {
+			values[fmt.Sprintf("%v", k)] = normaliseValue(v)
+		}
+	case ".toml":
+		if _, err = toml.Decode(string(buf), &values); err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			values[k] = normaliseValue(v)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file type - expected .yaml, .yml or .toml")
+	}
+	return values, nil
+}
+
+// ---------------------------------------------------------------------------
+// normaliseValue converts the nested maps produced by the YAML and TOML decoders
+// into map[string]interface{}, so that they can be handled alike.
+func normaliseValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range t {
+			m[fmt.Sprintf("%v", k)] = normaliseValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range t {
+			m[k] = normaliseValue(e)
+		}
+		return m
+	case []map[string]interface{}:
+		l := make([]interface{}, len(t))
+		for i, e := range t {
+			l[i] = normaliseValue(e)
+		}
+		return l
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, e := range t {
+			l[i] = normaliseValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// ---------------------------------------------------------------------------
+
+func (c *config) apply(values map[string]interface{}, defaults *config) error {
+	var problems []string
+
+	if specs, ok := values["specs"]; ok {
+		delete(values, "specs")
+		c.specs = nil
+		if err := decodeSection(specs, &c.specs); err != nil {
+			problems = append(problems, "specs: "+err.Error())
+		}
+	}
+	if proxies, ok := values["proxies"]; ok {
+		delete(values, "proxies")
+		c.proxies = nil
+		if err := decodeSection(proxies, &c.proxies); err != nil {
+			problems = append(problems, "proxies: "+err.Error())
+		}
+	}
+	if categories, ok := values["categories"]; ok {
+		delete(values, "categories")
+		c.categories = nil
+		if err := decodeSection(categories, &c.categories); err != nil {
+			problems = append(problems, "categories: "+err.Error())
+		}
+	}
+
+	s := reflect.ValueOf(c).Elem()
+	d := reflect.ValueOf(defaults).Elem()
+	t := s.Type()
+
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("flag"); len(name) > 0 {
+			fields[name] = i
+		}
+	}
+
+	for key, value := range values {
+		i, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown setting '%s'", key))
+			continue
+		}
+		if key == "config" || key == "profile" {
+			problems = append(problems, fmt.Sprintf("'%s' may only be given as an environment variable or flag", key))
+			continue
+		}
+		if !reflect.DeepEqual(s.Field(i).Interface(), d.Field(i).Interface()) {
+			continue // Given by environment or flag
+		}
+		if err := setField(s.Field(i), value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("configuration file %s:\n\t%s", c.ConfigFile, strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func setField(f reflect.Value, value interface{}) error {
+	switch f.Kind() {
+	case reflect.String:
+		s, err := scalar(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(s)
+	case reflect.Bool:
+		switch b := value.(type) {
+		case bool:
+			f.SetBool(b)
+		case string:
+			if b != "true" && b != "false" {
+				return fmt.Errorf("expected true or false, got '%s'", b)
+			}
+			f.SetBool(b == "true")
+		default:
+			return fmt.Errorf("expected true or false")
+		}
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value} // A single value is a list of one
+		}
+		var strs []string
+		for _, e := range list {
+			s, err := scalar(e)
+			if err != nil {
+				return err
+			}
+			strs = append(strs, s)
+		}
+		f.Set(reflect.ValueOf(strs))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Kind())
+	}
+	return nil
+}
+
+func scalar(value interface{}) (string, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("expected a single value")
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// ---------------------------------------------------------------------------
+// decodeSection decodes a structured section, such as specs, by round-tripping
+// it through JSON, which gives field type checking for free.
+func decodeSection(value interface{}, target interface{}) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
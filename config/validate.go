@@ -0,0 +1,495 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wix/dapperdox/logger"
+)
+
+// Valid specification IDs, as used in URLs
+var specID = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Valid locales, which are lowercase as they are used as URL prefixes
+var localeTag = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// Valid SDK languages, which name template directories and are URL path segments
+var sdkLanguage = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// The method sort orders understood by the spec package (see x-sortMethodsBy)
+var methodSortTypes = map[string]bool{
+	"path":       true,
+	"method":     true,
+	"operation":  true,
+	"navigation": true,
+	"summary":    true,
+}
+
+// The Referrer-Policy values
+var referrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// The classes of route that cors-origin gives a policy for (see security.Classes)
+var corsClasses = map[string]bool{
+	"pages":  true,
+	"specs":  true,
+	"static": true,
+	"proxy":  true,
+	"mock":   true,
+}
+
+// problems collects validation failures, so that they can all be reported at once.
+type problems []string
+
+func (p *problems) add(setting string, format string, args ...interface{}) {
+	*p = append(*p, setting+": "+fmt.Sprintf(format, args...))
+}
+
+// ---------------------------------------------------------------------------
+// validate checks every setting, returning a single error listing all problems.
+func (c *config) validate() error {
+	var p problems
+
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		p.add("BindAddr", "'%s' is not a host:port address", c.BindAddr)
+	}
+	if _, err := logger.LevelFromString(c.LogLevel); err != nil {
+		p.add("LogLevel", "%s", err)
+	}
+	if !isAbsoluteURL(c.SiteURL) {
+		p.add("SiteURL", "'%s' is not an absolute http(s) URL", c.SiteURL)
+	}
+
+	if len(c.StaticURL) > 0 && !isAbsoluteURL(c.StaticURL) {
+		p.add("StaticURL", "'%s' is not an absolute http(s) URL", c.StaticURL)
+	}
+
+	p.directory("AssetsDir", c.AssetsDir)
+	p.directory("SpecDir", c.SpecDir)
+	p.directory("ThemeDir", c.ThemeDir)
+	if strings.ContainsAny(c.Theme, "/\\") || c.Theme == "." || c.Theme == ".." {
+		p.add("Theme", "'%s' must be the name of a theme, not a path", c.Theme)
+	}
+
+	for _, v := range c.SpecRewriteURL {
+		if len(v) == 0 || strings.HasPrefix(v, "=") || strings.HasSuffix(v, "=") {
+			p.add("SpecRewriteURL", "'%s' must be a URL, or an = delimited from=to pair", v)
+		}
+	}
+	for _, v := range c.DocumentRewriteURL {
+		if _, err := ParsePair(v); err != nil {
+			p.add("DocumentRewriteURL", "%s", err)
+		}
+	}
+	proxyPaths := make(map[string]bool)
+	for _, v := range c.ProxyPath {
+		pair, err := ParsePair(v)
+		if err == nil {
+			proxyPaths[pair.From] = true
+		}
+		switch {
+		case err != nil:
+			p.add("ProxyPath", "%s", err)
+		case !strings.HasPrefix(pair.From, "/"):
+			p.add("ProxyPath", "'%s': local path '%s' must start with /", v, pair.From)
+		case !isAbsoluteURL(pair.To):
+			p.add("ProxyPath", "'%s': target '%s' is not an absolute http(s) URL", v, pair.To)
+		}
+	}
+
+	for _, v := range c.FrameAncestors {
+		switch {
+		case v == "'self'" || v == "'none'":
+			if v == "'none'" && len(c.FrameAncestors) > 1 {
+				p.add("FrameAncestors", "'none' cannot be given with other origins")
+			}
+		case !isAbsoluteURL(v):
+			p.add("FrameAncestors", "'%s' is not 'self', 'none' or an absolute http(s) URL", v)
+		}
+	}
+	if !referrerPolicies[c.ReferrerPolicy] {
+		p.add("ReferrerPolicy", "'%s' is not a Referrer-Policy", c.ReferrerPolicy)
+	}
+	for _, v := range c.CORSOrigin {
+		pair, err := ParsePair(v)
+		switch {
+		case err != nil:
+			p.add("CORSOrigin", "%s", err)
+		case !corsClasses[pair.From]:
+			p.add("CORSOrigin", "'%s': class '%s' must be one of pages, specs, static, proxy or mock", v, pair.From)
+		case pair.To != "*" && !isAbsoluteURL(pair.To):
+			p.add("CORSOrigin", "'%s': origin '%s' is not * or an absolute http(s) URL", v, pair.To)
+		}
+	}
+
+	for _, v := range c.RedirectURL {
+		pair, err := ParsePair(v)
+		switch {
+		case err != nil:
+			p.add("RedirectURL", "%s", err)
+		case !strings.HasPrefix(pair.From, "/") || !strings.HasPrefix(pair.To, "/"):
+			p.add("RedirectURL", "'%s': both paths must start with /", v)
+		}
+	}
+	if len(c.RedirectFile) > 0 {
+		if info, err := os.Stat(c.RedirectFile); err == nil && info.IsDir() {
+			p.add("RedirectFile", "'%s' is a directory", c.RedirectFile)
+		}
+	}
+
+	if len(c.AdminPath) > 0 && (!strings.HasPrefix(c.AdminPath, "/") || strings.HasSuffix(c.AdminPath, "/")) {
+		p.add("AdminPath", "'%s' must start with / and not end with one", c.AdminPath)
+	}
+	for _, v := range c.LinkCheckExternal {
+		if len(strings.TrimPrefix(v, "*.")) == 0 || strings.ContainsAny(v, "/:") {
+			p.add("LinkCheckExternal", "'%s' must be a host name, optionally starting *.", v)
+		}
+	}
+	p.file("LinkCheckBaseline", c.LinkCheckBaseline)
+
+	locales := make(map[string]bool)
+	for _, v := range c.Locale {
+		switch {
+		case !localeTag.MatchString(v):
+			p.add("Locale", "'%s' is not a language tag such as en or pt-br", v)
+		case locales[v]:
+			p.add("Locale", "'%s' is given more than once", v)
+		}
+		locales[v] = true
+	}
+
+	if !strings.HasPrefix(c.MockPath, "/") || strings.HasSuffix(c.MockPath, "/") {
+		p.add("MockPath", "'%s' must start with / and not end with one", c.MockPath)
+	}
+	if len(c.MockLatency) > 0 {
+		if _, _, err := ParseDurationRange(c.MockLatency); err != nil {
+			p.add("MockLatency", "%s", err)
+		}
+	}
+	if len(c.MockErrorRate) > 0 {
+		if rate, err := strconv.ParseFloat(c.MockErrorRate, 64); err != nil || rate < 0 || rate > 1 {
+			p.add("MockErrorRate", "'%s' is not a fraction from 0 to 1", c.MockErrorRate)
+		}
+	}
+
+	if len(c.Replay) > 0 && len(c.RecordDir) == 0 {
+		p.add("Replay", "requires record-dir")
+	}
+	if c.ReplayUnsafe && len(c.Replay) == 0 {
+		p.add("ReplayUnsafe", "requires replay")
+	}
+
+	languages := make(map[string]bool)
+	for _, v := range c.SDK {
+		switch {
+		case !sdkLanguage.MatchString(v):
+			p.add("SDK", "'%s' is not a language name of lower case letters, digits and -", v)
+		case languages[v]:
+			p.add("SDK", "'%s' is given more than once", v)
+		}
+		languages[v] = true
+	}
+	if len(c.SDKDir) > 0 && len(c.SDK) == 0 {
+		p.add("SDKDir", "requires sdk")
+	}
+
+	if c.Prerender && !c.PageCache {
+		p.add("Prerender", "requires page-cache")
+	}
+
+	if c.PreviewToken == "0" {
+		p.add("PreviewToken", "'0' is reserved for leaving preview mode")
+	}
+
+	c.validateTLS(&p)
+
+	sources := make(map[string]bool)
+	ids := make(map[string]bool)
+	for i, s := range c.specs {
+		setting := fmt.Sprintf("specs[%d]", i)
+		if len(s.Source) == 0 {
+			p.add(setting, "source must be given")
+		} else if sources[normaliseSource(s.Source)] {
+			p.add(setting, "source '%s' is configured more than once", s.Source)
+		}
+		sources[normaliseSource(s.Source)] = true
+
+		if len(s.ID) > 0 {
+			if !specID.MatchString(s.ID) {
+				p.add(setting, "id '%s' must contain only lower case letters, digits and hyphens", s.ID)
+			} else if ids[s.ID] {
+				p.add(setting, "id '%s' is used by more than one specification", s.ID)
+			}
+			ids[s.ID] = true
+		}
+		for _, by := range s.SortMethodsBy {
+			if !methodSortTypes[by] {
+				p.add(setting, "sortMethodsBy value '%s' must be one of path, method, operation, navigation or summary", by)
+			}
+		}
+
+		for _, r := range s.Rewrite {
+			if len(r.From) == 0 || len(r.To) == 0 {
+				p.add(setting, "rewrite rules must give both from and to")
+			}
+		}
+		for _, e := range s.Environments {
+			if len(e.Name) == 0 {
+				p.add(setting, "environments must be named")
+			}
+			if !isAbsoluteURL(e.URL) {
+				p.add(setting, "environment '%s' URL '%s' is not an absolute http(s) URL", e.Name, e.URL)
+			}
+		}
+	}
+
+	for i, pc := range c.proxies {
+		setting := fmt.Sprintf("proxies[%d]", i)
+		switch {
+		case !strings.HasPrefix(pc.Path, "/"):
+			p.add(setting, "path '%s' must start with /", pc.Path)
+		case proxyPaths[pc.Path]:
+			p.add(setting, "path '%s' is proxied more than once", pc.Path)
+		}
+		proxyPaths[pc.Path] = true
+		p.proxy(setting, &pc)
+	}
+
+	p.categories("categories", c.categories, make(map[string]bool))
+
+	if len(p) > 0 {
+		return fmt.Errorf("invalid configuration:\n\t%s", strings.Join(p, "\n\t"))
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func (c *config) validateTLS(p *problems) {
+	if (len(c.TLSCertificate) == 0) != (len(c.TLSKey) == 0) {
+		p.add("TLSCertificate", "both a certificate and a key must be provided to enable TLS")
+	}
+	p.file("TLSCertificate", c.TLSCertificate)
+	p.file("TLSKey", c.TLSKey)
+	p.file("TLSClientCA", c.TLSClientCA)
+
+	for _, v := range c.TLSCertificatePair {
+		pair, err := ParsePair(v)
+		if err != nil {
+			p.add("TLSCertificatePair", "%s", err)
+			continue
+		}
+		p.file("TLSCertificatePair", pair.From)
+		p.file("TLSCertificatePair", pair.To)
+	}
+
+	switch c.TLSMinVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		p.add("TLSMinVersion", "'%s' must be one of 1.0, 1.1, 1.2 or 1.3", c.TLSMinVersion)
+	}
+
+	switch c.TLSClientAuth {
+	case "", "none", "request":
+	case "verify-if-given", "require":
+		if len(c.TLSClientCA) == 0 {
+			p.add("TLSClientAuth", "'%s' requires a TLSClientCA bundle to verify against", c.TLSClientAuth)
+		}
+	default:
+		p.add("TLSClientAuth", "'%s' must be one of none, request, verify-if-given or require", c.TLSClientAuth)
+	}
+
+	if len(c.TLSClientSubject) > 0 && (len(c.TLSCertificate) == 0 || len(c.TLSKey) == 0) {
+		p.add("TLSClientSubject", "requires tls-certificate and tls-key, as without TLS there is no client certificate")
+	}
+	for _, v := range c.TLSClientSubject {
+		if _, err := regexp.Compile(v); err != nil {
+			p.add("TLSClientSubject", "'%s' is not a valid regular expression: %s", v, err)
+		}
+	}
+
+	switch strings.ToLower(c.TLSCipherPolicy) {
+	case "", "modern", "intermediate", "legacy":
+	default:
+		known := make(map[string]bool)
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			known[s.Name] = true
+		}
+		for _, name := range strings.Split(c.TLSCipherPolicy, ",") {
+			if !known[strings.TrimSpace(name)] {
+				p.add("TLSCipherPolicy", "'%s' is not a policy (modern, intermediate, legacy) or known cipher suite", name)
+			}
+		}
+	}
+
+	if len(c.TLSRedirectAddr) > 0 {
+		if _, _, err := net.SplitHostPort(c.TLSRedirectAddr); err != nil {
+			p.add("TLSRedirectAddr", "'%s' is not a host:port address", c.TLSRedirectAddr)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (p *problems) proxy(setting string, pc *ProxyConfig) {
+	if len(pc.Targets) == 0 {
+		p.add(setting, "targets must be given")
+	}
+	for _, t := range pc.Targets {
+		if !isAbsoluteURL(t) {
+			p.add(setting, "target '%s' is not an absolute http(s) URL", t)
+		}
+	}
+	for _, r := range pc.Rewrite {
+		if _, err := regexp.Compile(r.From); err != nil {
+			p.add(setting, "rewrite '%s' is not a valid regular expression: %s", r.From, err)
+		}
+	}
+	p.duration(setting+".timeout", pc.Timeout)
+	if pc.Retries < 0 {
+		p.add(setting, "retries must not be negative")
+	}
+
+	if cred := pc.Credential; cred != nil {
+		s := setting + ".credential"
+		switch cred.Type {
+		case "bearer", "basic":
+		case "header", "query":
+			if len(cred.Name) == 0 {
+				p.add(s, "a %s credential must give the name to send it as", cred.Type)
+			}
+		default:
+			p.add(s, "type '%s' must be one of bearer, basic, header or query", cred.Type)
+		}
+		given := 0
+		for _, v := range []string{cred.Value, cred.Env, cred.File} {
+			if len(v) > 0 {
+				given++
+			}
+		}
+		if given != 1 {
+			p.add(s, "exactly one of value, env or file must be given")
+		}
+		p.file(s+".file", cred.File)
+	}
+
+	if t := pc.TLS; t != nil {
+		s := setting + ".tls"
+		p.file(s+".ca", t.CA)
+		p.file(s+".certificate", t.Certificate)
+		p.file(s+".key", t.Key)
+		if (len(t.Certificate) == 0) != (len(t.Key) == 0) {
+			p.add(s, "both a client certificate and key must be given")
+		}
+	}
+
+	if hc := pc.HealthCheck; hc != nil {
+		s := setting + ".healthCheck"
+		if !strings.HasPrefix(hc.Path, "/") {
+			p.add(s, "path '%s' must start with /", hc.Path)
+		}
+		p.duration(s+".interval", hc.Interval)
+		p.duration(s+".timeout", hc.Timeout)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// categories checks a level of the category tree. IDs must be unique across the
+// whole tree, as they are what x-category refers to.
+func (p *problems) categories(setting string, categories []CategoryConfig, ids map[string]bool) {
+	for i, c := range categories {
+		s := fmt.Sprintf("%s[%d]", setting, i)
+		switch {
+		case !specID.MatchString(c.ID):
+			p.add(s, "id '%s' must contain only lower case letters, digits and hyphens", c.ID)
+		case ids[c.ID]:
+			p.add(s, "id '%s' is used by more than one category", c.ID)
+		}
+		ids[c.ID] = true
+		if len(c.Name) == 0 {
+			p.add(s, "name must be given")
+		}
+		p.categories(s+".categories", c.Categories, ids)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+func (p *problems) directory(setting string, dir string) {
+	if len(dir) == 0 {
+		return
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		p.add(setting, "'%s' is not a directory", dir)
+	}
+}
+
+func (p *problems) file(setting string, filename string) {
+	if len(filename) == 0 {
+		return
+	}
+	if info, err := os.Stat(filename); err != nil || info.IsDir() {
+		p.add(setting, "'%s' is not a readable file", filename)
+	}
+}
+
+func (p *problems) duration(setting string, value string) {
+	if len(value) == 0 {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		p.add(setting, "'%s' is not a duration such as 30s", value)
+	}
+}
+
+// ParseDurationRange parses a duration, such as 200ms, or a range of durations, such
+// as 100ms-2s, returning its bounds.
+func ParseDurationRange(value string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(value, "-", 2)
+	min, err := time.ParseDuration(parts[0])
+	max := min
+	if err == nil && len(parts) == 2 {
+		max, err = time.ParseDuration(parts[1])
+	}
+	if err != nil || min < 0 || max < min {
+		return 0, 0, fmt.Errorf("'%s' is not a duration such as 200ms, or a range such as 100ms-2s", value)
+	}
+	return min, max, nil
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/linkcheck"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/network"
+	"github.com/wix/dapperdox/record"
+	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/spec"
+	"github.com/gorilla/pat"
+	"github.com/justinas/nosurf"
+)
+
+// ----------------------------------------------------------------------------------------
+// Register creates routes for the admin reports, under admin-path. The link check
+// renders pages through r, so must be registered after all of the page routes.
+func Register(r *pat.Router) {
+	cfg, _ := config.Get()
+	if len(cfg.AdminPath) == 0 {
+		return
+	}
+	logger.Debugln(nil, "registering handlers for admin reports")
+
+	r.Path(cfg.AdminPath + "/links").Methods("GET").HandlerFunc(Handler(linksHandler(r)))
+	r.Path(cfg.AdminPath + "/review").Methods("GET").HandlerFunc(Handler(reviewHandler))
+
+	if record.Enabled() {
+		r.Path(cfg.AdminPath + "/recordings").Methods("GET").HandlerFunc(Handler(recordingsHandler))
+		r.Path(cfg.AdminPath + "/recordings/promote").Methods("POST").HandlerFunc(Handler(promoteHandler))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// Handler only serves h to admin clients: those giving admin-token, or if there is
+// none, loopback clients.
+func Handler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !authorised(req) {
+			logger.Warnf(req, "Refusing admin request from %s", req.RemoteAddr)
+			render.HTML(w, http.StatusForbidden, "error", render.DefaultVars(req, nil, render.Vars{"error": "Forbidden", "code": 403}))
+			return
+		}
+		h(w, req)
+	}
+}
+
+func authorised(req *http.Request) bool {
+	cfg, _ := config.Get()
+
+	if len(cfg.AdminToken) == 0 {
+		return network.IsLoopback(req)
+	}
+
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) == 1
+}
+
+// ----------------------------------------------------------------------------------------
+// IsAdmin returns true if a request is for an admin report. Reports such as the link
+// check may take longer than the timeout pages are served within.
+func IsAdmin(req *http.Request) bool {
+	cfg, _ := config.Get()
+	return len(cfg.AdminPath) > 0 && strings.HasPrefix(req.URL.Path, cfg.AdminPath+"/")
+}
+
+// ----------------------------------------------------------------------------------------
+// WantsJSON returns true if the report was requested as JSON, by format=json or the
+// Accept header.
+func WantsJSON(req *http.Request) bool {
+	return req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json")
+}
+
+// ----------------------------------------------------------------------------------------
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Errorf(nil, "Error writing admin report: %s", err)
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+
+func linksHandler(r *pat.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := linkcheck.Check(r)
+
+		if WantsJSON(req) {
+			writeJSON(w, report)
+			return
+		}
+		render.HTML(w, http.StatusOK, "admin/links", render.DefaultVars(req, nil, render.Vars{"Title": "Link check", "Report": report}))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// ReviewItem is a specification or guide pending approval.
+type ReviewItem struct {
+	Kind   string // "specification" or "guide"
+	Title  string
+	URL    string
+	Status string
+	Source string // Where an unapproved specification's approval setting came from
+}
+
+// ----------------------------------------------------------------------------------------
+// ReviewQueue returns the unapproved specifications, ordered by ID, followed by the
+// draft guides, ordered by URL. Specifications are only held back for approval, and
+// so listed, if require-approval is set.
+func ReviewQueue() []ReviewItem {
+	cfg, _ := config.Get()
+
+	var ids []string
+	for id, specification := range spec.APISuite {
+		if cfg.RequireApproval && !specification.Approved {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	queue := make([]ReviewItem, 0)
+	for _, id := range ids {
+		specification := spec.APISuite[id]
+		queue = append(queue, ReviewItem{
+			Kind:   "specification",
+			Title:  specification.APIInfo.Title,
+			URL:    "/" + specification.ID + "/reference",
+			Status: specification.Status,
+			Source: string(specification.Sources["approved"]),
+		})
+	}
+	for _, guide := range asset.DraftGuides() {
+		title := guide.Title
+		if len(title) == 0 {
+			title = guide.URL
+		}
+		queue = append(queue, ReviewItem{Kind: "guide", Title: title, URL: guide.URL, Status: guide.Status})
+	}
+	return queue
+}
+
+// ----------------------------------------------------------------------------------------
+
+func reviewHandler(w http.ResponseWriter, req *http.Request) {
+	queue := ReviewQueue()
+
+	if WantsJSON(req) {
+		writeJSON(w, queue)
+		return
+	}
+	render.HTML(w, http.StatusOK, "admin/review", render.DefaultVars(req, nil, render.Vars{"Title": "Review queue", "Queue": queue}))
+}
+
+// ----------------------------------------------------------------------------------------
+// recordingsHandler lists the recorded exchanges, from which responses may be promoted
+// to the documented examples of their operations.
+func recordingsHandler(w http.ResponseWriter, req *http.Request) {
+	exchanges, err := record.List()
+	if err != nil {
+		logger.Errorf(req, "Error reading recorded exchanges: %s", err)
+		render.HTML(w, http.StatusInternalServerError, "error", render.DefaultVars(req, nil, render.Vars{"error": "Recorded exchanges could not be read", "code": 500}))
+		return
+	}
+
+	if WantsJSON(req) {
+		writeJSON(w, exchanges)
+		return
+	}
+	render.HTML(w, http.StatusOK, "admin/recordings", render.DefaultVars(req, nil, render.Vars{
+		"Title":     "Recordings",
+		"Exchanges": exchanges,
+		"Token":     req.URL.Query().Get("token"),
+		"CSRFToken": nosurf.Token(req),
+	}))
+}
+
+// ----------------------------------------------------------------------------------------
+
+func promoteHandler(w http.ResponseWriter, req *http.Request) {
+	cfg, _ := config.Get()
+
+	id := req.FormValue("id")
+	if _, err := record.Promote(id); err != nil {
+		logger.Warnf(req, "Error promoting exchange %s: %s", id, err)
+		render.HTML(w, http.StatusBadRequest, "error", render.DefaultVars(req, nil, render.Vars{"error": err.Error(), "code": 400}))
+		return
+	}
+
+	location := cfg.AdminPath + "/recordings"
+	if token := req.URL.Query().Get("token"); len(token) > 0 {
+		location += "?token=" + url.QueryEscape(token)
+	}
+	http.Redirect(w, req, location, http.StatusSeeOther)
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -0,0 +1,295 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+// Exports let readers import the operations of a specification into their own tools,
+// as a Postman collection, an Insomnia export or a HAR file. Each request is filled
+// in with example path, query, header and body values, and the authentication its
+// operation documents, and is sent to the environment chosen by the environment
+// query parameter, or else to the specification's host.
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/handlers/mock"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/security"
+	"github.com/wix/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// EnvironmentQuery chooses the environment an export's requests are sent to, by name.
+const EnvironmentQuery = "environment"
+
+// format is a kind of export.
+type format struct {
+	extension string // Of the downloaded file, after the specification ID
+	build     func(e *export) interface{}
+}
+
+// Export formats, by the name they are served under
+var formats = map[string]format{
+	"postman":  {".postman_collection.json", postman},
+	"insomnia": {".insomnia.json", insomnia},
+	"har":      {".har", har},
+}
+
+// Path parameters of operation paths
+var paramRegex = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// export is a specification's operations, grouped by API, with example requests.
+type export struct {
+	specification *spec.APISpecification
+	environment   string // Name of the chosen environment, if any
+	baseURL       string
+	groups        []group
+}
+
+type group struct {
+	name     string
+	requests []request
+}
+
+// request is an example request to an operation, from which each format is built.
+type request struct {
+	id          string // Unique within the specification
+	name        string
+	description string
+	method      string
+	path        string // As documented, with {parameters}, relative to the basePath
+	pathParams  []param
+	query       []param
+	headers     []param
+	contentType string
+	body        string  // Example body, if the operation takes one
+	form        []param // Form parameters, if it takes them instead
+	auth        *auth
+}
+
+type param struct {
+	name        string
+	value       string
+	description string
+	required    bool
+	file        bool // A file form parameter
+}
+
+// auth is the security scheme an operation documents, of those it accepts the first.
+type auth struct {
+	name   string
+	scheme *spec.SecurityScheme
+	scopes []string
+}
+
+// ----------------------------------------------------------------------------------------
+// Register creates routes for each export of each specification, at
+// /<specification-id>/export/<format>, and for its generated SDKs.
+func Register(r *pat.Router) {
+	logger.Debugln(nil, "registering handlers for specification exports")
+
+	for _, specification := range spec.APISuite {
+		prefix := "/" + specification.ID + "/export/"
+		security.RoutePrefix(security.Specs, prefix)
+
+		for name, f := range formats {
+			logger.Debugf(nil, "+ %s%s", prefix, name)
+			r.Path(prefix + name).Methods("GET").HandlerFunc(handler(specification, f))
+		}
+	}
+	registerSDKs(r)
+}
+
+// ----------------------------------------------------------------------------------------
+
+func handler(specification *spec.APISpecification, f format) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !specification.Published() && !publish.Preview(req) {
+			render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, render.Vars{"error": "Page not found", "code": 404}))
+			return
+		}
+
+		e, ok := newExport(specification, req.URL.Query().Get(EnvironmentQuery))
+		if !ok {
+			render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, render.Vars{"error": "Environment not found", "code": 404}))
+			return
+		}
+
+		buf, err := json.MarshalIndent(f.build(e), "", "  ")
+		if err != nil {
+			logger.Errorf(req, "Error exporting %s: %s", specification.ID, err)
+			render.HTML(w, http.StatusInternalServerError, "error", render.DefaultVars(req, nil, render.Vars{"error": "Export failed", "code": 500}))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+specification.ID+f.extension+`"`)
+		w.Write(buf)
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// newExport builds the example requests of a specification, sent to the environment of
+// a name, if given. It returns false if the specification has no such environment.
+func newExport(specification *spec.APISpecification, environment string) (*export, bool) {
+	e := &export{specification: specification}
+
+	if len(environment) > 0 {
+		for _, env := range specification.Environments {
+			if env.Name == environment {
+				e.environment, e.baseURL = env.Name, env.URL
+			}
+		}
+		if len(e.baseURL) == 0 {
+			return nil, false
+		}
+	}
+
+	for a := range specification.APIs {
+		api := &specification.APIs[a]
+		if len(e.baseURL) == 0 && api.URL != nil {
+			e.baseURL = api.URL.String() + specification.BasePath
+		}
+		g := group{name: api.Name}
+		for m := range api.Methods {
+			g.requests = append(g.requests, newRequest(specification, api, &api.Methods[m]))
+		}
+		e.groups = append(e.groups, g)
+	}
+	e.baseURL = strings.TrimSuffix(e.baseURL, "/")
+	return e, true
+}
+
+// ----------------------------------------------------------------------------------------
+// newRequest builds the example request of an operation. Its path is relative to the
+// specification's basePath, which the base URL of every environment includes.
+func newRequest(specification *spec.APISpecification, api *spec.APIGroup, method *spec.Method) request {
+	r := request{
+		id:          api.ID + "/" + method.ID,
+		name:        method.Name,
+		description: method.Description,
+		method:      strings.ToUpper(method.Method),
+		path:        strings.TrimPrefix(method.Path, specification.BasePath),
+		pathParams:  params(method.PathParams),
+		query:       params(method.QueryParams),
+		headers:     params(method.HeaderParams),
+		auth:        authOf(method),
+	}
+
+	switch {
+	case method.BodyParam != nil:
+		r.contentType = consumed(method, "application/json")
+		buf, _ := spec.JSONMarshalIndent(mock.Example(method.BodyParam.Resource))
+		r.body = string(buf)
+	case len(method.FormParams) > 0:
+		r.form = params(method.FormParams)
+		r.contentType = "application/x-www-form-urlencoded"
+		for _, p := range r.form {
+			if p.file {
+				r.contentType = "multipart/form-data"
+			}
+		}
+		r.contentType = consumed(method, r.contentType)
+	}
+	// The multipart boundary is chosen by whatever sends the request
+	if len(r.contentType) > 0 && r.contentType != "multipart/form-data" {
+		r.headers = append(r.headers, param{name: "Content-Type", value: r.contentType, required: true})
+	}
+	return r
+}
+
+func params(list []spec.Parameter) []param {
+	var ps []param
+	for _, p := range list {
+		ps = append(ps, param{
+			name:        p.Name,
+			value:       mock.ParameterExample(p),
+			description: p.Description,
+			required:    p.Required,
+			file:        len(p.Type) > 0 && p.Type[len(p.Type)-1] == "file",
+		})
+	}
+	return ps
+}
+
+// consumed returns the type the operation consumes that is most like the preferred
+// type, or the preferred type if it documents none.
+func consumed(method *spec.Method, preferred string) string {
+	for _, t := range method.Consumes {
+		if t == preferred {
+			return t
+		}
+	}
+	for _, t := range method.Consumes {
+		if strings.HasPrefix(preferred, "application/json") && strings.HasSuffix(t, "+json") {
+			return t
+		}
+	}
+	return preferred
+}
+
+// authOf returns the first, by name, of the security schemes an operation accepts, or
+// nil if it needs none.
+func authOf(method *spec.Method) *auth {
+	var names []string
+	for name, s := range method.Security {
+		if s.Scheme != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	s := method.Security[names[0]]
+	a := &auth{name: names[0], scheme: s.Scheme}
+	for scope := range s.Scopes {
+		a.scopes = append(a.scopes, scope)
+	}
+	sort.Strings(a.scopes)
+	return a
+}
+
+// ----------------------------------------------------------------------------------------
+// url returns the request's URL, with its path parameters replaced by replace, but
+// without its query.
+func (r *request) url(baseURL string, replace func(p param) string) string {
+	path := paramRegex.ReplaceAllStringFunc(r.path, func(s string) string {
+		name := s[1 : len(s)-1]
+		for _, p := range r.pathParams {
+			if p.name == name {
+				return replace(p)
+			}
+		}
+		return s
+	})
+	return baseURL + path
+}
+
+// placeholder returns a value for the reader to fill in, such as <api key>.
+func placeholder(s string) string {
+	return "<" + s + ">"
+}
+
+// ----------------------------------------------------------------------------------------
+// end
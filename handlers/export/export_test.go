@@ -0,0 +1,89 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/spec"
+)
+
+func TestRequestURL(t *testing.T) {
+	escape := func(p param) string { return url.PathEscape(p.value) }
+	variable := func(p param) string { return ":" + p.name }
+
+	tests := []struct {
+		name    string
+		request request
+		baseURL string
+		replace func(p param) string
+		url     string
+	}{
+		{"no parameters", request{path: "/pets"}, "https://petstore.example.com/v2", escape, "https://petstore.example.com/v2/pets"},
+		{"parameter", request{path: "/pets/{id}", pathParams: []param{{name: "id", value: "42"}}}, "http://localhost", escape, "http://localhost/pets/42"},
+		{"escaped", request{path: "/files/{name}", pathParams: []param{{name: "name", value: "a b/c"}}}, "", escape, "/files/a%20b%2Fc"},
+		{"variables", request{path: "/owners/{owner}/pets/{id}", pathParams: []param{{name: "owner"}, {name: "id"}}}, "", variable, "/owners/:owner/pets/:id"},
+		{"undocumented parameter", request{path: "/pets/{id}"}, "", escape, "/pets/{id}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if u := test.request.url(test.baseURL, test.replace); u != test.url {
+				t.Errorf("got %s, want %s", u, test.url)
+			}
+		})
+	}
+}
+
+// The base URL of an environment includes the basePath, so operation paths must not.
+func TestNewExportBasePath(t *testing.T) {
+	host, _ := url.Parse("https://petstore.swagger.io")
+	specification := &spec.APISpecification{
+		ID:           "petstore",
+		BasePath:     "/v2",
+		Environments: []config.Environment{{Name: "Production", URL: "https://petstore.example.com/v2/"}},
+		APIs: spec.APISet{{
+			ID:      "pets",
+			URL:     host,
+			Methods: []spec.Method{{ID: "get-pet", Method: "get", Path: "/v2/pets/{id}"}},
+		}},
+	}
+
+	tests := []struct {
+		environment string
+		url         string
+	}{
+		{"", "https://petstore.swagger.io/v2/pets/{id}"},
+		{"Production", "https://petstore.example.com/v2/pets/{id}"},
+	}
+	for _, test := range tests {
+		e, ok := newExport(specification, test.environment)
+		if !ok {
+			t.Fatalf("environment %q not found", test.environment)
+		}
+		r := e.groups[0].requests[0]
+		if u := r.url(e.baseURL, func(p param) string { return "{" + p.name + "}" }); u != test.url {
+			t.Errorf("environment %q: got %s, want %s", test.environment, u, test.url)
+		}
+	}
+
+	if _, ok := newExport(specification, "Staging"); ok {
+		t.Errorf("got an export for an unknown environment")
+	}
+}
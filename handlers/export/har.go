@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------------
+// har returns a HAR 1.2 log with an entry for each operation: a skeleton whose request
+// is filled in with example values and placeholder credentials, and whose response is
+// empty.
+func har(e *export) interface{} {
+	entries := []object{}
+	for _, g := range e.groups {
+		for _, r := range g.requests {
+			entries = append(entries, object{
+				"startedDateTime": "1970-01-01T00:00:00Z",
+				"time":            0,
+				"comment":         g.name + ": " + r.name,
+				"request":         harRequest(e, &r),
+				"response": object{
+					"status":      0,
+					"statusText":  "",
+					"httpVersion": "HTTP/1.1",
+					"cookies":     []object{},
+					"headers":     []object{},
+					"content":     object{"size": 0, "mimeType": ""},
+					"redirectURL": "",
+					"headersSize": -1,
+					"bodySize":    -1,
+				},
+				"cache":   object{},
+				"timings": object{"send": 0, "wait": 0, "receive": 0},
+			})
+		}
+	}
+
+	return object{
+		"log": object{
+			"version": "1.2",
+			"creator": object{"name": "DapperDox", "version": ""},
+			"comment": e.specification.APIInfo.Title,
+			"entries": entries,
+		},
+	}
+}
+
+// harRequest returns a request with its required query parameters and headers, and
+// its credentials as placeholders.
+func harRequest(e *export, r *request) object {
+	headers := []object{}
+	for _, p := range r.headers {
+		if p.required {
+			headers = append(headers, object{"name": p.name, "value": p.value})
+		}
+	}
+	query := []object{}
+	for _, p := range r.query {
+		if p.required {
+			query = append(query, object{"name": p.name, "value": p.value})
+		}
+	}
+
+	if a := r.auth; a != nil {
+		s := a.scheme
+		switch {
+		case s.IsApiKey && s.ParamLocation == "query":
+			query = append(query, object{"name": s.ParamName, "value": placeholder("api key")})
+		case s.IsApiKey:
+			headers = append(headers, object{"name": s.ParamName, "value": placeholder("api key")})
+		case s.IsBasic:
+			headers = append(headers, object{"name": "Authorization", "value": "Basic " + placeholder("credentials")})
+		case s.IsOAuth2:
+			headers = append(headers, object{"name": "Authorization", "value": "Bearer " + placeholder("access token")})
+		}
+	}
+
+	target := r.url(e.baseURL, func(p param) string { return url.PathEscape(p.value) })
+	var values []string
+	for _, q := range query {
+		values = append(values, url.QueryEscape(q["name"].(string))+"="+url.QueryEscape(q["value"].(string)))
+	}
+	if len(values) > 0 {
+		target += "?" + strings.Join(values, "&")
+	}
+
+	request := object{
+		"method":      r.method,
+		"url":         target,
+		"httpVersion": "HTTP/1.1",
+		"cookies":     []object{},
+		"headers":     headers,
+		"queryString": query,
+		"headersSize": -1,
+		"bodySize":    -1,
+	}
+	switch {
+	case len(r.body) > 0:
+		request["postData"] = object{"mimeType": r.contentType, "text": r.body}
+		request["bodySize"] = len(r.body)
+	case len(r.form) > 0:
+		var params []object
+		for _, p := range r.form {
+			if p.file {
+				params = append(params, object{"name": p.name, "fileName": "", "contentType": "application/octet-stream"})
+			} else {
+				params = append(params, object{"name": p.name, "value": p.value})
+			}
+		}
+		request["postData"] = object{"mimeType": r.contentType, "params": params}
+	}
+	return request
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Insomnia grant types, by OpenAPI 2.0 flow
+var insomniaGrantTypes = map[string]string{
+	"implicit":    "implicit",
+	"password":    "password",
+	"application": "client_credentials",
+	"accessCode":  "authorization_code",
+}
+
+// ----------------------------------------------------------------------------------------
+// insomnia returns an Insomnia v4 export: a workspace with a folder for each API. The
+// base URL and credentials are environment variables, and path parameters are filled
+// in with example values.
+func insomnia(e *export) interface{} {
+	workspace := "wrk_" + e.specification.ID
+	environment := "env_" + e.specification.ID
+	data := object{"base_url": e.baseURL}
+
+	resources := []object{
+		{"_id": workspace, "_type": "workspace", "name": e.specification.APIInfo.Title, "description": e.specification.APIInfo.Description, "scope": "collection"},
+	}
+	envName := "Base Environment"
+	if len(e.environment) > 0 {
+		envName = e.environment
+	}
+	resources = append(resources, object{"_id": environment, "_type": "environment", "parentId": workspace, "name": envName, "data": data})
+
+	for i, g := range e.groups {
+		folder := fmt.Sprintf("fld_%s_%d", e.specification.ID, i)
+		resources = append(resources, object{"_id": folder, "_type": "request_group", "parentId": workspace, "name": g.name})
+
+		for j, r := range g.requests {
+			request := object{
+				"_id":            fmt.Sprintf("req_%s_%d_%d", e.specification.ID, i, j),
+				"_type":          "request",
+				"parentId":       folder,
+				"name":           r.name,
+				"description":    r.description,
+				"method":         r.method,
+				"url":            r.url("{{ _.base_url }}", func(p param) string { return p.value }),
+				"parameters":     insomniaParams(r.query),
+				"headers":        insomniaParams(r.headers),
+				"body":           insomniaBody(&r),
+				"authentication": insomniaAuth(r.auth, data),
+			}
+			resources = append(resources, request)
+		}
+	}
+
+	return object{
+		"_type":           "export",
+		"__export_format": 4,
+		"__export_date":   time.Now().UTC().Format(time.RFC3339),
+		"__export_source": "dapperdox",
+		"resources":       resources,
+	}
+}
+
+// insomniaParams returns query parameters or headers. Those that are optional are
+// disabled.
+func insomniaParams(params []param) []object {
+	list := []object{}
+	for _, p := range params {
+		list = append(list, object{"name": p.name, "value": p.value, "description": p.description, "disabled": !p.required})
+	}
+	return list
+}
+
+func insomniaBody(r *request) object {
+	switch {
+	case len(r.body) > 0:
+		return object{"mimeType": r.contentType, "text": r.body}
+	case len(r.form) > 0:
+		var params []object
+		for _, p := range r.form {
+			if p.file {
+				params = append(params, object{"name": p.name, "type": "file", "fileName": "", "description": p.description})
+			} else {
+				params = append(params, object{"name": p.name, "value": p.value, "description": p.description})
+			}
+		}
+		return object{"mimeType": r.contentType, "params": params}
+	}
+	return object{}
+}
+
+// insomniaAuth returns a request's authentication, adding the environment variables it
+// uses to data.
+func insomniaAuth(a *auth, data object) object {
+	if a == nil {
+		return object{}
+	}
+	s := a.scheme
+	switch {
+	case s.IsApiKey:
+		data["api_key"] = ""
+		addTo := "header"
+		if s.ParamLocation == "query" {
+			addTo = "queryParams"
+		}
+		return object{"type": "apikey", "key": s.ParamName, "value": "{{ _.api_key }}", "addTo": addTo}
+	case s.IsBasic:
+		data["username"], data["password"] = "", ""
+		return object{"type": "basic", "username": "{{ _.username }}", "password": "{{ _.password }}"}
+	case s.IsOAuth2:
+		return object{
+			"type":             "oauth2",
+			"grantType":        insomniaGrantTypes[s.OAuth2Flow],
+			"authorizationUrl": s.AuthorizationUrl,
+			"accessTokenUrl":   s.TokenUrl,
+			"scope":            strings.Join(a.scopes, " "),
+		}
+	}
+	return object{}
+}
+
+// ----------------------------------------------------------------------------------------
+// end
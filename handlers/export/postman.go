@@ -0,0 +1,185 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+import (
+	"net/url"
+	"strings"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// Postman grant types, by OpenAPI 2.0 flow
+var postmanGrantTypes = map[string]string{
+	"implicit":    "implicit",
+	"password":    "password_credentials",
+	"application": "client_credentials",
+	"accessCode":  "authorization_code",
+}
+
+type object map[string]interface{}
+
+// ----------------------------------------------------------------------------------------
+// postman returns a Postman v2.1 collection, with a folder for each API. The base URL
+// and credentials are collection variables.
+func postman(e *export) interface{} {
+	variables := []object{{"key": "baseUrl", "value": e.baseURL}}
+	seen := make(map[string]bool)
+
+	var folders []object
+	for _, g := range e.groups {
+		var items []object
+		for _, r := range g.requests {
+			item := object{
+				"name": r.name,
+				"request": object{
+					"method":      r.method,
+					"header":      postmanParams(r.headers),
+					"url":         postmanURL(&r),
+					"description": r.description,
+				},
+				"response": []object{},
+			}
+			request := item["request"].(object)
+			if body := postmanBody(&r); body != nil {
+				request["body"] = body
+			}
+			auth, names := postmanAuth(r.auth)
+			request["auth"] = auth
+			for _, name := range names {
+				if !seen[name] {
+					seen[name] = true
+					variables = append(variables, object{"key": name, "value": ""})
+				}
+			}
+			items = append(items, item)
+		}
+		folders = append(folders, object{"name": g.name, "item": items})
+	}
+
+	return object{
+		"info": object{
+			"_postman_id": e.specification.ID,
+			"name":        e.specification.APIInfo.Title,
+			"description": e.specification.APIInfo.Description,
+			"schema":      postmanSchema,
+		},
+		"item":     folders,
+		"variable": variables,
+	}
+}
+
+// postmanURL returns a request URL, with :name path variables.
+func postmanURL(r *request) object {
+	path := r.url("", func(p param) string { return ":" + p.name })
+
+	variables := []object{}
+	for _, p := range r.pathParams {
+		variables = append(variables, object{"key": p.name, "value": p.value, "description": p.description})
+	}
+
+	raw := "{{baseUrl}}" + path
+	var query []string
+	for _, p := range r.query {
+		if p.required {
+			query = append(query, url.QueryEscape(p.name)+"="+url.QueryEscape(p.value))
+		}
+	}
+	if len(query) > 0 {
+		raw += "?" + strings.Join(query, "&")
+	}
+
+	return object{
+		"raw":      raw,
+		"host":     []string{"{{baseUrl}}"},
+		"path":     strings.Split(strings.TrimPrefix(path, "/"), "/"),
+		"query":    postmanParams(r.query),
+		"variable": variables,
+	}
+}
+
+// postmanParams returns query parameters or headers. Those that are optional are
+// disabled.
+func postmanParams(params []param) []object {
+	list := []object{}
+	for _, p := range params {
+		list = append(list, object{"key": p.name, "value": p.value, "description": p.description, "disabled": !p.required})
+	}
+	return list
+}
+
+func postmanBody(r *request) object {
+	switch {
+	case len(r.body) > 0:
+		return object{"mode": "raw", "raw": r.body, "options": object{"raw": object{"language": "json"}}}
+	case r.contentType == "multipart/form-data":
+		var data []object
+		for _, p := range r.form {
+			if p.file {
+				data = append(data, object{"key": p.name, "type": "file", "src": []string{}, "description": p.description})
+			} else {
+				data = append(data, object{"key": p.name, "value": p.value, "type": "text", "description": p.description})
+			}
+		}
+		return object{"mode": "formdata", "formdata": data}
+	case len(r.form) > 0:
+		var data []object
+		for _, p := range r.form {
+			data = append(data, object{"key": p.name, "value": p.value, "description": p.description})
+		}
+		return object{"mode": "urlencoded", "urlencoded": data}
+	}
+	return nil
+}
+
+// postmanAuth returns a request's auth, and the collection variables it uses.
+func postmanAuth(a *auth) (object, []string) {
+	if a == nil {
+		return object{"type": "noauth"}, nil
+	}
+	s := a.scheme
+	switch {
+	case s.IsApiKey:
+		in := "header"
+		if s.ParamLocation == "query" {
+			in = "query"
+		}
+		return object{"type": "apikey", "apikey": []object{
+			{"key": "key", "value": s.ParamName, "type": "string"},
+			{"key": "value", "value": "{{apiKey}}", "type": "string"},
+			{"key": "in", "value": in, "type": "string"},
+		}}, []string{"apiKey"}
+	case s.IsBasic:
+		return object{"type": "basic", "basic": []object{
+			{"key": "username", "value": "{{username}}", "type": "string"},
+			{"key": "password", "value": "{{password}}", "type": "string"},
+		}}, []string{"username", "password"}
+	case s.IsOAuth2:
+		return object{"type": "oauth2", "oauth2": []object{
+			{"key": "grant_type", "value": postmanGrantTypes[s.OAuth2Flow], "type": "string"},
+			{"key": "authUrl", "value": s.AuthorizationUrl, "type": "string"},
+			{"key": "accessTokenUrl", "value": s.TokenUrl, "type": "string"},
+			{"key": "scope", "value": strings.Join(a.scopes, " "), "type": "string"},
+			{"key": "addTokenTo", "value": "header", "type": "string"},
+		}}, nil
+	}
+	return object{"type": "noauth"}, nil
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package export
+
+// Generated SDKs are served beneath /<specification-id>/sdk/<language>/, as archives at
+// <version>.zip, and as source pages at <version>/<file>, which method pages link to
+// the function lines of.
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/sdk"
+	"github.com/wix/dapperdox/security"
+	"github.com/wix/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// ----------------------------------------------------------------------------------------
+// registerSDKs creates a route for the generated SDKs of each specification.
+func registerSDKs(r *pat.Router) {
+	for _, specification := range spec.APISuite {
+		if len(sdk.Downloads(specification.ID)) == 0 {
+			continue
+		}
+		prefix := "/" + specification.ID + "/sdk/"
+		logger.Debugf(nil, "+ %s", prefix)
+
+		security.RoutePrefix(security.Specs, prefix)
+		r.PathPrefix(prefix).Methods("GET").HandlerFunc(sdkHandler(specification, prefix))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+
+func sdkHandler(specification *spec.APISpecification, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		notFound := func() {
+			render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, render.Vars{"error": "Page not found", "code": 404}))
+		}
+		if !specification.Published() && !publish.Preview(req) {
+			notFound()
+			return
+		}
+
+		// <language>/<version>.zip, or <language>/<version>[/<file>]
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, prefix), "/", 3)
+		if len(parts) < 2 {
+			notFound()
+			return
+		}
+		language, version := parts[0], parts[1]
+
+		if len(parts) == 2 && strings.HasSuffix(version, ".zip") {
+			version = strings.TrimSuffix(version, ".zip")
+			buf, ok := sdk.Archive(specification.ID, language, version)
+			if !ok {
+				notFound()
+				return
+			}
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+sdk.ArchiveName(specification.ID, language, version)+`"`)
+			w.Write(buf)
+			return
+		}
+
+		// Only the current version's sources are kept
+		g := sdk.Get(specification.ID, language)
+		if g == nil || g.Version != version {
+			notFound()
+			return
+		}
+		file := ""
+		if len(parts) == 3 {
+			file = parts[2]
+		}
+		if len(file) == 0 && len(g.Files) > 0 {
+			file = g.Files[0]
+		}
+		source, ok := g.Source(file)
+		if !ok {
+			notFound()
+			return
+		}
+		render.HTML(w, http.StatusOK, "sdk_source", render.DefaultVars(req, specification, render.Vars{"Title": language + " SDK", "SDK": g, "File": file, "Lines": strings.Split(string(source), "\n")}))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// end
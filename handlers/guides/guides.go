@@ -19,18 +19,22 @@ package guides
 
 import (
 	//"github.com/davecgh/go-spew/spew"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	"github.com/wix/dapperdox/i18n"
 	"github.com/wix/dapperdox/logger"
 	"github.com/wix/dapperdox/navigation"
+	"github.com/wix/dapperdox/publish"
 	"github.com/wix/dapperdox/render"
 	"github.com/wix/dapperdox/render/asset"
 	"github.com/wix/dapperdox/spec"
 	"github.com/gorilla/pat"
+	"gopkg.in/yaml.v2"
 )
 
 // ---------------------------------------------------------------------------
@@ -53,6 +57,10 @@ func Register(r *pat.Router) {
 }
 
 // ---------------------------------------------------------------------------
+// register creates the routes and navigation of a set of guides. A guide in another
+// locale is served at the same route as the default locale guide it translates, and
+// takes its place in that locale's navigation. Guides only written in some locales
+// are only listed in them.
 func register(r *pat.Router, base string, specification *spec.APISpecification) {
 
 	root_node := "/guides"
@@ -62,105 +70,242 @@ func register(r *pat.Router, base string, specification *spec.APISpecification)
 		route_base = "/" + specification.ID + route_base
 	}
 
-	path_base := base + root_node
-
-	guidesNavigation := &navigation.NavigationNode{}
+	// Locale->Directory of the guides assets
+	path_bases := make(map[string]string)
+	for _, locale := range i18n.Locales() {
+		path_bases[locale] = base + "/" + i18n.AssetName(strings.TrimPrefix(root_node, "/"), locale)
+	}
 
-	guidesNavigation.Children = make([]*navigation.NavigationNode, 0)
-	guidesNavigation.ChildMap = make(map[string]*navigation.NavigationNode)
+	// Route->Locale->Guide asset
+	pages := make(map[string]map[string]string)
+	var routes []string
 
-	logger.Tracef(nil, "  - Walk compiled asset tree %s", path_base)
+	for _, locale := range i18n.Locales() {
+		path_base := path_bases[locale]
+		logger.Tracef(nil, "  - Walk compiled asset tree %s", path_base)
 
-	for _, path := range asset.AssetNames() {
-		if !strings.HasPrefix(path, path_base) { // Only keep assets we want
-			continue
+		for _, path := range asset.AssetNames() {
+			if !strings.HasPrefix(path, path_base+"/") { // Only keep assets we want
+				continue
+			}
+			switch filepath.Ext(path) {
+			case ".tmpl", ".md":
+				logger.Debugf(nil, "    - File "+path)
+
+				// Convert path/filename to route
+				route := route_base + StripBasepathAndExtension(path, path_base)
+				if _, ok := pages[route]; !ok {
+					pages[route] = make(map[string]string)
+					routes = append(routes, route)
+				}
+				pages[route][locale] = path
+			}
 		}
-		ext := filepath.Ext(path)
-
-		switch ext {
-		case ".tmpl", ".md":
-			logger.Debugf(nil, "    - File "+path)
-
-			// Convert path/filename to route
-			route := route_base + StripBasepathAndExtension(path, path_base)
-			absresource := StripBasepathAndExtension(path, base)
-			resource := strings.TrimPrefix(absresource, "/")
+	}
+	sort.Strings(routes)
 
-			buildNavigation(guidesNavigation, path, path_base, route, ext)
+	for _, route := range routes {
+		r.Path(route).Methods("GET").HandlerFunc(guideHandler(base, specification, pages[route]))
 
-			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-				sid := "TOP LEVEL"
-				if specification != nil {
-					sid = specification.ID
+		// Redirect from the guide's previous URLs, given by its redirect-from metadata
+		for _, path := range pages[route] {
+			if metadata := asset.Guide(path); metadata != nil {
+				for _, from := range metadata.RedirectFrom {
+					logger.Tracef(nil, "    + Redirect %s -> %s", from, route)
+					r.Path(from).Methods("GET").HandlerFunc(redirectHandler(route))
 				}
-				logger.Tracef(nil, "Fetching guide from '%s' for spec ID %s\n", resource, sid)
-				render.HTML(w, http.StatusOK, resource, render.DefaultVars(req, specification, render.Vars{"Guide": resource}))
-			})
+			}
 		}
 	}
 
-	sortNavigation(guidesNavigation)
+	// Build the navigation of each locale, from its own guides and those of the default
+	firstGuide := make(map[string]string)
+	for _, locale := range i18n.Locales() {
+		guidesNavigation := &navigation.NavigationNode{}
+
+		guidesNavigation.Children = make([]*navigation.NavigationNode, 0)
+		guidesNavigation.ChildMap = make(map[string]*navigation.NavigationNode)
+
+		for _, route := range routes {
+			guideLocale := locale
+			path, ok := pages[route][guideLocale]
+			if !ok {
+				guideLocale = i18n.Default()
+				if path, ok = pages[route][guideLocale]; !ok {
+					continue
+				}
+			}
+			uri := route
+			if locale != i18n.Default() {
+				uri = "/" + locale + route
+			}
+			buildNavigation(guidesNavigation, path, path_bases[guideLocale], uri, filepath.Ext(path))
+		}
+
+		applyIndexFiles(guidesNavigation, path_bases[i18n.Default()])
+		if locale != i18n.Default() {
+			applyIndexFiles(guidesNavigation, path_bases[locale])
+		}
+		sortNavigation(guidesNavigation)
+		firstGuide[locale] = findFirstGuideUri(guidesNavigation)
+
+		// Register the guides navigation with the renderer
+		render.SetGuidesNavigation(specification, locale, &guidesNavigation.Children)
+	}
 
 	// Register default route for this guide set
 	r.Path(route_base).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		uri := findFirstGuideUri(guidesNavigation)
-		logger.Infof(nil, "Redirect to %s\n", uri)
+		uri := firstGuide[i18n.Locale(req)]
+		logger.Tracef(req, "Redirect to %s\n", uri)
 		http.Redirect(w, req, uri, 302)
 	})
+}
+
+// ---------------------------------------------------------------------------
+// guideHandler serves a guide in the locale of the request, falling back to the
+// default locale, then to the first locale it is written in.
+func guideHandler(base string, specification *spec.APISpecification, paths map[string]string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		path, ok := paths[i18n.Locale(req)]
+		if !ok {
+			path, ok = paths[i18n.Default()]
+		}
+		for _, locale := range i18n.Locales() {
+			if !ok {
+				path, ok = paths[locale]
+			}
+		}
+		metadata := asset.Guide(path)
+
+		if !ok || (metadata != nil && metadata.Draft && !publish.Preview(req)) {
+			logger.Tracef(req, "Not serving draft guide '%s' outside of preview mode", req.URL.Path)
+			render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, render.Vars{"error": "Page not found", "code": 404}))
+			return
+		}
 
-	// Register the guides navigation with the renderer
-	render.SetGuidesNavigation(specification, &guidesNavigation.Children)
+		sid := "TOP LEVEL"
+		if specification != nil {
+			sid = specification.ID
+		}
+		resource := strings.TrimPrefix(StripBasepathAndExtension(path, base), "/")
+		logger.Tracef(nil, "Fetching guide from '%s' for spec ID %s\n", resource, sid)
+		render.HTML(w, http.StatusOK, resource, render.DefaultVars(req, specification, render.Vars{"Guide": resource, "GuideMetadata": metadata, "Unpublished": metadata != nil && metadata.Draft}))
+	}
 }
 
 // ---------------------------------------------------------------------------
 
+func redirectHandler(to string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, to, http.StatusMovedPermanently)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// findFirstGuideUri returns the first page of the tree in navigation order that is
+// not a draft. It must be called after the tree has been sorted.
 func findFirstGuideUri(tree *navigation.NavigationNode) string {
-	var uri string
-	for i := range tree.Children {
-		node := tree.Children[i]
-		uri = node.Uri
-		logger.Infof(nil, "Redirect 1 uri %s with index %s\n", uri, i)
-		if uri == "" {
-			if len(node.Children) > 0 {
-				uri = findFirstGuideUri(node)
-				logger.Infof(nil, "Redirect 2 uri %s with index %s\n", uri, i)
-			}
+	for _, node := range tree.Children {
+		if node.Uri != "" && !node.Draft {
+			return node.Uri
 		}
-		logger.Infof(nil, "Redirect 3 uri %s with index %s\n", uri, i)
-		if uri != "" {
-			break
+		if uri := findFirstGuideUri(node); uri != "" {
+			return uri
 		}
 	}
-	logger.Infof(nil, "Redirect 4 uri %s \n", uri)
-	return uri
+	return ""
 }
 
 // ---------------------------------------------------------------------------
+// sortNavigation orders every level of the tree, dropping hidden nodes.
 func sortNavigation(tree *navigation.NavigationNode) {
 
-	for i := range tree.Children {
-		node := tree.Children[i]
-
-		if len(node.Children) > 0 {
-			sort.Sort(navigation.ByOrder(node.Children))
+	children := make([]*navigation.NavigationNode, 0, len(tree.Children))
+	for _, node := range tree.Children {
+		if node.Hidden {
+			continue
 		}
+		sortNavigation(node)
+		children = append(children, node)
 	}
-	sort.Sort(navigation.ByOrder(tree.Children))
+	sort.Sort(navigation.ByOrder(children))
+	tree.Children = children
 }
 
 // ---------------------------------------------------------------------------
-func dumpit(tree *navigation.NavigationNode) {
-	for i := range tree.Children {
-		node := tree.Children[i]
-
-		logger.Tracef(nil, "Sorted name = %s\n", node.Name)
-		for j := range node.Children {
-			node2 := node.Children[j]
-			logger.Tracef(nil, "       name = %s\n", node2.Name)
+// IndexFile is the name of the optional file in a guides directory that sets the
+// title, sort order and visibility of its navigation branch. The order is compared
+// with the SortOrder metadata of sibling pages, which defaults to their route:
+//
+//	title: Getting started
+//	order: 10
+//	collapsed: false
+//	hidden: false
+const IndexFile = "_index.yaml"
+
+type directoryIndex struct {
+	Title     string      `yaml:"title"`
+	Order     interface{} `yaml:"order"`
+	Collapsed *bool       `yaml:"collapsed"`
+	Hidden    bool        `yaml:"hidden"`
+}
+
+// ---------------------------------------------------------------------------
+// applyIndexFiles applies the index file of each directory under path_base to the
+// branch node of the same path. A directory's branch is matched by node ID, so it
+// also applies when the hierarchy comes from Navigation metadata.
+func applyIndexFiles(nav *navigation.NavigationNode, path_base string) {
+
+	for _, path := range asset.AssetNames() {
+		if !strings.HasPrefix(path, path_base+"/") || filepath.Base(path) != IndexFile {
+			continue
+		}
+		dir := strings.TrimPrefix(filepath.Dir(path), path_base+"/")
+		if dir == filepath.Dir(path) {
+			continue // The index of the guides root has no branch to apply to
+		}
+
+		var index directoryIndex
+		buf, err := asset.Asset(path)
+		if err == nil {
+			err = yaml.Unmarshal(buf, &index)
+		}
+		if err != nil {
+			logger.Errorf(nil, "Error: Guide index file %s: %s", path, err)
+			os.Exit(1)
+		}
+
+		node := nav
+		for _, name := range strings.Split(dir, "/") {
+			if node = node.ChildMap[navigationId(name)]; node == nil {
+				break
+			}
+		}
+		if node == nil {
+			logger.Warnf(nil, "Guide index file %s does not match a navigation branch", path)
+			continue
+		}
+
+		logger.Tracef(nil, "      * Applying index file %s\n", path)
+		if len(index.Title) > 0 {
+			node.Name = index.Title
+		}
+		if index.Order != nil {
+			node.SortOrder = fmt.Sprint(index.Order)
 		}
+		if index.Collapsed != nil {
+			node.Collapsed = *index.Collapsed
+		}
+		node.Hidden = index.Hidden
 	}
 }
 
+// ---------------------------------------------------------------------------
+
+func navigationId(name string) string {
+	return strings.Replace(strings.ToLower(name), " ", "-", -1)
+}
+
 // ---------------------------------------------------------------------------
 func StripBasepathAndExtension(name string, basepath string) string {
 	// Strip base path and file extension
@@ -190,67 +335,61 @@ func buildNavigation(nav *navigation.NavigationNode, path string, path_base stri
 	split := strings.Split(hierarchy, "/")
 	parts := len(split)
 
-	if parts > 2 {
-		logger.Errorf(nil, "Error: Guide '"+hierarchy+"' contains too many nagivation levels (%d)", parts)
-		os.Exit(1)
-	}
-
 	if sortOrder == "" {
 		sortOrder = route
 	}
 
 	current := nav.ChildMap
 	currentList := &nav.Children
+	parentId := ""
 
 	// Build tree for this navigation item
 	for i := range split {
 
 		name := split[i]
-		id := strings.Replace(strings.ToLower(name), " ", "-", -1)
+		id := navigationId(name)
 
-		if i < parts-1 {
-			// Have we already created this branch node?
-			if currentItem, ok := current[id]; !ok {
-				// create new branch node
-				current[id] = &navigation.NavigationNode{
-					Id:        id,
-					SortOrder: sortOrder,
-					Name:      name,
-					ChildMap:  make(map[string]*navigation.NavigationNode),
-					Children:  make([]*navigation.NavigationNode, 0),
-				}
-				*currentList = append(*currentList, current[id])
-				logger.Tracef(nil, "      + Adding %s = %s to branch\n", id, current[id].Name)
-			} else {
-				// Update the branch node sort order, if the leaf has a lower sort
-				if sortOrder < currentItem.SortOrder {
-					currentItem.SortOrder = sortOrder
-				}
+		// Node Ids are prefixed by their parent's, to be unique across the whole tree
+		nodeId := id
+		if parentId != "" {
+			nodeId = parentId + "-" + id
+		}
+
+		currentItem, ok := current[id]
+		if !ok {
+			currentItem = &navigation.NavigationNode{
+				Id:        nodeId,
+				SortOrder: sortOrder,
+				Name:      name,
+				ChildMap:  make(map[string]*navigation.NavigationNode),
+				Children:  make([]*navigation.NavigationNode, 0),
 			}
-			// Step down branch
-			currentList = &current[id].Children // Get parent list before stepping into child
+			current[id] = currentItem
+			*currentList = append(*currentList, currentItem)
+			logger.Tracef(nil, "      + Adding %s = %s\n", nodeId, name)
+		} else if navigation.NaturalLess(sortOrder, currentItem.SortOrder) {
+			// Update the node sort order, if this page has a lower sort
+			currentItem.SortOrder = sortOrder
+		}
 
-			current = current[id].ChildMap
+		if i < parts-1 {
+			// Branch node, collapsed unless an index file says otherwise
+			currentItem.Collapsed = true
+
+			// Step down branch
+			currentList = &currentItem.Children
+			current = currentItem.ChildMap
+			parentId = nodeId
 		} else {
-			// Leaf node
-			if currentItem, ok := current[id]; !ok {
-				current[id] = &navigation.NavigationNode{
-					Id:        id,
-					SortOrder: sortOrder,
-					Uri:       route,
-					Name:      name,
-					ChildMap:  make(map[string]*navigation.NavigationNode),
-					Children:  make([]*navigation.NavigationNode, 0),
-				}
-				*currentList = append(*currentList, current[id])
-			} else {
-				// The page is a leaf node, but sits at a branch node. This means that the branch
-				// node has content! Set the uri, and adjust the sort order, if necessary.
-				currentItem.Uri = route
-				if sortOrder < currentItem.SortOrder {
-					currentItem.SortOrder = sortOrder
+			// Leaf node. If it already exists as a branch then the branch node has content.
+			currentItem.Uri = route
+			if m := asset.Guide(path); m != nil {
+				currentItem.Metadata = m.Fields
+				if len(m.Title) > 0 {
+					currentItem.Name = m.Title
 				}
-				logger.Tracef(nil, "      + Adding %s = %s to leaf node [b] Sort %s\n", currentItem.Uri, currentItem.Name, sortOrder)
+				currentItem.Draft = m.Draft
+				currentItem.Status = m.Status
 			}
 		}
 	}
@@ -0,0 +1,132 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wix/dapperdox/spec"
+	"github.com/wix/dapperdox/validate"
+)
+
+// ----------------------------------------------------------------------------------------
+// Example returns an example value of a resource: its documented example, or else one
+// built from the examples, enumerations and types of its properties.
+func Example(resource *spec.Resource) interface{} {
+	if resource == nil || len(resource.Type) == 0 {
+		return nil
+	}
+	if len(resource.Example) > 0 {
+		var value interface{}
+		if err := json.Unmarshal([]byte(resource.Example), &value); err == nil {
+			return value
+		}
+	}
+
+	switch resource.Type[0] {
+	case "object":
+		return object(resource)
+	case "array":
+		return []interface{}{element(resource)}
+	case "map":
+		return map[string]interface{}{"key": element(resource)}
+	}
+	if len(resource.Enum) > 0 {
+		return typed(resource.Type[0], resource.Enum[0])
+	}
+	return primitiveExample(resource.Type[0])
+}
+
+// ----------------------------------------------------------------------------------------
+// ParameterExample returns an example value of a parameter, as written in a path, query
+// or header: its first enumerated value, or else one of its type.
+func ParameterExample(p spec.Parameter) string {
+	switch {
+	case len(p.Enum) > 0:
+		return p.Enum[0]
+	case len(p.Type) > 0:
+		return fmt.Sprint(primitiveExample(p.Type[len(p.Type)-1]))
+	}
+	return "string"
+}
+
+// element returns an example member of an array or map resource.
+func element(resource *spec.Resource) interface{} {
+	if len(resource.Type) > 1 && resource.Type[1] != "object" {
+		if len(resource.Enum) > 0 {
+			return typed(resource.Type[1], resource.Enum[0])
+		}
+		return primitiveExample(resource.Type[1])
+	}
+	return object(resource)
+}
+
+func object(resource *spec.Resource) map[string]interface{} {
+	value := make(map[string]interface{})
+	for name, property := range resource.Properties {
+		if name == "<key>" {
+			name = "key"
+		}
+		value[name] = Example(property)
+	}
+	return value
+}
+
+// typed converts an enumerated value, which is held as a string, to its type.
+func typed(t string, s string) interface{} {
+	var value interface{}
+	switch validate.BaseType(t) {
+	case "integer", "number", "boolean":
+		if err := json.Unmarshal([]byte(s), &value); err == nil {
+			return value
+		}
+	}
+	return s
+}
+
+// ----------------------------------------------------------------------------------------
+// primitiveExample returns an example value of a primitive type or format.
+func primitiveExample(t string) interface{} {
+	switch t {
+	case "date-time":
+		return "2017-01-01T12:00:00Z"
+	case "date":
+		return "2017-01-01"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "byte":
+		return "ZXhhbXBsZQ=="
+	}
+	switch validate.BaseType(t) {
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return true
+	}
+	return "string"
+}
+
+// ----------------------------------------------------------------------------------------
+// end
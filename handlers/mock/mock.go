@@ -0,0 +1,258 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+// The mock serves every operation of the loaded specifications beneath mock-path. It
+// validates each request against its operation, then answers with the documented
+// response for the chosen status, whose body is its promoted example (see record) or
+// else is built from the response resource.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/record"
+	"github.com/wix/dapperdox/security"
+	"github.com/wix/dapperdox/spec"
+	"github.com/wix/dapperdox/validate"
+	"github.com/gorilla/pat"
+)
+
+// StatusHeader and StatusQuery choose the status of a mock response.
+const (
+	StatusHeader = "X-Mock-Status"
+	StatusQuery  = "mock-status"
+)
+
+// EnvironmentName is the name of the mock among the explorer's target environments.
+const EnvironmentName = "Mock"
+
+// ----------------------------------------------------------------------------------------
+// Register creates a route for the mock of each specification, and adds the mock to
+// the specification's target environments.
+func Register(r *pat.Router) {
+	cfg, _ := config.Get()
+	if !cfg.Mock {
+		return
+	}
+	logger.Debugln(nil, "registering handlers for the mock")
+
+	for _, specification := range spec.APISuite {
+		prefix := cfg.MockPath + "/" + specification.ID
+		logger.Tracef(nil, "+ mock %s -> %s", prefix, specification.APIInfo.Title)
+
+		security.RoutePrefix(security.Mock, prefix)
+		r.PathPrefix(prefix + "/").HandlerFunc(handler(specification, prefix))
+
+		specification.Environments = append(specification.Environments, config.Environment{
+			Name: EnvironmentName,
+			URL:  strings.TrimSuffix(cfg.SiteURL, "/") + prefix + specification.BasePath,
+		})
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+
+func handler(specification *spec.APISpecification, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !specification.Published() && !publish.Preview(req) {
+			fail(w, http.StatusNotFound, "No such operation", nil)
+			return
+		}
+
+		method, params := validate.Match(specification, req.Method, strings.TrimPrefix(req.URL.Path, prefix))
+		if method == nil {
+			fail(w, http.StatusNotFound, "No such operation", nil)
+			return
+		}
+		if problems := validate.Request(method, req, params); len(problems) > 0 {
+			logger.Debugf(req, "Mock request for %s %s is invalid: %v", strings.ToUpper(method.Method), method.Path, problems)
+			fail(w, http.StatusBadRequest, "The request does not match its documentation", problems)
+			return
+		}
+
+		if !delay(req) {
+			return
+		}
+
+		status, response := choose(method, req)
+		if response == nil && status == 0 {
+			fail(w, http.StatusBadRequest, "Status must be an HTTP status from 100 to 599", nil)
+			return
+		}
+		if response == nil {
+			fail(w, http.StatusBadRequest, "Status "+strconv.Itoa(status)+" is not documented", nil)
+			return
+		}
+		respond(w, method, status, response, record.Promoted(specification.ID, method, status))
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// choose returns the status to answer with, and its documented response, or nil if
+// it has none. The status is that requested, that of an injected error, or the
+// operation's first success status. A requested status that is not an HTTP status
+// is returned as 0.
+func choose(method *spec.Method, req *http.Request) (int, *spec.Response) {
+	cfg, _ := config.Get()
+
+	var statuses []int
+	for status := range method.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	requested := req.Header.Get(StatusHeader)
+	if len(requested) == 0 {
+		requested = req.URL.Query().Get(StatusQuery)
+	}
+	if len(requested) > 0 {
+		status, err := strconv.Atoi(requested)
+		if err != nil || status < 100 || status > 599 {
+			return 0, nil
+		}
+		return status, responseFor(method, status)
+	}
+
+	if rate, err := strconv.ParseFloat(cfg.MockErrorRate, 64); err == nil && rand.Float64() < rate {
+		status := http.StatusInternalServerError
+		for _, s := range statuses {
+			if s >= 500 {
+				status = s
+				break
+			}
+		}
+		if response := responseFor(method, status); response != nil {
+			return status, response
+		}
+		return status, &spec.Response{}
+	}
+
+	for _, status := range statuses {
+		if status >= 200 && status < 300 {
+			return status, responseFor(method, status)
+		}
+	}
+	if len(statuses) > 0 {
+		return statuses[0], responseFor(method, statuses[0])
+	}
+	return http.StatusOK, method.DefaultResponse
+}
+
+func responseFor(method *spec.Method, status int) *spec.Response {
+	if response, ok := method.Responses[status]; ok {
+		return &response
+	}
+	return method.DefaultResponse
+}
+
+// ----------------------------------------------------------------------------------------
+// delay sleeps for mock-latency, or a random duration within its range. It returns
+// false if the client went away first.
+func delay(req *http.Request) bool {
+	cfg, _ := config.Get()
+	if len(cfg.MockLatency) == 0 {
+		return true
+	}
+	min, max, _ := config.ParseDurationRange(cfg.MockLatency)
+	d := min
+	if max > min {
+		d += time.Duration(rand.Int63n(int64(max - min)))
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// respond writes a documented response, with example values for its headers and body.
+// A promoted example, if given, is the body.
+func respond(w http.ResponseWriter, method *spec.Method, status int, response *spec.Response, example *record.Example) {
+	for _, header := range response.Headers {
+		w.Header().Set(header.Name, headerExample(header))
+	}
+	if example != nil {
+		if len(example.ContentType) > 0 {
+			w.Header().Set("Content-Type", example.ContentType)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(example.Body))
+		return
+	}
+	if response.Body == nil || status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", contentType(method))
+	w.WriteHeader(status)
+	buf, _ := spec.JSONMarshalIndent(Example(response.Body))
+	w.Write(buf)
+}
+
+// contentType returns the JSON type the operation produces, or application/json.
+func contentType(method *spec.Method) string {
+	for _, t := range method.Produces {
+		if t == "application/json" || strings.HasSuffix(t, "+json") {
+			return t
+		}
+	}
+	return "application/json"
+}
+
+func headerExample(header spec.Header) string {
+	switch {
+	case len(header.Default) > 0:
+		return header.Default
+	case len(header.Enum) > 0:
+		return header.Enum[0]
+	case len(header.Type) > 0:
+		return fmt.Sprint(primitiveExample(header.Type[len(header.Type)-1]))
+	}
+	return "string"
+}
+
+// ----------------------------------------------------------------------------------------
+// fail writes a JSON error, listing any problems found with the request.
+func fail(w http.ResponseWriter, status int, message string, problems []validate.Problem) {
+	body := map[string]interface{}{
+		"status":  status,
+		"error":   http.StatusText(status),
+		"message": message,
+	}
+	if len(problems) > 0 {
+		body["problems"] = problems
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// ----------------------------------------------------------------------------------------
+// end
@@ -0,0 +1,78 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/wix/dapperdox/spec"
+)
+
+func TestChoose(t *testing.T) {
+	documented := &spec.Method{
+		Responses: map[int]spec.Response{
+			201: {Description: "Created"},
+			400: {Description: "Bad request"},
+			503: {Description: "Unavailable"},
+		},
+	}
+	withDefault := &spec.Method{
+		Responses:       map[int]spec.Response{200: {Description: "OK"}},
+		DefaultResponse: &spec.Response{Description: "Error"},
+	}
+	undocumented := &spec.Method{}
+
+	tests := []struct {
+		name        string
+		method      *spec.Method
+		header      string
+		query       string
+		status      int
+		description string // Of the response chosen, or empty if none is
+	}{
+		{"first success", documented, "", "", 201, "Created"},
+		{"requested by header", documented, "400", "", 400, "Bad request"},
+		{"requested by query", documented, "", "503", 503, "Unavailable"},
+		{"header before query", documented, "400", "503", 400, "Bad request"},
+		{"not documented", documented, "404", "", 404, ""},
+		{"falls back to default", withDefault, "404", "", 404, "Error"},
+		{"not a number", withDefault, "ok", "", 0, ""},
+		{"below range", withDefault, "42", "", 0, ""},
+		{"above range", withDefault, "1000", "", 0, ""},
+		{"no responses", undocumented, "", "", 200, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/mock/petstore/pets?"+StatusQuery+"="+test.query, nil)
+			if len(test.header) > 0 {
+				req.Header.Set(StatusHeader, test.header)
+			}
+			status, response := choose(test.method, req)
+			if status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+			switch {
+			case response == nil && len(test.description) > 0:
+				t.Errorf("got no response, want %s", test.description)
+			case response != nil && response.Description != test.description:
+				t.Errorf("got response %s, want %s", response.Description, test.description)
+			}
+		})
+	}
+}
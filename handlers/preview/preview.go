@@ -0,0 +1,72 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package preview
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/spec"
+)
+
+// ----------------------------------------------------------------------------------------
+// Handler enters and leaves preview mode, and refuses the pages of unpublished
+// specifications to readers who are not in it. A reader enters preview mode with
+// ?preview=<preview-token>, or ?preview=1 from a loopback client if there is no
+// token, and leaves it with ?preview=0.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if values, ok := query["preview"]; ok {
+			value := values[0]
+			switch {
+			case value == "0":
+				http.SetCookie(w, &http.Cookie{Name: publish.CookieName, Value: "", Path: "/", MaxAge: -1})
+			case publish.Allowed(req, value):
+				http.SetCookie(w, &http.Cookie{Name: publish.CookieName, Value: publish.Session(), Path: "/", HttpOnly: true, Secure: req.TLS != nil})
+			default:
+				logger.Warnf(req, "Refusing preview mode to %s", req.RemoteAddr)
+				render.HTML(w, http.StatusForbidden, "error", render.DefaultVars(req, nil, render.Vars{"error": "Forbidden", "code": 403}))
+				return
+			}
+
+			// Redirect to the page without the parameter, so that the token does not linger in the address bar
+			query.Del("preview")
+			target := *req.URL
+			target.RawQuery = query.Encode()
+			http.Redirect(w, req, target.RequestURI(), http.StatusFound)
+			return
+		}
+
+		if !publish.Preview(req) {
+			id := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)[0]
+			if specification, ok := spec.APISuite[id]; ok && !specification.Published() {
+				logger.Tracef(req, "Not serving unpublished specification '%s' outside of preview mode", id)
+				render.HTML(w, http.StatusNotFound, "error", render.DefaultVars(req, nil, render.Vars{"error": "Page not found", "code": 404}))
+				return
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ----------------------------------------------------------------------------------------
+// end
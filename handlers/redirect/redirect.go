@@ -0,0 +1,241 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package redirect
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/spec"
+	"github.com/gorilla/pat"
+)
+
+// table is the content of the redirect file. URLs maps the stable identity of
+// each page (its specification source, tag or path, method and so on) to the URL
+// it was last served at, and Redirects maps old URLs to their replacements.
+type table struct {
+	URLs      map[string]string `json:"urls"`
+	Redirects map[string]string `json:"redirects"`
+}
+
+// ----------------------------------------------------------------------------------------
+// Register creates 301 redirect routes for page URLs that have changed since a
+// previous build, and for those configured by redirect-url. It must be called
+// after the page routes have been registered, so that they take precedence.
+func Register(r *pat.Router) {
+	logger.Debugln(nil, "registering redirects")
+
+	cfg, _ := config.Get()
+
+	current := currentURLs()
+	t := &table{URLs: make(map[string]string), Redirects: make(map[string]string)}
+
+	if len(cfg.RedirectFile) > 0 {
+		if err := t.load(cfg.RedirectFile); err != nil {
+			logger.Errorf(nil, "Error reading redirect file %s: %s", cfg.RedirectFile, err)
+			os.Exit(1)
+		}
+	}
+
+	// A page whose URL has changed since it was recorded is redirected from the old one
+	for key, old := range t.URLs {
+		if now, ok := current[key]; ok && now != old {
+			logger.Infof(nil, "Page URL %s has moved to %s", old, now)
+			t.Redirects[old] = now
+		}
+	}
+	for _, v := range cfg.RedirectURL {
+		pair, _ := config.ParsePair(v) // Already validated
+		t.Redirects[pair.From] = pair.To
+	}
+	t.URLs = current
+	t.resolve()
+
+	if len(cfg.RedirectFile) > 0 {
+		if err := t.save(cfg.RedirectFile); err != nil {
+			logger.Errorf(nil, "Error writing redirect file %s: %s", cfg.RedirectFile, err)
+			os.Exit(1)
+		}
+	}
+
+	// Register in a stable order, so that the longest prefixes match first
+	from := make([]string, 0, len(t.Redirects))
+	for f := range t.Redirects {
+		from = append(from, f)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(from)))
+
+	for _, f := range from {
+		to := t.Redirects[f]
+		if isLive(f, current) {
+			logger.Tracef(nil, "Skipping redirect %s - it is the URL of a current page", f)
+			continue
+		}
+		logger.Tracef(nil, "+ Redirect %s -> %s", f, to)
+		if strings.HasSuffix(f, "/") {
+			r.PathPrefix(f).Methods("GET").HandlerFunc(prefixHandler(f, to))
+			bare := strings.TrimSuffix(to, "/")
+			if len(bare) == 0 {
+				bare = "/"
+			}
+			r.Path(strings.TrimSuffix(f, "/")).Methods("GET").HandlerFunc(handler(bare))
+		} else {
+			r.Path(f).Methods("GET").HandlerFunc(handler(to))
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// currentURLs returns the stable identity->URL of every specification, API, method and
+// resource page. The URLs follow those registered by the reference handlers.
+func currentURLs() map[string]string {
+	urls := make(map[string]string)
+
+	for _, specification := range spec.APISuite {
+		base := "/" + specification.ID
+		urls["spec "+specification.URL] = base + "/" // Every page of the specification is under it
+
+		for _, api := range specification.APIs {
+			apiKey := specification.URL + " " + api.Key
+			urls["api "+apiKey] = base + "/reference/" + api.ID
+
+			for _, method := range api.Methods {
+				urls["method "+apiKey+" "+strings.ToUpper(method.Method)+" "+method.Path] = base + "/reference/" + api.ID + "/" + method.ID
+			}
+		}
+		for _, resources := range specification.ResourceList {
+			for id, resource := range resources {
+				urls["resource "+specification.URL+" "+resource.Title] = base + "/resources/" + id
+			}
+		}
+	}
+	return urls
+}
+
+// ----------------------------------------------------------------------------------------
+// resolve collapses chains of redirects, so that every old URL goes straight to
+// the current one, and drops any that would redirect a URL to itself.
+func (t *table) resolve() {
+	for from := range t.Redirects {
+		to := t.Redirects[from]
+		seen := map[string]bool{from: true}
+		for {
+			next, ok := t.Redirects[to]
+			if !ok || seen[to] {
+				break
+			}
+			seen[to] = true
+			to = next
+		}
+		if to == from {
+			delete(t.Redirects, from)
+		} else {
+			t.Redirects[from] = to
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+
+func isLive(from string, current map[string]string) bool {
+	for _, u := range current {
+		if u == from || strings.TrimSuffix(u, "/") == from || (strings.HasSuffix(from, "/") && strings.HasPrefix(u, from)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------------------
+
+func (t *table) load(filename string) error {
+	buf, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil // First build
+	}
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(buf, t); err != nil {
+		return err
+	}
+	if t.URLs == nil {
+		t.URLs = make(map[string]string)
+	}
+	if t.Redirects == nil {
+		t.Redirects = make(map[string]string)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------------------
+// save writes the table via a temporary file, so that an interrupted write does
+// not lose the history of previous builds.
+func (t *table) save(filename string) error {
+	buf, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".redirects")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(append(buf, '\n')); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// ----------------------------------------------------------------------------------------
+
+func handler(to string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		redirect(w, req, to)
+	}
+}
+
+func prefixHandler(from, to string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		redirect(w, req, to+strings.TrimPrefix(req.URL.Path, from))
+	}
+}
+
+func redirect(w http.ResponseWriter, req *http.Request, target string) {
+	// pat joins any route variables onto the query with &, so tidy it up
+	if query := strings.Trim(req.URL.RawQuery, "&"); len(query) > 0 {
+		target += "?" + query
+	}
+	logger.Tracef(req, "Redirect to %s", target)
+	http.Redirect(w, req, target, http.StatusMovedPermanently)
+}
+
+// ----------------------------------------------------------------------------------------
+// end
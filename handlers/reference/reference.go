@@ -19,10 +19,13 @@ package reference
 
 import (
 	"net/http"
+	"net/url"
+	"sort"
 
 	//"github.com/wix/dapperdox/go-spew/spew"
 	"github.com/wix/dapperdox/logger"
 	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/render/cache"
 	"github.com/wix/dapperdox/spec"
 	"github.com/gorilla/pat"
 )
@@ -49,7 +52,7 @@ func Register(r *pat.Router) {
 
 		for _, api := range specification.APIs {
 			logger.Debugf(nil, "  - Scanning API [%s] %s", api.ID, api.Name)
-			r.Path(spec_id + "/reference/" + api.ID).Methods("GET").HandlerFunc(APIHandler(specification, api))
+			r.Path(spec_id + "/reference/" + api.ID).Methods("GET").HandlerFunc(cache.Handler(APIHandler(specification, api)))
 
 			version := api.CurrentVersion
 
@@ -62,7 +65,7 @@ func Register(r *pat.Router) {
 				// Add version->method to pathVersionMethod
 				if _, ok := pathVersionMethod[path]; !ok {
 					pathVersionMethod[path] = make(versionedMethod)
-					r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
+					r.Path(path).Methods("GET").HandlerFunc(cache.Handler(MethodHandler(specification, api, path)))
 				}
 				pathVersionMethod[path][version] = method
 			}
@@ -73,13 +76,15 @@ func Register(r *pat.Router) {
 					// Add version->resource to pathVersionResource
 					if _, ok := pathVersionMethod[path]; !ok {
 						pathVersionMethod[path] = make(versionedMethod)
-						r.Path(path).Methods("GET").HandlerFunc(MethodHandler(specification, api, path))
+						r.Path(path).Methods("GET").HandlerFunc(cache.Handler(MethodHandler(specification, api, path)))
 					}
 					pathVersionMethod[path][version] = method
 				}
 			}
 		}
 
+		r.Path(spec_id + "/deprecations").Methods("GET").HandlerFunc(DeprecationsHandler(specification))
+
 		logger.Debugf(nil, "  - Registering resources")
 		for version, resources := range specification.ResourceList {
 			logger.Debugf(nil, "    - Version %s", version)
@@ -88,7 +93,7 @@ func Register(r *pat.Router) {
 				logger.Debugf(nil, "      + resource %s", id)
 				if _, ok := pathVersionResource[path]; !ok {
 					pathVersionResource[path] = make(versionedResource)
-					r.Path(path).Methods("GET").HandlerFunc(GlobalResourceHandler(specification, path))
+					r.Path(path).Methods("GET").HandlerFunc(cache.Handler(GlobalResourceHandler(specification, path)))
 				}
 				pathVersionResource[path][version] = resource
 			}
@@ -97,6 +102,35 @@ func Register(r *pat.Router) {
 	logger.Debugf(nil, "\n")
 }
 
+// ------------------------------------------------------------------------------------------------------------
+// URLs returns the URL of every API, method and resource page, in each version, for
+// pre-rendering.
+func URLs() []string {
+	var urls []string
+	for _, specification := range spec.APISuite {
+		for _, api := range specification.APIs {
+			urls = append(urls, "/"+specification.ID+"/reference/"+api.ID)
+			for version := range api.Versions {
+				urls = append(urls, "/"+specification.ID+"/reference/"+api.ID+"?v="+url.QueryEscape(version))
+			}
+		}
+	}
+	for path, versions := range pathVersionMethod {
+		urls = append(urls, path)
+		for version := range versions {
+			urls = append(urls, path+"?v="+url.QueryEscape(version))
+		}
+	}
+	for path, versions := range pathVersionResource {
+		urls = append(urls, path)
+		for version := range versions {
+			urls = append(urls, path+"?v="+url.QueryEscape(version))
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
 // ------------------------------------------------------------------------------------------------------------
 
 func getVersionMethod(api spec.APIGroup, version string) []spec.Method {
@@ -169,6 +203,9 @@ func APIHandler(specification *spec.APISpecification, api spec.APIGroup) func(w
 		}
 		versions := getAPIVersions(api)
 		methods := getVersionMethod(api, version)
+		if _, ok := api.Versions[version]; !ok && version != api.CurrentVersion {
+			cache.NoStore(w) // Rendered as the current version
+		}
 
 		tmpl := "api"
 		customTmpl := "reference/" + api.ID
@@ -192,7 +229,10 @@ func MethodHandler(specification *spec.APISpecification, api spec.APIGroup, path
 			version = api.CurrentVersion
 		}
 		versions := getMethodVersions(api, pathVersionMethod[path])
-		method := pathVersionMethod[path][version]
+		method, ok := pathVersionMethod[path][version]
+		if !ok {
+			cache.NoStore(w)
+		}
 
 		tmpl := "method"
 		customTmpl := "reference/" + api.ID + "/" + method.ID
@@ -237,7 +277,10 @@ func GlobalResourceHandler(specification *spec.APISpecification, path string) fu
 			}
 		}
 
-		resource := pathVersionResource[path][version]
+		resource, ok := pathVersionResource[path][version]
+		if !ok {
+			cache.NoStore(w)
+		}
 
 		logger.Debugf(nil, "Render resource "+resource.ID)
 		tmpl := "resource"
@@ -254,5 +297,13 @@ func GlobalResourceHandler(specification *spec.APISpecification, path string) fu
 	}
 }
 
+// ------------------------------------------------------------------------------------------------------------
+// DeprecationsHandler is a http.Handler for rendering the deprecation report of a specification
+func DeprecationsHandler(specification *spec.APISpecification) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		render.HTML(w, http.StatusOK, "deprecations", render.DefaultVars(req, specification, render.Vars{"Title": "Deprecations"}))
+	}
+}
+
 // ------------------------------------------------------------------------------------------------------------
 // end
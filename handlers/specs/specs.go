@@ -26,6 +26,7 @@ import (
 
 	"github.com/wix/dapperdox/config"
 	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/security"
 	"github.com/gorilla/pat"
 )
 
@@ -53,16 +54,12 @@ func Register(r *pat.Router) {
 
 		// Configure the replacer with key=value pairs
 		for i := range cfg.SpecRewriteURL {
-
-			slice := strings.Split(cfg.SpecRewriteURL[i], "=")
-
-			switch len(slice) {
-			case 1: // Map between configured URL and site URL
-				replacements = append(replacements, slice[0], cfg.SiteURL)
-			case 2: // Map between configured to=from URL pair
-				replacements = append(replacements, slice...)
-			default:
-				panic("Invalid DocumentWriteUrl - does not contain an = delimited from=to pair")
+			if pair, err := config.ParsePair(cfg.SpecRewriteURL[i]); err == nil {
+				// Map between configured to=from URL pair
+				replacements = append(replacements, pair.From, pair.To)
+			} else {
+				// Map between configured URL and site URL
+				replacements = append(replacements, cfg.SpecRewriteURL[i], cfg.SiteURL)
 			}
 		}
 		specReplacer = strings.NewReplacer(replacements...)
@@ -104,6 +101,16 @@ func Register(r *pat.Router) {
 			// Replace URLs in document
 			specMap[route] = []byte(specReplacer.Replace(string(specMap[route])))
 
+			// Then apply any rewrites from the specification's configuration file section
+			if section := cfg.SpecSection(route); section != nil && len(section.Rewrite) > 0 {
+				var replacements []string
+				for _, r := range section.Rewrite {
+					replacements = append(replacements, r.From, r.To)
+				}
+				specMap[route] = []byte(strings.NewReplacer(replacements...).Replace(string(specMap[route])))
+			}
+
+			security.Route(security.Specs, route)
 			r.Path(route).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				serveSpec(w, route)
 			})
@@ -18,19 +18,65 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package static
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
 	"mime"
 	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	//"github.com/wix/dapperdox/assets"
 	"github.com/wix/dapperdox/logger"
 	"github.com/wix/dapperdox/render"
 	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/security"
+	"github.com/andybalholm/brotli"
 	"github.com/gorilla/pat"
 )
 
-// Register creates routes for each static resource
+// Types of extensions missing from, or mistyped by, many system MIME tables
+var mimeTypes = map[string]string{
+	".css":   "text/css; charset=utf-8",
+	".eot":   "application/vnd.ms-fontobject",
+	".ico":   "image/x-icon",
+	".js":    "application/javascript",
+	".json":  "application/json",
+	".map":   "application/json",
+	".md":    "text/markdown; charset=utf-8",
+	".otf":   "font/otf",
+	".pdf":   "application/pdf",
+	".svg":   "image/svg+xml",
+	".ttf":   "font/ttf",
+	".wasm":  "application/wasm",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".yaml":  "application/yaml",
+	".yml":   "application/yaml",
+	".zip":   "application/zip",
+}
+
+const minCompressSize = 256 // Bytes, below which compression is not worthwhile
+
+// staticAsset is a static file, with its compressed variants.
+type staticAsset struct {
+	mimeType string
+	etag     string
+	body     []byte
+	encoded  map[string][]byte // Content-Encoding->Compressed body
+}
+
+// Content-Encodings offered, in order of preference
+var encodings = []string{"br", "gzip"}
+
+// Register creates routes for each static resource, at both its path and its
+// content hashed path (see asset.StaticPath). Responses at hashed paths never change
+// so may be cached indefinitely, while those at plain paths must be revalidated.
 func Register(r *pat.Router) {
 	logger.Debugln(nil, "registering not found handler in static package")
 
@@ -40,44 +86,205 @@ func Register(r *pat.Router) {
 
 	logger.Debugln(nil, "registering static content handlers for static package")
 
-	var allow bool
+	modified := time.Now()
 
 	for _, file := range asset.AssetNames() {
-		mimeType := mime.TypeByExtension(filepath.Ext(file))
+		if !strings.HasPrefix(file, "assets/static/") {
+			continue
+		}
+		// Drop assets/static prefix
+		path := strings.TrimPrefix(file, "assets/static")
+
+		body, _ := asset.Asset(file)
+		a := newStaticAsset(path, body)
+
+		logger.Debugf(nil, "registering handler for static asset: %s (%s)", path, a.mimeType)
+
+		security.Route(security.Static, path)
+		security.Route(security.Static, asset.StaticPath(path))
+		r.Path(path).Methods("GET", "HEAD").HandlerFunc(a.handler(modified, "public, no-cache"))
+		r.Path(asset.StaticPath(path)).Methods("GET", "HEAD").HandlerFunc(a.handler(modified, "public, max-age=31536000, immutable"))
+	}
+}
 
-		if mimeType == "" {
+// ---------------------------------------------------------------------------
+// newStaticAsset types a static file and compresses it, if that is worthwhile.
+func newStaticAsset(path string, body []byte) *staticAsset {
+	a := &staticAsset{
+		mimeType: mimeType(path, body),
+		etag:     asset.ContentHash(body),
+		body:     body,
+		encoded:  make(map[string][]byte),
+	}
+
+	if len(body) < minCompressSize || !compressible(a.mimeType) {
+		return a
+	}
+	for _, encoding := range encodings {
+		var b bytes.Buffer
+		var err error
+		switch encoding {
+		case "br":
+			w := brotli.NewWriterLevel(&b, brotli.BestCompression)
+			if _, err = w.Write(body); err == nil {
+				err = w.Close()
+			}
+		case "gzip":
+			w, _ := gzip.NewWriterLevel(&b, gzip.BestCompression)
+			if _, err = w.Write(body); err == nil {
+				err = w.Close()
+			}
+		}
+		if err != nil {
+			logger.Errorf(nil, "Error compressing static asset %s: %s", path, err)
 			continue
 		}
+		if b.Len() < len(body) {
+			a.encoded[encoding] = b.Bytes()
+		}
+	}
+	return a
+}
 
-		logger.Debugf(nil, "Got MIME type: %s", mimeType)
+// ---------------------------------------------------------------------------
+// handler serves the asset in the encoding the client prefers, supporting range and
+// conditional requests.
+func (a *staticAsset) handler(modified time.Time, cacheControl string) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := a.body
+		etag := a.etag
 
-		switch {
-		case strings.HasPrefix(mimeType, "image"),
-			strings.HasPrefix(mimeType, "text/css"),
-			strings.HasSuffix(mimeType, "javascript"):
-			allow = true
-		default:
-			allow = false
+		if len(a.encoded) > 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if encoding := negotiate(req.Header.Get("Accept-Encoding"), a.encoded); len(encoding) > 0 {
+				body = a.encoded[encoding]
+				etag += "-" + encoding
+				w.Header().Set("Content-Encoding", encoding)
+			}
 		}
 
-		if allow {
-			// Drop assets/static prefix
-			path := strings.TrimPrefix(file, "assets/static")
+		w.Header().Set("Content-Type", a.mimeType)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", `"`+etag+`"`)
 
-			logger.Debugf(nil, "registering handler for static asset: %s", path)
+		http.ServeContent(w, req, "", modified, bytes.NewReader(body))
+	}
+}
 
-			r.Path(path).Methods("GET").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-				if b, err := asset.Asset("assets/static" + path); err == nil {
-					w.Header().Set("Content-Type", mimeType)
-					w.Header().Set("Cache-control", "public, max-age=259200")
-					w.WriteHeader(200)
-					w.Write(b)
-					return
+// ---------------------------------------------------------------------------
+// negotiate returns the preferred encoding of an Accept-Encoding header that the
+// asset is available in, or "" for the asset as is.
+func negotiate(header string, available map[string][]byte) string {
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		if _, ok := available[encoding]; !ok {
+			continue
+		}
+		quality := acceptQuality(header, encoding)
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// ---------------------------------------------------------------------------
+// acceptQuality returns the q-value an Accept-Encoding header gives an encoding,
+// or 0 if it is not accepted.
+func acceptQuality(header string, encoding string) float64 {
+	quality := 0.0
+	for _, item := range strings.Split(header, ",") {
+		fields := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encoding && (name != "*" || quality > 0) {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				if f, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = f
 				}
-				// This should never happen!
-				logger.Errorf(nil, "it happened ¯\\_(ツ)_/¯", path)
-				r.NotFoundHandler.ServeHTTP(w, req)
-			})
+			}
+		}
+		if name == encoding {
+			return q
+		}
+		quality = q
+	}
+	return quality
+}
+
+// ---------------------------------------------------------------------------
+// mimeType returns the type of a static file, by extension or else by content.
+func mimeType(path string, body []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := mimeTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); len(t) > 0 {
+		return t
+	}
+	return http.DetectContentType(body)
+}
+
+// ---------------------------------------------------------------------------
+// compressible returns true for types that are not already compressed.
+func compressible(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		strings.HasPrefix(mimeType, "image/svg"),
+		strings.HasPrefix(mimeType, "font/ttf"),
+		strings.HasPrefix(mimeType, "font/otf"),
+		strings.HasPrefix(mimeType, "application/vnd.ms-fontobject"):
+		return true
+	case strings.HasPrefix(mimeType, "application/"):
+		return strings.Contains(mimeType, "javascript") ||
+			strings.Contains(mimeType, "json") ||
+			strings.Contains(mimeType, "xml") ||
+			strings.Contains(mimeType, "yaml") ||
+			strings.Contains(mimeType, "wasm")
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Publish writes every static asset to dir, under both its plain and content hashed
+// paths, for upload to static-url. A manifest.json maps each plain path to its
+// hashed path.
+func Publish(dir string) error {
+	manifest := make(map[string]string)
+	var paths []string
+	for _, file := range asset.AssetNames() {
+		if strings.HasPrefix(file, "assets/static/") {
+			paths = append(paths, strings.TrimPrefix(file, "assets/static"))
 		}
 	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		body, _ := asset.Asset("assets/static" + path)
+		hashed := asset.StaticPath(path)
+		for _, name := range []string{path, hashed} {
+			filename := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(filename), os.FileMode(0755)); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filename, body, os.FileMode(0644)); err != nil {
+				return err
+			}
+		}
+		manifest[path] = hashed
+		logger.Debugf(nil, "published static asset %s as %s", path, hashed)
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "manifest.json"), append(buf, '\n'), os.FileMode(0644)); err != nil {
+		return err
+	}
+	logger.Infof(nil, "Published %d static assets to %s", len(paths), dir)
+	return nil
 }
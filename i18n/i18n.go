@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+)
+
+// CookieName is the cookie that remembers the locale a reader last chose by URL prefix.
+const CookieName = "dapperdox-locale"
+
+// AssetDir is the directory of assets-dir holding the templates and sections trees of
+// each locale other than the default, and the matching prefix of their compiled names.
+const AssetDir = "locales"
+
+type contextKey int
+
+const localeKey contextKey = 0
+
+// Alternate is the URL of the current page in another locale, for hreflang links.
+type Alternate struct {
+	Locale string // "x-default" for the unprefixed URL
+	URL    string
+}
+
+// ---------------------------------------------------------------------------
+// Locales returns the configured locales, the default first. Without any it
+// returns a single empty default locale.
+func Locales() []string {
+	cfg, _ := config.Get()
+	if len(cfg.Locale) == 0 {
+		return []string{""}
+	}
+	return cfg.Locale
+}
+
+// ---------------------------------------------------------------------------
+// Default returns the default locale, whose assets are not prefixed.
+func Default() string {
+	return Locales()[0]
+}
+
+// ---------------------------------------------------------------------------
+// AssetName returns the name of the locale's version of an asset or template name
+// relative to assets/templates.
+func AssetName(name string, locale string) string {
+	if locale == Default() {
+		return name
+	}
+	return AssetDir + "/" + locale + "/" + name
+}
+
+// ---------------------------------------------------------------------------
+// AssetLocale returns the locale of a compiled asset name, such as
+// assets/templates/locales/de/guides/intro.tmpl, and the name it localises.
+func AssetLocale(name string) (string, string) {
+	prefix := "assets/templates/" + AssetDir + "/"
+	if !strings.HasPrefix(name, prefix) {
+		return Default(), name
+	}
+	parts := strings.SplitN(strings.TrimPrefix(name, prefix), "/", 2)
+	if len(parts) < 2 || !known(parts[0]) {
+		return Default(), name
+	}
+	return parts[0], "assets/templates/" + parts[1]
+}
+
+// ---------------------------------------------------------------------------
+// Locale returns the locale chosen for the request by Handler, or the default.
+func Locale(req *http.Request) string {
+	if req != nil {
+		if locale, ok := req.Context().Value(localeKey).(string); ok {
+			return locale
+		}
+	}
+	return Default()
+}
+
+// ---------------------------------------------------------------------------
+// Alternates returns the URLs of the request's page in every locale, for hreflang
+// alternate links, or nil if there is only one locale.
+func Alternates(req *http.Request) []Alternate {
+	locales := Locales()
+	if req == nil || len(locales) < 2 {
+		return nil
+	}
+	var alternates []Alternate
+	for _, locale := range locales {
+		alternates = append(alternates, Alternate{Locale: locale, URL: "/" + locale + req.URL.Path})
+	}
+	return append(alternates, Alternate{Locale: "x-default", URL: req.URL.Path})
+}
+
+// ---------------------------------------------------------------------------
+// Handler chooses the locale of each request, from a locale URL prefix, which is
+// stripped before routing and remembered by cookie, then the cookie, then the
+// Accept-Language header, and lastly the default.
+func Handler(h http.Handler) http.Handler {
+	if len(Locales()) < 2 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		locale := ""
+
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		if known(parts[0]) {
+			locale = parts[0]
+			path := "/"
+			if len(parts) == 2 {
+				path += parts[1]
+			}
+			u := *req.URL
+			u.Path, u.RawPath = path, ""
+			req.URL = &u
+			http.SetCookie(w, &http.Cookie{Name: CookieName, Value: locale, Path: "/"})
+		} else if cookie, err := req.Cookie(CookieName); err == nil && known(cookie.Value) {
+			locale = cookie.Value
+		} else {
+			locale = negotiate(req.Header.Get("Accept-Language"))
+		}
+
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+		if !known(parts[0]) {
+			w.Header().Add("Vary", "Cookie") // The locale may be that of the cookie
+		}
+		h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), localeKey, locale)))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// negotiate returns the configured locale best matching an Accept-Language header,
+// comparing just the primary language if there is no exact match, or the default.
+func negotiate(header string) string {
+	type preference struct {
+		tag     string
+		quality float64
+	}
+	var preferences []preference
+	for _, item := range strings.Split(header, ",") {
+		fields := strings.Split(item, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil {
+					quality = v
+				}
+			}
+		}
+		if len(tag) > 0 && quality > 0 {
+			preferences = append(preferences, preference{tag, quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+
+	for _, p := range preferences {
+		if known(p.tag) {
+			return p.tag
+		}
+		primary := strings.SplitN(p.tag, "-", 2)[0]
+		for _, locale := range Locales() {
+			if strings.SplitN(locale, "-", 2)[0] == primary {
+				return locale
+			}
+		}
+	}
+	return Default()
+}
+
+// ---------------------------------------------------------------------------
+
+func known(locale string) bool {
+	if len(locale) == 0 {
+		return false
+	}
+	for _, l := range Locales() {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// end
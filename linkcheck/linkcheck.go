@@ -0,0 +1,445 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package linkcheck
+
+// The link check renders every page through the router, as a browser would request
+// it, and checks that each internal link, anchor and static asset reference on it
+// resolves. External links are only fetched for hosts given by link-check-external.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/i18n"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/spec"
+)
+
+// Report is the result of a link check. Broken links are listed by the page they
+// are on, so that they can be compared with a baseline report.
+type Report struct {
+	Started     time.Time            `json:"started"`
+	Pages       int                  `json:"pages"`
+	Links       int                  `json:"links"`
+	Unchecked   int                  `json:"unchecked"` // External links to hosts not in link-check-external
+	Broken      map[string][]Problem `json:"broken"`
+	Regressions map[string][]Problem `json:"regressions,omitempty"` // Broken links not in the baseline report
+}
+
+// Problem is a broken link on a page.
+type Problem struct {
+	Link   string `json:"link"`
+	Reason string `json:"reason"`
+}
+
+type page struct {
+	status   int
+	location string // Of a redirect
+	html     bool
+	body     string
+	ids      map[string]bool
+}
+
+type checker struct {
+	handler  http.Handler
+	site     *url.URL
+	external []string
+	client   *http.Client
+	pages    map[string]*page  // Fetched internal URLs
+	fetched  map[string]string // Checked external URLs->Why broken, or ""
+}
+
+const maxRedirects = 5
+
+var (
+	linkRegex   = regexp.MustCompile(`(?i)\s(?:href|src)\s*=\s*["']([^"']*)["']`)
+	anchorRegex = regexp.MustCompile(`(?i)\s(?:id|name)\s*=\s*["']([^"']+)["']`)
+)
+
+// ---------------------------------------------------------------------------
+// Check renders every page through handler and returns the broken links found.
+// Locale prefixed URLs are resolved as they are for readers.
+func Check(handler http.Handler) *Report {
+	cfg, _ := config.Get()
+
+	site, _ := url.Parse(cfg.SiteURL) // Already validated
+	c := &checker{
+		handler:  i18n.Handler(handler),
+		site:     site,
+		external: cfg.LinkCheckExternal,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		pages:    make(map[string]*page),
+		fetched:  make(map[string]string),
+	}
+	report := &Report{Started: time.Now(), Broken: make(map[string][]Problem)}
+
+	queued := make(map[string]bool)
+	queue := seeds()
+	for _, u := range queue {
+		queued[u] = true
+	}
+
+	for len(queue) > 0 {
+		source := queue[0]
+		queue = queue[1:]
+
+		p := c.fetch(source)
+		if p.status != http.StatusOK || !p.html {
+			if p.status >= 400 {
+				report.add(source, source, fmt.Sprintf("page returns %d", p.status))
+			}
+			continue
+		}
+		report.Pages++
+		logger.Tracef(nil, "Checking links of %s", source)
+
+		base, _ := url.Parse(source)
+		for _, m := range linkRegex.FindAllStringSubmatch(p.body, -1) {
+			href := htmlUnescape(m[1])
+			if skip(href) {
+				continue
+			}
+			report.Links++
+
+			target, err := base.Parse(href)
+			if err != nil {
+				report.add(source, href, "not a valid URL")
+				continue
+			}
+			if !c.internal(target) {
+				reason, checked := c.checkExternal(target)
+				if !checked {
+					report.Unchecked++
+				} else if len(reason) > 0 {
+					report.add(source, href, reason)
+				}
+				continue
+			}
+
+			uri, page, reason := c.checkInternal(target)
+			if len(reason) > 0 {
+				report.add(source, href, reason)
+				continue
+			}
+			// Crawl the pages linked to, so that none are missed
+			if page != nil && page.html && !queued[uri] {
+				queued[uri] = true
+				queue = append(queue, uri)
+			}
+		}
+	}
+	return report
+}
+
+// ---------------------------------------------------------------------------
+// seeds returns the URLs of every published page known from the specifications and
+// guides. Pages they link to are found as they are checked.
+func seeds() []string {
+	urls := []string{"/"}
+
+	var ids []string
+	for id := range spec.APISuite {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		specification := spec.APISuite[id]
+		if !specification.Published() {
+			continue
+		}
+		base := "/" + specification.ID
+		urls = append(urls, base+"/reference", base+"/deprecations")
+
+		for _, api := range specification.APIs {
+			urls = append(urls, base+"/reference/"+api.ID)
+			for _, method := range api.Methods {
+				urls = append(urls, base+"/reference/"+api.ID+"/"+method.ID)
+			}
+		}
+		var resources []string
+		for id := range specification.ResourceList["latest"] {
+			resources = append(resources, base+"/resources/"+id)
+		}
+		sort.Strings(resources)
+		urls = append(urls, resources...)
+	}
+	for _, url := range asset.GuideURLs() {
+		id := strings.SplitN(strings.TrimPrefix(url, "/"), "/", 2)[0]
+		if specification, ok := spec.APISuite[id]; ok && !specification.Published() {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// ---------------------------------------------------------------------------
+// checkInternal returns the URI and page a link leads to, following redirects, or
+// why it is broken.
+func (c *checker) checkInternal(target *url.URL) (string, *page, string) {
+
+	// Static assets are served straight from the compiled assets
+	if len(target.RawQuery) == 0 && len(target.Fragment) == 0 {
+		if _, err := asset.Asset("assets/static" + target.Path); err == nil {
+			return "", nil, ""
+		}
+	}
+
+	u := target
+	p := c.fetch(u.RequestURI())
+	for i := 0; p.status >= 300 && p.status < 400; i++ {
+		if i == maxRedirects || len(p.location) == 0 {
+			return "", nil, "too many redirects"
+		}
+		next, err := u.Parse(p.location)
+		if err != nil {
+			return "", nil, fmt.Sprintf("redirects to invalid URL %s", p.location)
+		}
+		if !c.internal(next) {
+			return "", nil, "" // Redirected off site
+		}
+		u = next
+		p = c.fetch(u.RequestURI())
+	}
+	if p.status >= 400 {
+		return "", nil, fmt.Sprintf("returns %d", p.status)
+	}
+
+	if len(target.Fragment) > 0 && p.html && !p.ids[target.Fragment] {
+		return "", nil, fmt.Sprintf("anchor #%s not found", target.Fragment)
+	}
+	return u.RequestURI(), p, ""
+}
+
+// ---------------------------------------------------------------------------
+// fetch renders an internal URL through the router, once.
+func (c *checker) fetch(uri string) *page {
+	if p, ok := c.pages[uri]; ok {
+		return p
+	}
+
+	req := httptest.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+
+	p := &page{
+		status:   w.Code,
+		location: w.Header().Get("Location"),
+		html:     strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"),
+	}
+	if p.html {
+		p.body = w.Body.String()
+		p.ids = make(map[string]bool)
+		for _, m := range anchorRegex.FindAllStringSubmatch(p.body, -1) {
+			p.ids[htmlUnescape(m[1])] = true
+		}
+	}
+	c.pages[uri] = p
+	return p
+}
+
+// ---------------------------------------------------------------------------
+// checkExternal fetches an external link if its host is allowed, returning why it
+// is broken and whether it was checked.
+func (c *checker) checkExternal(target *url.URL) (string, bool) {
+	if !c.allowed(target.Hostname()) {
+		return "", false
+	}
+	u := *target
+	u.Fragment = ""
+
+	if reason, ok := c.fetched[u.String()]; ok {
+		return reason, true
+	}
+
+	reason := ""
+	resp, err := c.client.Head(u.String())
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = c.client.Get(u.String())
+	}
+	if err != nil {
+		reason = err.Error()
+	} else {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			reason = fmt.Sprintf("returns %d", resp.StatusCode)
+		}
+	}
+	c.fetched[u.String()] = reason
+	return reason, true
+}
+
+// ---------------------------------------------------------------------------
+
+func (c *checker) allowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range c.external {
+		pattern = strings.ToLower(pattern)
+		if host == pattern || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// internal returns true if the URL is served by this site.
+func (c *checker) internal(u *url.URL) bool {
+	return len(u.Host) == 0 || strings.EqualFold(u.Host, c.site.Host)
+}
+
+// ---------------------------------------------------------------------------
+
+func skip(href string) bool {
+	if len(href) == 0 || strings.HasPrefix(href, "#") && len(href) == 1 {
+		return true
+	}
+	lower := strings.ToLower(href)
+	for _, scheme := range []string{"mailto:", "tel:", "javascript:", "data:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+var htmlReplacer = strings.NewReplacer("&amp;", "&", "&#38;", "&", "&#34;", `"`, "&quot;", `"`, "&#39;", "'", "&lt;", "<", "&gt;", ">")
+
+func htmlUnescape(s string) string {
+	return htmlReplacer.Replace(s)
+}
+
+// ---------------------------------------------------------------------------
+
+func (r *Report) add(source, link, reason string) {
+	r.Broken[source] = append(r.Broken[source], Problem{Link: link, Reason: reason})
+}
+
+// ---------------------------------------------------------------------------
+// Compare records as regressions the broken links that are not in the baseline.
+func (r *Report) Compare(baseline *Report) {
+	r.Regressions = make(map[string][]Problem)
+	for source, problems := range r.Broken {
+		for _, p := range problems {
+			if !baseline.has(source, p.Link) {
+				r.Regressions[source] = append(r.Regressions[source], p)
+			}
+		}
+	}
+}
+
+func (r *Report) has(source, link string) bool {
+	for _, p := range r.Broken[source] {
+		if p.Link == link {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Sources returns the pages with broken links, sorted.
+func (r *Report) Sources() []string {
+	var sources []string
+	for source := range r.Broken {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// ---------------------------------------------------------------------------
+// Run is the check-links command. It checks the site, prints the broken links of
+// each page, writes link-check-report if given, and returns the exit status: 1 if
+// there are broken links not in link-check-baseline.
+func Run(handler http.Handler) int {
+	cfg, _ := config.Get()
+
+	logger.Infof(nil, "Checking links")
+	report := Check(handler)
+
+	failed := report.Broken
+	if len(cfg.LinkCheckBaseline) > 0 {
+		baseline, err := load(cfg.LinkCheckBaseline)
+		if err != nil {
+			logger.Errorf(nil, "Error reading link check baseline %s: %s", cfg.LinkCheckBaseline, err)
+			return 1
+		}
+		report.Compare(baseline)
+		failed = report.Regressions
+	}
+
+	for _, source := range report.Sources() {
+		fmt.Printf("%s\n", source)
+		for _, p := range report.Broken[source] {
+			fmt.Printf("    %s: %s\n", p.Link, p.Reason)
+		}
+	}
+	fmt.Printf("%d pages, %d links, %d pages with broken links, %d external links not checked\n", report.Pages, report.Links, len(report.Broken), report.Unchecked)
+
+	if len(cfg.LinkCheckReport) > 0 {
+		if err := report.save(cfg.LinkCheckReport); err != nil {
+			logger.Errorf(nil, "Error writing link check report %s: %s", cfg.LinkCheckReport, err)
+			return 1
+		}
+	}
+
+	if len(failed) > 0 {
+		if report.Regressions != nil {
+			fmt.Printf("%d pages have broken links not in the baseline\n", len(failed))
+		}
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+
+func load(filename string) (*Report, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err = json.Unmarshal(buf, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Report) save(filename string) error {
+	buf, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(buf, '\n'), os.FileMode(0644))
+}
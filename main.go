@@ -26,17 +26,29 @@ import (
 	"time"
 
 	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/handlers/admin"
+	"github.com/wix/dapperdox/handlers/export"
 	"github.com/wix/dapperdox/handlers/guides"
 	"github.com/wix/dapperdox/handlers/home"
+	"github.com/wix/dapperdox/handlers/mock"
+	"github.com/wix/dapperdox/handlers/preview"
+	"github.com/wix/dapperdox/handlers/redirect"
 	"github.com/wix/dapperdox/handlers/reference"
 	"github.com/wix/dapperdox/handlers/specs"
 	"github.com/wix/dapperdox/handlers/static"
 	"github.com/wix/dapperdox/handlers/timeout"
+	"github.com/wix/dapperdox/i18n"
+	"github.com/wix/dapperdox/linkcheck"
 	"github.com/wix/dapperdox/logger"
 	"github.com/wix/dapperdox/network"
 	"github.com/wix/dapperdox/proxy"
+	"github.com/wix/dapperdox/record"
 	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/render/cache"
+	"github.com/wix/dapperdox/sdk"
+	"github.com/wix/dapperdox/security"
 	"github.com/wix/dapperdox/spec"
+	"github.com/wix/dapperdox/themecheck"
 	"github.com/gorilla/pat"
 	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
@@ -58,6 +70,13 @@ func main() {
 		log.Fatalf("error configuring app: %s", err)
 	}
 
+	if cfg.DumpConfig {
+		if err = cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("error dumping configuration: %s", err)
+		}
+		os.Exit(0)
+	}
+
 	// logging before this point must rely on setting LOGLEVEL env var
 	if l, err := logger.LevelFromString(cfg.LogLevel); err == nil {
 		logger.DefaultLevel = l
@@ -67,7 +86,7 @@ func main() {
 	}
 
 	router := pat.New()
-	chain := alice.New(logger.Handler /*, context.ClearHandler*/, timeoutHandler, withCsrf, injectHeaders).Then(router)
+	chain := alice.New(logger.Handler /*, context.ClearHandler*/, i18n.Handler, preview.Handler, timeoutHandler, security.Handler, withCsrf, injectHeaders).Then(router)
 
 	logger.Infof(nil, "listening on %s", cfg.BindAddr)
 	listener, err := net.Listen("tcp", cfg.BindAddr)
@@ -102,43 +121,106 @@ func main() {
 	}
 
 	render.Register()
+	security.Register()
+	record.Register()
+
+	if cfg.ValidateTheme {
+		listener.Close()
+		wg.Wait()
+		os.Exit(themecheck.Run())
+	}
 
+	sdk.Register() // After the templates, including those of SDKs, are compiled
 	reference.Register(router)
+	export.Register(router)
 	guides.Register(router)
-	static.Register(router) // TODO - Static content should be capable of being CDN hosted
+	static.Register(router) // Served here even when pages load them from static-url
+
+	if len(cfg.PublishStatic) > 0 {
+		listener.Close()
+		wg.Wait()
+		if err := static.Publish(cfg.PublishStatic); err != nil {
+			logger.Errorf(nil, "Error publishing static assets to %s: %s", cfg.PublishStatic, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	home.Register(router)
+	admin.Register(router)
+	redirect.Register(router) // After the page routes, which take precedence
 	proxy.Register(router)
+	mock.Register(router)
 
 	listener.Close() // Stop serving specs
 	wg.Wait()        // wait for go routine serving specs to terminate
 
+	if len(cfg.Replay) > 0 {
+		os.Exit(record.Replay(cfg.Replay, cfg.ReplayUnsafe))
+	}
+
+	if cfg.CheckLinks {
+		os.Exit(linkcheck.Run(router))
+	}
+
+	if cfg.Prerender {
+		cache.Prerender(router, reference.URLs())
+	}
+
 	listener, err = network.GetListener(&tlsEnabled)
 	if err != nil {
 		logger.Errorf(nil, "Error listening on %s: %s", cfg.BindAddr, err)
 		os.Exit(1)
 	}
 
-	http.Serve(listener, chain)
+	if tlsEnabled {
+		go func() {
+			if err := network.ServeRedirect(); err != nil {
+				logger.Errorf(nil, "Error serving HTTP redirects: %s", err)
+			}
+		}()
+	}
+
+	// Client certificates are checked only here, not while the specifications are
+	// fetched over loopback at startup
+	http.Serve(listener, network.ClientAccessHandler(chain))
 }
 
 // ---------------------------------------------------------------------------
+// Protect the requests the security policy scopes CSRF protection to. Others are
+// served without a token being issued or checked.
 func withCsrf(h http.Handler) http.Handler {
 	csrfHandler := nosurf.New(h)
+	csrfHandler.SetBaseCookie(security.CSRFCookie())
 	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		rsn := nosurf.Reason(req).Error()
 		logger.Warnf(req, "failed csrf validation: %s", rsn)
 		render.HTML(w, http.StatusBadRequest, "error", map[string]interface{}{"error": rsn})
 	}))
-	return csrfHandler
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !security.CSRF(req) {
+			h.ServeHTTP(w, req)
+			return
+		}
+		csrfHandler.ServeHTTP(w, req)
+	})
 }
 
 // ---------------------------------------------------------------------------
+// Proxied requests are left to the timeouts of their routes, mock requests may be
+// delayed by design, and admin reports may crawl every page.
 func timeoutHandler(h http.Handler) http.Handler {
-	return timeout.Handler(h, 1*time.Second, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	th := timeout.Handler(h, 1*time.Second, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		logger.Warnln(req, "request timed out")
 		render.HTML(w, http.StatusRequestTimeout, "error", map[string]interface{}{"error": "Request timed out"})
 	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if class := security.Class(req); class == security.Proxy || class == security.Mock || admin.IsAdmin(req) {
+			h.ServeHTTP(w, req)
+			return
+		}
+		th.ServeHTTP(w, req)
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -17,7 +17,10 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
 package navigation
 
-import ()
+import (
+	"strings"
+	"unicode"
+)
 
 type NavigationNode struct {
 	ChildMap  map[string]*NavigationNode
@@ -26,6 +29,11 @@ type NavigationNode struct {
 	Name      string
 	Id        string
 	Uri       string
+	Collapsed bool                   // Branch is initially collapsed, unless it leads to the current page
+	Hidden    bool                   // Excluded from navigation, though its pages are still served
+	Draft     bool                   // Only listed, and its page only served, in preview mode
+	Status    string                 // Status badge, such as beta or deprecated
+	Metadata  map[string]interface{} // The page's metadata, keyed by lowercased name, or nil
 }
 
 type ByOrder []*NavigationNode
@@ -34,8 +42,44 @@ func (n ByOrder) Len() int {
 	return len(n)
 }
 func (n ByOrder) Less(a, b int) bool {
-	return n[a].SortOrder < n[b].SortOrder
+	if n[a].SortOrder != n[b].SortOrder {
+		return NaturalLess(n[a].SortOrder, n[b].SortOrder)
+	}
+	return NaturalLess(strings.ToLower(n[a].Name), strings.ToLower(n[b].Name))
 }
 func (n ByOrder) Swap(a, b int) {
 	n[a], n[b] = n[b], n[a]
 }
+
+// NaturalLess compares strings with runs of digits compared by their numeric value,
+// so that "2-setup" sorts before "10-advanced".
+func NaturalLess(a, b string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		da, db := digits(a), digits(b)
+		if da > 0 && db > 0 {
+			na := strings.TrimLeft(a[:da], "0")
+			nb := strings.TrimLeft(b[:db], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[da:], b[db:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digits(s string) int {
+	n := 0
+	for n < len(s) && unicode.IsDigit(rune(s[n])) {
+		n++
+	}
+	return n
+}
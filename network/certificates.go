@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+)
+
+// How often the certificate and key files are checked for changes.
+const certificateWatchInterval = 10 * time.Second
+
+// keyPair is a certificate loaded from a certificate and key file pair, along
+// with the modification times they had when they were loaded.
+type keyPair struct {
+	certFile string
+	keyFile  string
+	certMod  time.Time
+	keyMod   time.Time
+	cert     *tls.Certificate
+}
+
+// certificateStore holds the loaded certificates, the first being the default
+// served when no SNI server name matches.
+type certificateStore struct {
+	mu    sync.RWMutex
+	pairs []*keyPair
+}
+
+// ---------------------------------------------------------------------------
+
+func newCertificateStore(certFile string, keyFile string, extra []string) (*certificateStore, error) {
+	store := &certificateStore{}
+
+	if len(certFile) > 0 {
+		store.pairs = append(store.pairs, &keyPair{certFile: certFile, keyFile: keyFile})
+	}
+	for _, value := range extra {
+		pair, err := config.ParsePair(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TLSCertificatePair: %s", err)
+		}
+		store.pairs = append(store.pairs, &keyPair{certFile: pair.From, keyFile: pair.To})
+	}
+
+	for _, p := range store.pairs {
+		if _, err := p.load(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// ---------------------------------------------------------------------------
+// load (re)reads the key pair from disk if either file has changed since it was
+// last loaded. It returns true if a new certificate was loaded.
+func (p *keyPair) load() (bool, error) {
+	certInfo, err := os.Stat(p.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyInfo, err := os.Stat(p.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	if p.cert != nil && certInfo.ModTime().Equal(p.certMod) && keyInfo.ModTime().Equal(p.keyMod) {
+		return false, nil
+	}
+
+	crt, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return false, err
+	}
+	if crt.Leaf == nil && len(crt.Certificate) > 0 {
+		if crt.Leaf, err = x509.ParseCertificate(crt.Certificate[0]); err != nil {
+			return false, err
+		}
+	}
+
+	p.cert = &crt
+	p.certMod = certInfo.ModTime()
+	p.keyMod = keyInfo.ModTime()
+	return true, nil
+}
+
+// ---------------------------------------------------------------------------
+// watch polls the certificate and key files, reloading any that change. A
+// failed reload (for example, when only one of the pair has been replaced so
+// far) keeps the previous certificate in service and is retried next time.
+func (s *certificateStore) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		for _, p := range s.pairs {
+			s.mu.RLock()
+			candidate := *p
+			s.mu.RUnlock()
+
+			reloaded, err := candidate.load()
+			if err != nil {
+				logger.Warnf(nil, "Unable to reload TLS certificate %s: %s", p.certFile, err)
+				continue
+			}
+			if reloaded {
+				s.mu.Lock()
+				*p = candidate
+				s.mu.Unlock()
+				logger.Infof(nil, "Reloaded TLS certificate %s", p.certFile)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GetCertificate implements tls.Config.GetCertificate, selecting a certificate
+// by the SNI server name offered by the client.
+func (s *certificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if CertificateProvider != nil {
+		crt, err := CertificateProvider(hello)
+		if crt != nil || err != nil {
+			return crt, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.pairs) == 0 {
+		return nil, errors.New("no TLS certificate available")
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if len(name) > 0 {
+		for _, p := range s.pairs {
+			if p.cert.Leaf != nil && p.cert.Leaf.VerifyHostname(name) == nil {
+				return p.cert, nil
+			}
+		}
+	}
+	return s.pairs[0].cert, nil
+}
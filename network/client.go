@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+// ---------------------------------------------------------------------------
+
+func configureClientAuth(tlscfg *tls.Config, caFile string, authType string) error {
+	if len(authType) == 0 {
+		if len(caFile) == 0 {
+			return nil
+		}
+		authType = "require"
+	}
+
+	auth, ok := clientAuthTypes[authType]
+	if !ok {
+		return fmt.Errorf("Invalid TLSClientAuth '%s' - must be one of none, request, verify-if-given or require", authType)
+	}
+	if auth >= tls.VerifyClientCertIfGiven && len(caFile) == 0 {
+		return fmt.Errorf("TLSClientAuth '%s' requires a TLSClientCA bundle to verify against", authType)
+	}
+	tlscfg.ClientAuth = auth
+
+	if len(caFile) > 0 {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificates found in TLSClientCA bundle %s", caFile)
+		}
+		tlscfg.ClientCAs = pool
+	}
+
+	logger.Infof(nil, "client certificates: %s", authType)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// ClientSubject returns the subject distinguished name of the verified client
+// certificate presented with the request, or an empty string if there is none.
+func ClientSubject(req *http.Request) string {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return req.TLS.VerifiedChains[0][0].Subject.String()
+}
+
+// ---------------------------------------------------------------------------
+// IsLoopback returns true if the request was made from the local host.
+func IsLoopback(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	ip := net.ParseIP(host)
+	return err == nil && ip != nil && ip.IsLoopback()
+}
+
+// ---------------------------------------------------------------------------
+// ClientAccessHandler refuses requests whose client certificate subject does not
+// match any of the configured TLSClientSubject expressions. When none are
+// configured, all requests are passed through.
+func ClientAccessHandler(h http.Handler) http.Handler {
+	cfg, _ := config.Get()
+
+	if len(cfg.TLSClientSubject) == 0 {
+		return h
+	}
+
+	var rules []*regexp.Regexp
+	for _, expr := range cfg.TLSClientSubject {
+		rule, err := regexp.Compile(expr)
+		if err != nil {
+			logger.Errorf(nil, "Error: Invalid TLSClientSubject expression '%s': %s", expr, err)
+			os.Exit(1)
+		}
+		rules = append(rules, rule)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		subject := ClientSubject(req)
+		if len(subject) > 0 {
+			for _, rule := range rules {
+				if rule.MatchString(subject) {
+					h.ServeHTTP(w, req)
+					return
+				}
+			}
+		}
+		logger.Warnf(req, "client certificate subject '%s' refused access", subject)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+}
@@ -1,5 +1,5 @@
 /*
-Copyright (C) 2016-2017 dapperdox.com 
+Copyright (C) 2016-2017 dapperdox.com
 
 This program is free software: you can redistribute it and/or modify
 it under the terms of the GNU General Public License as published by
@@ -23,8 +23,19 @@ import (
 	"github.com/wix/dapperdox/config"
 	"github.com/wix/dapperdox/logger"
 	"net"
+	"net/http"
 )
 
+// CertificateProvider, if set, is asked for a certificate before the configured
+// certificate files are consulted. Its signature matches that of an ACME client
+// such as autocert.Manager.GetCertificate, which may be plugged in here. Returning
+// a nil certificate and nil error falls through to the configured certificates.
+var CertificateProvider func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+// ChallengeHandler, if set, wraps the HTTP to HTTPS redirect handler. This allows
+// an ACME client to answer http-01 challenges (see autocert.Manager.HTTPHandler).
+var ChallengeHandler func(http.Handler) http.Handler
+
 func GetListener(tlsEnabled *bool) (net.Listener, error) {
 
 	cfg, _ := config.Get() // Don't worry about error. If there was something wrong with the config, we'd know by now.
@@ -38,7 +49,7 @@ func GetListener(tlsEnabled *bool) (net.Listener, error) {
 	}
 
 	// If no cert & key, then we're to run in plain-text mode
-	if useTLS == 0 {
+	if useTLS == 0 && CertificateProvider == nil {
 		logger.Infof(nil, "listening on %s for unsecured connections", cfg.BindAddr)
 		return net.Listen("tcp", cfg.BindAddr)
 	}
@@ -48,26 +59,71 @@ func GetListener(tlsEnabled *bool) (net.Listener, error) {
 	}
 
 	// Okay, we're building a TLS listener
-	crt, err := tls.LoadX509KeyPair(cfg.TLSCertificate, cfg.TLSKey)
+	store, err := newCertificateStore(cfg.TLSCertificate, cfg.TLSKey, cfg.TLSCertificatePair)
+	if err != nil {
+		return nil, err
+	}
+	go store.watch(certificateWatchInterval)
+
+	minVersion, err := tlsVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	ciphers, err := cipherSuites(cfg.TLSCipherPolicy)
 	if err != nil {
 		return nil, err
 	}
 
 	// Be really secure!
 	tlscfg := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+		MinVersion:               minVersion,
+		CurvePreferences:         []tls.CurveID{tls.X25519, tls.CurveP521, tls.CurveP384, tls.CurveP256},
 		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-		Certificates: []tls.Certificate{crt},
+		CipherSuites:             ciphers,
+		GetCertificate:           store.GetCertificate,
+	}
+
+	if err = configureClientAuth(tlscfg, cfg.TLSClientCA, cfg.TLSClientAuth); err != nil {
+		return nil, err
 	}
 
 	logger.Infof(nil, "listening on %s for SECURED connections", cfg.BindAddr)
 	*tlsEnabled = true
 	return tls.Listen("tcp", cfg.BindAddr, tlscfg)
 }
+
+// ServeRedirect listens for plain HTTP connections on the configured redirect
+// address, and permanently redirects every request to its HTTPS equivalent.
+// It blocks, so is expected to be run as a go routine.
+func ServeRedirect() error {
+	cfg, _ := config.Get()
+
+	if len(cfg.TLSRedirectAddr) == 0 {
+		return nil
+	}
+
+	_, port, err := net.SplitHostPort(cfg.BindAddr)
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := "https://" + host + req.URL.RequestURI()
+
+		logger.Tracef(req, "Redirect to %s", target)
+		http.Redirect(w, req, target, http.StatusMovedPermanently)
+	})
+	if ChallengeHandler != nil {
+		handler = ChallengeHandler(handler)
+	}
+
+	logger.Infof(nil, "listening on %s for HTTP to HTTPS redirects", cfg.TLSRedirectAddr)
+	return http.ListenAndServe(cfg.TLSRedirectAddr, handler)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+)
+
+const (
+	defaultCheckInterval = 10 * time.Second
+	defaultCheckTimeout  = 2 * time.Second
+)
+
+var errNoUpstream = errors.New("no healthy target")
+
+// Methods whose requests may be retried, as repeating them has no further effect
+var idempotent = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"PUT":     true,
+	"DELETE":  true,
+	"TRACE":   true,
+}
+
+type upstream struct {
+	target  *url.URL
+	healthy int32 // 1 if healthy, accessed atomically
+}
+
+func (u *upstream) isHealthy() bool {
+	return atomic.LoadInt32(&u.healthy) == 1
+}
+
+// balancer is the transport of a proxied route. It sends each request to the next
+// healthy target in turn, retrying idempotent requests that fail at another.
+type balancer struct {
+	route     string
+	upstreams []*upstream
+	next      uint32
+	transport http.RoundTripper
+	timeout   time.Duration
+	retries   int
+}
+
+// -----------------------------------------------------------------------------
+
+func newBalancer(route string, targets []string, transport http.RoundTripper, timeout time.Duration, retries int) (*balancer, error) {
+	b := &balancer{route: route, transport: transport, timeout: timeout, retries: retries}
+	for _, t := range targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, err
+		}
+		b.upstreams = append(b.upstreams, &upstream{target: u, healthy: 1})
+	}
+	return b, nil
+}
+
+// -----------------------------------------------------------------------------
+// pick returns the next healthy upstream, preferring those not yet tried, or nil if
+// none are healthy.
+func (b *balancer) pick(tried map[*upstream]bool) *upstream {
+	var fallback *upstream
+	n := uint32(len(b.upstreams))
+	start := atomic.AddUint32(&b.next, 1)
+	for i := uint32(0); i < n; i++ {
+		u := b.upstreams[(start+i)%n]
+		if !u.isHealthy() {
+			continue
+		}
+		if !tried[u] {
+			return u
+		}
+		if fallback == nil {
+			fallback = u
+		}
+	}
+	return fallback
+}
+
+// -----------------------------------------------------------------------------
+// RoundTrip sends a request to a target, retrying an idempotent request that fails
+// to connect, times out, or is answered 502, 503 or 504.
+func (b *balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	var body []byte
+	if idempotent[req.Method] {
+		attempts += b.retries
+		if attempts > 1 && req.Body != nil && req.Body != http.NoBody {
+			var err error
+			if body, err = ioutil.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+		}
+	}
+
+	tried := make(map[*upstream]bool)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		u := b.pick(tried)
+		if u == nil {
+			return nil, errNoUpstream
+		}
+		tried[u] = true
+
+		ctx, cancel := context.WithTimeout(req.Context(), b.timeout)
+		out := req.Clone(ctx)
+		out.URL.Scheme = u.target.Scheme
+		out.URL.Host = u.target.Host
+		out.URL.Path = joinPath(u.target.Path, req.URL.Path)
+		if len(u.target.RawQuery) > 0 && len(req.URL.RawQuery) > 0 {
+			out.URL.RawQuery = u.target.RawQuery + "&" + req.URL.RawQuery
+		} else if len(u.target.RawQuery) > 0 {
+			out.URL.RawQuery = u.target.RawQuery
+		}
+		out.Host = u.target.Host // Rewrite Host
+		if body != nil {
+			out.Body = ioutil.NopCloser(bytes.NewReader(body))
+			out.ContentLength = int64(len(body))
+		}
+		logger.Debugf(req, "Proxy request to: %s", out.URL)
+
+		resp, err := b.transport.RoundTrip(out)
+		retry := err != nil || resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout
+
+		if !retry || attempt >= attempts || req.Context().Err() != nil {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{resp.Body, cancel} // The attempt's timeout covers reading the body
+			return resp, nil
+		}
+
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = errors.New(resp.Status)
+			resp.Body.Close()
+		}
+		cancel()
+		logger.Warnf(req, "Proxy request to %s failed (%s), retrying", u.target, lastErr)
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func joinPath(a, b string) string {
+	switch {
+	case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+		return a + b[1:]
+	case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// -----------------------------------------------------------------------------
+// check polls the health check path of each target, forever, marking targets
+// healthy or not.
+func (b *balancer) check(hc *config.ProxyHealthCheck) {
+	interval := defaultCheckInterval
+	if len(hc.Interval) > 0 {
+		interval, _ = time.ParseDuration(hc.Interval)
+	}
+	timeout := defaultCheckTimeout
+	if len(hc.Timeout) > 0 {
+		timeout, _ = time.ParseDuration(hc.Timeout)
+	}
+	client := &http.Client{Transport: b.transport, Timeout: timeout}
+
+	for {
+		var wg sync.WaitGroup
+		for _, u := range b.upstreams {
+			wg.Add(1)
+			go func(u *upstream) {
+				defer wg.Done()
+
+				target := *u.target
+				target.Path = joinPath(target.Path, hc.Path)
+
+				healthy := false
+				resp, err := client.Get(target.String())
+				if err == nil {
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+					healthy = resp.StatusCode < http.StatusInternalServerError
+				}
+
+				switch was := u.isHealthy(); {
+				case was && !healthy:
+					atomic.StoreInt32(&u.healthy, 0)
+					logger.Warnf(nil, "Proxy target %s of %s failed its health check", u.target, b.route)
+				case !was && healthy:
+					atomic.StoreInt32(&u.healthy, 1)
+					logger.Infof(nil, "Proxy target %s of %s passed its health check", u.target, b.route)
+				}
+			}(u)
+		}
+		wg.Wait()
+		time.Sleep(interval)
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,210 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package proxy
+
+// Contract checking matches proxied requests to the operations documented for them,
+// and checks the request and the service's response against that documentation.
+// Departures are logged as contract drift, and reported to the explorer in the
+// ContractHeader of the response. Exchanges with documented operations are also
+// recorded, if record-dir is given.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/record"
+	"github.com/wix/dapperdox/spec"
+	"github.com/wix/dapperdox/validate"
+)
+
+// ContractHeader is the response header listing, as URL escaped JSON, the ways in
+// which a proxied request and its response depart from their documentation.
+const ContractHeader = "X-DapperDox-Contract"
+
+const maxProblems = 20 // Reported in the ContractHeader, so that it stays a sensible size
+
+type contextKey int
+
+const exchangeKey contextKey = 0
+
+// exchange is a proxied request matched to its documented operation, to be checked
+// against it or recorded.
+type exchange struct {
+	specification *spec.APISpecification
+	method        *spec.Method // Nil if no operation matched
+	validate      bool
+	problems      []validate.Problem
+	request       *record.Request // As received, if it is to be recorded
+}
+
+// -----------------------------------------------------------------------------
+// validates returns true if the route checks requests and responses.
+func (rt *route) validates() bool {
+	cfg, _ := config.Get()
+	return rt.Validate || cfg.ProxyValidate
+}
+
+// -----------------------------------------------------------------------------
+// specifications returns those that the route's requests may be documented by,
+// which is every loaded specification unless the route names one.
+func (rt *route) specifications() []*spec.APISpecification {
+	if len(rt.Specification) > 0 {
+		return []*spec.APISpecification{spec.APISuite[rt.Specification]}
+	}
+	var ids []string
+	for id := range spec.APISuite {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var list []*spec.APISpecification
+	for _, id := range ids {
+		list = append(list, spec.APISuite[id])
+	}
+	return list
+}
+
+// -----------------------------------------------------------------------------
+// checkRequest matches a request to its documented operation, by the path it is
+// forwarded with, checks it against that operation if the route validates, and
+// keeps it if it is to be recorded. It returns the request with the exchange in its
+// context, for checkResponse.
+func (rt *route) checkRequest(req *http.Request) *http.Request {
+	path := rt.rewritePath(req.URL.Path)
+	if len(rt.balancer.upstreams) > 0 {
+		path = joinPath(rt.balancer.upstreams[0].target.Path, path)
+	}
+
+	x := &exchange{validate: rt.validates()}
+	for _, specification := range rt.specifications() {
+		if method, params := validate.Match(specification, req.Method, path); method != nil {
+			x.specification, x.method = specification, method
+			if x.validate {
+				x.problems = prefixed("request.", validate.Request(method, req, params))
+			}
+			break
+		}
+	}
+	if x.method == nil {
+		if !x.validate {
+			return req
+		}
+		x.problems = []validate.Problem{{Location: "request", Message: "no documented operation matches " + req.Method + " " + path}}
+	}
+
+	if x.method != nil && record.Enabled() {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			logger.Warnf(req, "Not recording %s %s: %s", req.Method, path, err)
+		} else {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			x.request = &record.Request{
+				Method: req.Method,
+				Path:   path,
+				Query:  strings.TrimSuffix(req.URL.RawQuery, "&"),
+				Header: req.Header.Clone(),
+				Body:   string(body),
+			}
+		}
+	}
+
+	// Responses are read, so are asked for uncompressed. The transport still has them
+	// compressed in transit, if the service supports it.
+	req.Header.Del("Accept-Encoding")
+
+	return req.WithContext(context.WithValue(req.Context(), exchangeKey, x))
+}
+
+func exchangeOf(req *http.Request) *exchange {
+	if req == nil {
+		return nil
+	}
+	x, _ := req.Context().Value(exchangeKey).(*exchange)
+	return x
+}
+
+// -----------------------------------------------------------------------------
+// checkResponse checks a response against the operation its request was matched to,
+// reporting the problems with the exchange if the route validates, and records the
+// exchange if its request was kept.
+func (rt *route) checkResponse(x *exchange, resp *http.Response) error {
+	var body []byte
+	if x.method != nil {
+		var err error
+		if body, err = ioutil.ReadAll(resp.Body); err != nil {
+			return err
+		}
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if x.request != nil {
+		rx := record.NewExchange(x.specification, x.method)
+		rx.Request = *x.request
+		rx.Response = record.Response{Status: resp.StatusCode, Header: resp.Header.Clone(), Body: string(body)}
+		if err := record.Save(rx); err != nil {
+			logger.Warnf(resp.Request, "Error recording %s %s: %s", x.request.Method, x.request.Path, err)
+		}
+	}
+	if x.validate {
+		report(x, resp, body)
+	}
+	return nil
+}
+
+// report logs the problems with an exchange, and reports them in the ContractHeader.
+func report(x *exchange, resp *http.Response, body []byte) {
+	problems := x.problems
+	if x.method != nil {
+		problems = append(problems, prefixed("response.", validate.Response(x.method, resp.StatusCode, resp.Header, body))...)
+	}
+	if len(problems) == 0 {
+		return
+	}
+
+	operation := "undocumented operation"
+	if x.method != nil {
+		operation = x.specification.ID + " " + x.method.ID
+	}
+	for _, p := range problems {
+		logger.Warnf(resp.Request, "CONTRACT-DRIFT %s %s (%s): %s", resp.Request.Method, resp.Request.URL.Path, operation, p)
+	}
+
+	if len(problems) > maxProblems {
+		problems = append(problems[:maxProblems:maxProblems], validate.Problem{Location: "response", Message: "more problems were found"})
+	}
+	buf, _ := json.Marshal(problems)
+	resp.Header.Set(ContractHeader, url.PathEscape(string(buf)))
+}
+
+func prefixed(prefix string, problems []validate.Problem) []validate.Problem {
+	for i := range problems {
+		problems[i].Location = prefix + problems[i].Location
+	}
+	return problems
+}
+
+// -----------------------------------------------------------------------------
@@ -17,17 +17,40 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
 package proxy
 
+// A proxied route forwards requests beneath its path to one of its targets, adding
+// any credential and applying its header and path rules. Idempotent requests that
+// fail are retried, and failures are answered with a structured error.
+
 import (
-	"github.com/wix/dapperdox/config"
-	"github.com/wix/dapperdox/logger"
-	"github.com/gorilla/pat"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"os"
+	"regexp"
 	"strings"
 	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/i18n"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/record"
+	"github.com/wix/dapperdox/render"
+	"github.com/wix/dapperdox/security"
+	"github.com/wix/dapperdox/spec"
+	"github.com/gorilla/pat"
 )
 
+const defaultTimeout = 30 * time.Second
+
 type responseCapture struct {
 	http.ResponseWriter
 	statusCode int
@@ -38,6 +61,19 @@ func (r *responseCapture) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+type rewrite struct {
+	from *regexp.Regexp
+	to   string
+}
+
+// route is a proxied route, built from its configuration.
+type route struct {
+	config.ProxyConfig
+	rewrites   []rewrite
+	credential string // Resolved value of the credential, if any
+	balancer   *balancer
+}
+
 // -----------------------------------------------------------------------------
 
 func Register(r *pat.Router) {
@@ -46,44 +82,46 @@ func Register(r *pat.Router) {
 	logger.Tracef(nil, "Registering proxied paths:\n")
 
 	for i := range cfg.ProxyPath {
-		slice := strings.Split(cfg.ProxyPath[i], "=")
-		switch len(slice) {
-		case 2:
-			register(r, slice[0], slice[1])
-		default:
-			panic("Invalid ProxyPath specified - does not contain an = delimited path=host/path pair")
-		}
+		// ProxyPath values have been validated by config.Get
+		pair, _ := config.ParsePair(cfg.ProxyPath[i])
+		register(r, config.ProxyConfig{Path: pair.From, Targets: []string{pair.To}})
+	}
+	for _, pc := range cfg.Proxies() {
+		register(r, pc)
 	}
 	logger.Tracef(nil, "Registering proxied paths done.\n")
 }
 
 // -----------------------------------------------------------------------------
 
-func register(r *pat.Router, routePattern string, target string) {
+func register(r *pat.Router, pc config.ProxyConfig) {
 
-	u, _ := url.Parse(target)
+	logger.Tracef(nil, "+ %s -> %s\n", pc.Path, strings.Join(pc.Targets, ", "))
 
-	logger.Tracef(nil, "+ %s -> %s\n", routePattern, target)
-
-	proxy := httputil.NewSingleHostReverseProxy(u)
-	od := proxy.Director
-
-	proxy.Director = func(r *http.Request) {
-		od(r)
-		r.Host = r.URL.Host // Rewrite Host
+	rt, err := newRoute(pc)
+	if err != nil {
+		logger.Errorf(nil, "Error configuring proxied path %s: %s", pc.Path, err)
+		os.Exit(1)
+	}
 
-		scheme := "http://"
-		if r.TLS != nil {
-			scheme = "https://"
-		}
-		logger.Debugf(r, "Proxy request to: %s%s%s", scheme, r.Host, r.URL.Path)
+	proxy := &httputil.ReverseProxy{
+		Director:       rt.direct,
+		Transport:      rt.balancer,
+		ModifyResponse: rt.modifyResponse,
+		ErrorHandler:   rt.fail,
 	}
 
-	r.PathPrefix(routePattern).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	security.RoutePrefix(security.Proxy, pc.Path)
+
+	r.PathPrefix(pc.Path).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rc := &responseCapture{w, 0}
 		s := time.Now()
 		logger.Tracef(r, "Proxy request started: %v", s)
 
+		if rt.validates() || record.Enabled() {
+			r = rt.checkRequest(r)
+		}
+
 		proxy.ServeHTTP(rc, r)
 
 		e := time.Now()
@@ -95,3 +133,209 @@ func register(r *pat.Router, routePattern string, target string) {
 }
 
 // -----------------------------------------------------------------------------
+// newRoute builds a route from its configuration, which has been validated by
+// config.Get, resolving its credential and loading its TLS files.
+func newRoute(pc config.ProxyConfig) (*route, error) {
+	rt := &route{ProxyConfig: pc}
+
+	if _, ok := spec.APISuite[pc.Specification]; len(pc.Specification) > 0 && !ok {
+		return nil, fmt.Errorf("specification %s is not loaded", pc.Specification)
+	}
+	for _, rw := range pc.Rewrite {
+		rt.rewrites = append(rt.rewrites, rewrite{regexp.MustCompile(rw.From), rw.To})
+	}
+
+	if cred := pc.Credential; cred != nil {
+		switch {
+		case len(cred.Value) > 0:
+			rt.credential = cred.Value
+		case len(cred.Env) > 0:
+			rt.credential = os.Getenv(cred.Env)
+		case len(cred.File) > 0:
+			buf, err := ioutil.ReadFile(cred.File)
+			if err != nil {
+				return nil, err
+			}
+			rt.credential = strings.TrimSpace(string(buf))
+		}
+		if len(rt.credential) == 0 {
+			return nil, fmt.Errorf("credential is empty")
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if pc.TLS != nil {
+		tlsConfig, err := clientTLS(pc.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	timeout := defaultTimeout
+	if len(pc.Timeout) > 0 {
+		timeout, _ = time.ParseDuration(pc.Timeout)
+	}
+
+	var err error
+	rt.balancer, err = newBalancer(pc.Path, pc.Targets, transport, timeout, pc.Retries)
+	if err != nil {
+		return nil, err
+	}
+	if pc.HealthCheck != nil {
+		go rt.balancer.check(pc.HealthCheck)
+	}
+	return rt, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func clientTLS(t *config.ProxyTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if len(t.CA) > 0 {
+		pem, err := ioutil.ReadFile(t.CA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CA)
+		}
+	}
+	if len(t.Certificate) > 0 {
+		certificate, err := tls.LoadX509KeyPair(t.Certificate, t.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// -----------------------------------------------------------------------------
+// direct prepares a request for the upstream: rewriting its path, applying the
+// header rules and adding the credential. The balancer chooses its target.
+func (rt *route) direct(req *http.Request) {
+	req.URL.Path = rt.rewritePath(req.URL.Path)
+	req.URL.RawPath = ""
+
+	req.Header.Del(security.CSRFHeader) // DapperDox's own, of no use to the upstream
+	removeOwnCookies(req)
+	applyHeaders(req.Header, rt.RequestHeaders)
+
+	if cred := rt.Credential; cred != nil {
+		switch cred.Type {
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+rt.credential)
+		case "basic":
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(rt.credential)))
+		case "header":
+			req.Header.Set(cred.Name, rt.credential)
+		case "query":
+			query := req.URL.Query()
+			query.Set(cred.Name, rt.credential)
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "") // Prevent the default Go user agent being sent
+	}
+}
+
+// removeOwnCookies removes DapperDox's own cookies from a request, keeping any others
+// the upstream may have set.
+func removeOwnCookies(req *http.Request) {
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		switch cookie.Name {
+		case publish.CookieName, security.CSRFCookieName, i18n.CookieName:
+			continue
+		}
+		req.AddCookie(cookie)
+	}
+}
+
+// rewritePath returns the path a request is forwarded with, relative to its target.
+func (rt *route) rewritePath(path string) string {
+	if rt.StripPrefix {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, rt.Path), "/")
+	}
+	for _, rw := range rt.rewrites {
+		if rw.from.MatchString(path) {
+			return rw.from.ReplaceAllString(path, rw.to)
+		}
+	}
+	return path
+}
+
+// -----------------------------------------------------------------------------
+// modifyResponse applies the response header rules and any CORS policy, which
+// replaces that of the upstream, and checks and records the response of a matched
+// request.
+func (rt *route) modifyResponse(resp *http.Response) error {
+	if x := exchangeOf(resp.Request); x != nil {
+		if err := rt.checkResponse(x, resp); err != nil {
+			return err
+		}
+	}
+	if security.HasCORS(security.Proxy) {
+		for name := range resp.Header {
+			if strings.HasPrefix(http.CanonicalHeaderKey(name), "Access-Control-") {
+				resp.Header.Del(name)
+			}
+		}
+		security.CORS(security.Proxy, resp.Request, resp.Header)
+	}
+	applyHeaders(resp.Header, rt.ResponseHeaders)
+	return nil
+}
+
+func applyHeaders(header http.Header, rules config.ProxyHeaders) {
+	for _, name := range rules.Remove {
+		header.Del(name)
+	}
+	for name, value := range rules.Set {
+		header.Set(name, value)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// fail answers a request that could not be proxied: with the error page to a
+// browser navigating to it, and otherwise, as to the explorer, with a JSON error.
+func (rt *route) fail(w http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		return // The client has gone away
+	}
+	logger.Warnf(req, "Proxy request to %s failed: %s", rt.Path, err)
+
+	status := http.StatusBadGateway
+	message := "could not be reached"
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoUpstream):
+		status, message = http.StatusServiceUnavailable, "is unavailable"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		status, message = http.StatusGatewayTimeout, "did not respond in time"
+	}
+	message = fmt.Sprintf("The service behind %s %s", rt.Path, message)
+
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		render.HTML(w, status, "error", render.DefaultVars(req, nil, render.Vars{"error": message, "code": status}))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"error":   http.StatusText(status),
+		"message": message,
+	})
+}
+
+// -----------------------------------------------------------------------------
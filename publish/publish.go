@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package publish
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/network"
+)
+
+// CookieName is the cookie that holds a reader's preview mode session.
+const CookieName = "dapperdox-preview"
+
+// ---------------------------------------------------------------------------
+// Preview returns true if the request was made in preview mode, in which
+// unapproved specifications and draft guides are shown.
+func Preview(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	cookie, err := req.Cookie(CookieName)
+	if err != nil {
+		return false
+	}
+	cfg, _ := config.Get()
+
+	if len(cfg.PreviewToken) == 0 {
+		return cookie.Value == Session() && network.IsLoopback(req)
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(Session())) == 1
+}
+
+// ---------------------------------------------------------------------------
+// Session returns the value of the preview cookie: an HMAC keyed by preview-token,
+// so that the token itself is not stored by browsers, or 1 if there is no token.
+func Session() string {
+	cfg, _ := config.Get()
+
+	if len(cfg.PreviewToken) == 0 {
+		return "1"
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.PreviewToken))
+	mac.Write([]byte(CookieName))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ---------------------------------------------------------------------------
+// Allowed returns true if value, given by ?preview= or the preview cookie, grants
+// preview mode. It must match preview-token, or if there is none, be given by a
+// loopback client.
+func Allowed(req *http.Request, value string) bool {
+	cfg, _ := config.Get()
+
+	if len(cfg.PreviewToken) == 0 {
+		return len(value) > 0 && value != "0" && network.IsLoopback(req)
+	}
+	return subtle.ConstantTimeCompare([]byte(value), []byte(cfg.PreviewToken)) == 1
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,183 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package record
+
+// An exchange's response may be promoted to the documented example of its operation
+// and status. Promoted examples are kept in record-dir/examples.json, by
+// specification and operation, and are shown on reference pages and answered by the
+// mock.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/cache"
+	"github.com/wix/dapperdox/spec"
+)
+
+const examplesFile = "examples.json"
+
+// Example is the documented example response of an operation, promoted from an
+// exchange.
+type Example struct {
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"`
+	Exchange    string `json:"exchange"` // ID of the exchange it was promoted from
+}
+
+// Specification ID->Operation key->Status->Example. Operations are keyed by verb and
+// path, which stay the same when their IDs are disambiguated differently.
+type examples map[string]map[string]map[string]*Example
+
+var (
+	promoted     = make(examples)
+	exampleMutex sync.RWMutex
+)
+
+// ---------------------------------------------------------------------------
+// Register loads the promoted examples, if exchanges are recorded.
+func Register() {
+	cfg, _ := config.Get()
+	if !Enabled() {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(cfg.RecordDir, examplesFile))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(buf, &promoted)
+	}
+	if err != nil {
+		logger.Errorf(nil, "Error reading promoted examples from %s: %s", cfg.RecordDir, err)
+		os.Exit(1)
+	}
+	logger.Debugf(nil, "Loaded promoted examples of %d specifications", len(promoted))
+}
+
+func operationKey(method *spec.Method) string {
+	return strings.ToUpper(method.Method) + " " + method.Path
+}
+
+// ---------------------------------------------------------------------------
+// Promoted returns the promoted example of an operation of a specification for a
+// status, or nil if there is none.
+func Promoted(specification string, method *spec.Method, status int) *Example {
+	exampleMutex.RLock()
+	defer exampleMutex.RUnlock()
+
+	return promoted[specification][operationKey(method)][strconv.Itoa(status)]
+}
+
+// ---------------------------------------------------------------------------
+// Promote makes the response of an exchange the documented example of its operation
+// for the response's status.
+func Promote(id string) (*Example, error) {
+	cfg, _ := config.Get()
+
+	x, err := Load(id)
+	if err != nil {
+		return nil, err
+	}
+	method := findMethod(x)
+	if method == nil {
+		return nil, fmt.Errorf("%s %s is no longer documented", x.Request.Method, x.Request.Path)
+	}
+
+	example := &Example{
+		ContentType: x.Response.Header.Get("Content-Type"),
+		Body:        x.Response.Body,
+		Exchange:    x.ID,
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(example.Body), &v) == nil {
+		buf, _ := json.MarshalIndent(v, "", "  ")
+		example.Body = string(buf)
+	}
+
+	exampleMutex.Lock()
+	defer exampleMutex.Unlock()
+
+	operations := promoted[x.Specification]
+	if operations == nil {
+		operations = make(map[string]map[string]*Example)
+		promoted[x.Specification] = operations
+	}
+	key := operationKey(method)
+	if operations[key] == nil {
+		operations[key] = make(map[string]*Example)
+	}
+	operations[key][strconv.Itoa(x.Response.Status)] = example
+
+	buf, err := json.MarshalIndent(promoted, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(cfg.RecordDir, examplesFile), append(buf, '\n'), os.FileMode(0644)); err != nil {
+		return nil, err
+	}
+	cache.Purge() // Reference pages show the example
+
+	logger.Infof(nil, "Promoted exchange %s to the example %d response of %s", x.ID, x.Response.Status, key)
+	return example, nil
+}
+
+// ---------------------------------------------------------------------------
+// IsExample returns true if the exchange's response is the promoted example of its
+// operation.
+func (x *Exchange) IsExample() bool {
+	method := findMethod(x)
+	if method == nil {
+		return false
+	}
+	example := Promoted(x.Specification, method, x.Response.Status)
+	return example != nil && example.Exchange == x.ID
+}
+
+// findMethod returns the documented operation of an exchange, or nil if it is no
+// longer documented.
+func findMethod(x *Exchange) *spec.Method {
+	specification, ok := spec.APISuite[x.Specification]
+	if !ok {
+		return nil
+	}
+	for a := range specification.APIs {
+		api := &specification.APIs[a]
+		if api.ID != x.API {
+			continue
+		}
+		for m := range api.Methods {
+			if api.Methods[m].ID == x.Operation {
+				return &api.Methods[m]
+			}
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// end
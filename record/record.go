@@ -0,0 +1,314 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package record
+
+// The recorder keeps the exchanges of proxied routes with the operations they match,
+// as JSON files beneath record-dir/<specification-id>/<api-id>/<operation-id>, named
+// by status and time. Credentials, and the headers, query parameters and JSON fields
+// given by record-redact, are redacted before an exchange is written.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/spec"
+)
+
+// Redacted replaces redacted values.
+const Redacted = "REDACTED"
+
+const (
+	keep    = 20      // Exchanges kept of each operation and status, the latest
+	maxBody = 1 << 20 // Bytes of a body recorded, beyond which the exchange is not
+)
+
+// Headers always redacted
+var redactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-CSRF-Token"}
+
+// Exchange IDs, which are the paths of their files beneath record-dir
+var idRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*(/[A-Za-z0-9_][A-Za-z0-9_.-]*){2}/[0-9]{3}-[0-9]+$`)
+
+var mutex sync.Mutex
+
+// Exchange is a recorded request to an operation, and the response to it.
+type Exchange struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	Specification string    `json:"specification"` // ID of the specification
+	API           string    `json:"api"`           // ID of the API
+	Operation     string    `json:"operation"`     // ID of the method, within its API
+	Request       Request   `json:"request"`
+	Response      Response  `json:"response"`
+}
+
+// Request is a recorded request. Its path is that the operation is documented with,
+// beneath the specification's base path, rather than that of the proxied route.
+type Request struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// ---------------------------------------------------------------------------
+// Enabled returns true if exchanges are recorded.
+func Enabled() bool {
+	cfg, _ := config.Get()
+	return len(cfg.RecordDir) > 0
+}
+
+// ---------------------------------------------------------------------------
+// NewExchange returns an exchange with an operation of a specification, as matched
+// by validate.Match, to be given its request and response.
+func NewExchange(specification *spec.APISpecification, method *spec.Method) *Exchange {
+	x := &Exchange{Specification: specification.ID, Operation: method.ID}
+	for a := range specification.APIs {
+		api := &specification.APIs[a]
+		for m := range api.Methods {
+			if &api.Methods[m] == method {
+				x.API = api.ID
+			}
+		}
+	}
+	return x
+}
+
+// ---------------------------------------------------------------------------
+// Save redacts and writes an exchange with an operation, giving it its ID and time,
+// and discards the oldest exchanges of the operation and status beyond those kept.
+func Save(x *Exchange) error {
+	cfg, _ := config.Get()
+
+	if len(x.Request.Body) > maxBody || len(x.Response.Body) > maxBody {
+		logger.Debugf(nil, "Not recording %s %s: body is too large", x.Request.Method, x.Request.Path)
+		return nil
+	}
+
+	x.Time = time.Now()
+	x.ID = fmt.Sprintf("%s/%s/%s/%03d-%d", x.Specification, x.API, x.Operation, x.Response.Status, x.Time.UnixNano())
+	if !idRegex.MatchString(x.ID) {
+		return fmt.Errorf("cannot record exchange %s", x.ID)
+	}
+	x.redact()
+
+	buf, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	dir := filepath.Join(cfg.RecordDir, x.Specification, x.API, x.Operation)
+	if err = os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(cfg.RecordDir, filepath.FromSlash(x.ID)+".json"), append(buf, '\n'), os.FileMode(0644)); err != nil {
+		return err
+	}
+	logger.Debugf(nil, "Recorded exchange %s", x.ID)
+
+	return prune(dir, fmt.Sprintf("%03d-", x.Response.Status))
+}
+
+// prune removes the oldest exchanges of a status from an operation's directory,
+// beyond those kept.
+func prune(dir string, prefix string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), prefix) && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names) // Oldest first, as times are of the same length
+	for len(names) > keep {
+		if err = os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// redact replaces the values of redacted headers, query parameters and JSON fields.
+func (x *Exchange) redact() {
+	cfg, _ := config.Get()
+
+	names := make(map[string]bool)
+	for _, name := range append(redactHeaders, cfg.RecordRedact...) {
+		names[strings.ToLower(name)] = true
+	}
+
+	redactHeader(x.Request.Header, names)
+	redactHeader(x.Response.Header, names)
+
+	if query, err := url.ParseQuery(x.Request.Query); err == nil && len(query) > 0 {
+		redacted := false
+		for name := range query {
+			if names[strings.ToLower(name)] {
+				query[name] = []string{Redacted}
+				redacted = true
+			}
+		}
+		if redacted {
+			x.Request.Query = query.Encode()
+		}
+	}
+
+	x.Request.Body = redactBody(x.Request.Body, names)
+	x.Response.Body = redactBody(x.Response.Body, names)
+}
+
+func redactHeader(header http.Header, names map[string]bool) {
+	for name := range header {
+		if names[strings.ToLower(name)] {
+			header[name] = []string{Redacted}
+		}
+	}
+}
+
+// redactBody redacts the fields of a JSON body, at any depth. Other bodies are
+// returned as they are.
+func redactBody(body string, names map[string]bool) string {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal([]byte(body), &v) != nil {
+		return body
+	}
+	if !redactValue(v, names) {
+		return body
+	}
+	buf, _ := json.MarshalIndent(v, "", "  ")
+	return string(buf)
+}
+
+func redactValue(v interface{}, names map[string]bool) bool {
+	redacted := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if names[strings.ToLower(key)] {
+				t[key] = Redacted
+				redacted = true
+			} else if redactValue(value, names) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, value := range t {
+			if redactValue(value, names) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+// ---------------------------------------------------------------------------
+// List returns the recorded exchanges, ordered by specification, API, operation and
+// status, latest first.
+func List() ([]*Exchange, error) {
+	cfg, _ := config.Get()
+
+	var list []*Exchange
+	err := filepath.Walk(cfg.RecordDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == cfg.RecordDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(cfg.RecordDir, path)
+		id := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if info.IsDir() || !idRegex.MatchString(id) {
+			return nil
+		}
+		x, err := Load(id)
+		if err != nil {
+			return err
+		}
+		list = append(list, x)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.Specification != b.Specification:
+			return a.Specification < b.Specification
+		case a.API != b.API:
+			return a.API < b.API
+		case a.Operation != b.Operation:
+			return a.Operation < b.Operation
+		case a.Response.Status != b.Response.Status:
+			return a.Response.Status < b.Response.Status
+		}
+		return a.Time.After(b.Time)
+	})
+	return list, nil
+}
+
+// ---------------------------------------------------------------------------
+// Load reads the exchange with an ID.
+func Load(id string) (*Exchange, error) {
+	cfg, _ := config.Get()
+
+	if !idRegex.MatchString(id) {
+		return nil, fmt.Errorf("%s is not an exchange", id)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(cfg.RecordDir, filepath.FromSlash(id)+".json"))
+	if err != nil {
+		return nil, err
+	}
+	x := &Exchange{}
+	if err = json.Unmarshal(buf, x); err != nil {
+		return nil, fmt.Errorf("%s: %s", id, err)
+	}
+	x.ID = id
+	return x, nil
+}
+
+// ---------------------------------------------------------------------------
+// end
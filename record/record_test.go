@@ -0,0 +1,95 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package record
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/wix/dapperdox/config"
+)
+
+func TestRedact(t *testing.T) {
+	cfg, _ := config.Get()
+	cfg.RecordRedact = []string{"Password", "api_key"}
+	defer func() { cfg.RecordRedact = nil }()
+
+	tests := []struct {
+		name     string
+		exchange Exchange
+		want     Exchange
+	}{
+		{
+			"default headers",
+			Exchange{
+				Request:  Request{Header: http.Header{"Authorization": {"Bearer t"}, "Cookie": {"a=b"}, "Accept": {"*/*"}}},
+				Response: Response{Header: http.Header{"Set-Cookie": {"a=c"}, "Content-Type": {"text/plain"}}},
+			},
+			Exchange{
+				Request:  Request{Header: http.Header{"Authorization": {Redacted}, "Cookie": {Redacted}, "Accept": {"*/*"}}},
+				Response: Response{Header: http.Header{"Set-Cookie": {Redacted}, "Content-Type": {"text/plain"}}},
+			},
+		},
+		{
+			"configured header",
+			Exchange{Request: Request{Header: http.Header{"Api_key": {"k"}}}},
+			Exchange{Request: Request{Header: http.Header{"Api_key": {Redacted}}}},
+		},
+		{
+			"query",
+			Exchange{Request: Request{Query: "api_key=k&limit=10"}},
+			Exchange{Request: Request{Query: "api_key=" + Redacted + "&limit=10"}},
+		},
+		{
+			"query without redacted parameters",
+			Exchange{Request: Request{Query: "limit=10&offset=5"}},
+			Exchange{Request: Request{Query: "limit=10&offset=5"}},
+		},
+		{
+			"nested body fields",
+			Exchange{
+				Request:  Request{Body: `{"user":{"name":"a","password":"p"}}`},
+				Response: Response{Body: `[{"API_KEY":"k","id":1}]`},
+			},
+			Exchange{
+				Request:  Request{Body: "{\n  \"user\": {\n    \"name\": \"a\",\n    \"password\": \"" + Redacted + "\"\n  }\n}"},
+				Response: Response{Body: "[\n  {\n    \"API_KEY\": \"" + Redacted + "\",\n    \"id\": 1\n  }\n]"},
+			},
+		},
+		{
+			"body without redacted fields",
+			Exchange{Request: Request{Body: `{"name":"a"}`}},
+			Exchange{Request: Request{Body: `{"name":"a"}`}},
+		},
+		{
+			"body that is not JSON",
+			Exchange{Request: Request{Body: "password=p"}},
+			Exchange{Request: Request{Body: "password=p"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x := test.exchange
+			x.redact()
+			if !reflect.DeepEqual(x, test.want) {
+				t.Errorf("got %+v, want %+v", x, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package record
+
+// Replay sends recorded requests to an environment and compares the responses with
+// those recorded. Values differ from one response to the next, so only the status,
+// content type and structure of JSON bodies are compared.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/spec"
+)
+
+const replayTimeout = 30 * time.Second
+
+// Methods replayed without replay-unsafe, as they should not change state
+var safeMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+}
+
+// Headers not replayed, as the client sets them for the request it sends
+var unreplayedHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+}
+
+// ---------------------------------------------------------------------------
+// Replay is the replay command. It sends every recorded request to an environment,
+// given by the name of an environment of the exchange's specification, or by URL,
+// prints the exchanges whose responses differ, and returns the exit status: 1 if
+// any differ. Requests that may change state are skipped unless unsafe is true.
+func Replay(environment string, unsafe bool) int {
+	exchanges, err := List()
+	if err != nil {
+		logger.Errorf(nil, "Error reading recorded exchanges: %s", err)
+		return 1
+	}
+	logger.Infof(nil, "Replaying %d exchanges against %s", len(exchanges), environment)
+
+	client := &http.Client{Timeout: replayTimeout}
+	differ, skipped := 0, 0
+	for _, x := range exchanges {
+		if !unsafe && !safeMethods[strings.ToUpper(x.Request.Method)] {
+			logger.Debugf(nil, "Not replaying %s %s (%s): it may change state", x.Request.Method, x.Request.Path, x.ID)
+			skipped++
+			continue
+		}
+		differences := x.replay(client, environment)
+		if len(differences) == 0 {
+			continue
+		}
+		differ++
+		fmt.Printf("%s %s (%s, recorded %s)\n", x.Request.Method, x.Request.Path, x.ID, x.Time.Format(time.RFC3339))
+		for _, d := range differences {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+	fmt.Printf("%d exchanges replayed, %d differ", len(exchanges)-skipped, differ)
+	if skipped > 0 {
+		fmt.Printf(", %d skipped as they may change state (see replay-unsafe)", skipped)
+	}
+	fmt.Println()
+
+	if differ > 0 {
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// replay sends the request of an exchange to an environment, and returns the ways
+// in which the response differs from that recorded. The environment's URL includes
+// the specification's basePath, which the recorded path is stripped of.
+func (x *Exchange) replay(client *http.Client, environment string) []string {
+	base, err := environmentURL(x.Specification, environment)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	path := x.Request.Path
+	if s, ok := spec.APISuite[x.Specification]; ok {
+		path = strings.TrimPrefix(path, s.BasePath)
+	}
+	target := strings.TrimSuffix(base, "/") + path
+	query, _ := url.ParseQuery(x.Request.Query)
+	for name, values := range query {
+		if len(values) == 1 && values[0] == Redacted {
+			delete(query, name)
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(x.Request.Method, target, bytes.NewReader([]byte(x.Request.Body)))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	for name, values := range x.Request.Header {
+		if unreplayedHeaders[http.CanonicalHeaderKey(name)] || (len(values) == 1 && values[0] == Redacted) {
+			continue
+		}
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var differences []string
+	if resp.StatusCode != x.Response.Status {
+		differences = append(differences, fmt.Sprintf("status: %d, was %d", resp.StatusCode, x.Response.Status))
+	}
+	was, is := mediaType(x.Response.Header.Get("Content-Type")), mediaType(resp.Header.Get("Content-Type"))
+	if was != is {
+		differences = append(differences, fmt.Sprintf("content type: %s, was %s", describeType(is), describeType(was)))
+	}
+
+	var wasBody, isBody interface{}
+	if json.Unmarshal([]byte(x.Response.Body), &wasBody) == nil && json.Unmarshal(body, &isBody) == nil {
+		differences = append(differences, compare("body", wasBody, isBody)...)
+	}
+	return differences
+}
+
+// environmentURL returns the URL of a specification's environment, given by name or
+// URL.
+func environmentURL(specification string, environment string) (string, error) {
+	if strings.Contains(environment, "://") {
+		return environment, nil
+	}
+	if s, ok := spec.APISuite[specification]; ok {
+		for _, e := range s.Environments {
+			if strings.EqualFold(e.Name, environment) {
+				return e.URL, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("specification %s has no environment %s", specification, environment)
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return t
+}
+
+func describeType(t string) string {
+	if len(t) == 0 {
+		return "none"
+	}
+	return t
+}
+
+// ---------------------------------------------------------------------------
+// compare returns the differences in structure between two JSON values: fields
+// that are missing or new, and values of a different type. Array elements are
+// compared by their first. Null is taken to be of any type.
+func compare(location string, was interface{}, is interface{}) []string {
+	if was == nil || is == nil {
+		return nil
+	}
+	if kindOf(was) != kindOf(is) {
+		return []string{fmt.Sprintf("%s: %s, was %s", location, kindOf(is), kindOf(was))}
+	}
+
+	var differences []string
+	switch w := was.(type) {
+	case map[string]interface{}:
+		i := is.(map[string]interface{})
+		var keys []string
+		for key := range w {
+			keys = append(keys, key)
+		}
+		for key := range i {
+			if _, ok := w[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			wv, wok := w[key]
+			iv, iok := i[key]
+			switch {
+			case !iok:
+				differences = append(differences, location+"."+key+": is missing")
+			case !wok:
+				differences = append(differences, location+"."+key+": is new")
+			default:
+				differences = append(differences, compare(location+"."+key, wv, iv)...)
+			}
+		}
+	case []interface{}:
+		i := is.([]interface{})
+		if len(w) > 0 && len(i) > 0 {
+			differences = append(differences, compare(location+"[0]", w[0], i[0])...)
+		}
+	}
+	return differences
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -31,6 +31,7 @@ import (
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/wix/dapperdox/config"
 	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/theme"
 	"github.com/shurcooL/github_flavored_markdown"
 	"io/ioutil"
 	"os"
@@ -41,6 +42,8 @@ import (
 
 var _bindata = map[string][]byte{}
 var _metadata = map[string]map[string]string{}
+var _sources = map[string]string{} // Directory or archive each asset was compiled from
+var compiling string
 var guideReplacer *strings.Replacer
 var gfmReplace []*gfmReplacer
 
@@ -65,6 +68,13 @@ func AssetNames() []string {
 	return names
 }
 
+// ---------------------------------------------------------------------------
+// Source returns the directory or archive an asset was compiled from, or "" if it
+// is not known.
+func Source(name string) string {
+	return _sources[strings.Replace(name, "\\", "/", -1)]
+}
+
 // ---------------------------------------------------------------------------
 func MetaData(filename string, name string) string {
 	if md, ok := _metadata[filename]; ok {
@@ -89,24 +99,7 @@ func MetaDataFileList() []string {
 // ---------------------------------------------------------------------------
 func Compile(dir string, prefix string) {
 
-	cfg, _ := config.Get()
-
-	// Build a replacer to search/replace Document URLs in the documents.
-	if guideReplacer == nil {
-		var replacements []string
-
-		// Configure the replacer with key=value pairs
-		for i := range cfg.DocumentRewriteURL {
-
-			slice := strings.Split(cfg.DocumentRewriteURL[i], "=")
-
-			if len(slice) != 2 {
-				panic("Invalid DocumentWriteUrl - does not contain an = delimited from=to pair")
-			}
-			replacements = append(replacements, slice...)
-		}
-		guideReplacer = strings.NewReplacer(replacements...)
-	}
+	buildReplacer()
 
 	dir, err := filepath.Abs(dir)
 	if err != nil {
@@ -116,6 +109,7 @@ func Compile(dir string, prefix string) {
 	logger.Debugf(nil, "- Scanning directory %s", dir)
 
 	dir = filepath.ToSlash(dir)
+	compiling = dir
 
 	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		path = filepath.Clean(filepath.ToSlash(path))
@@ -132,11 +126,6 @@ func Compile(dir string, prefix string) {
 			return nil
 		}
 
-		ext := ""
-		if strings.Index(path, ".") != -1 {
-			ext = filepath.Ext(path)
-		}
-
 		buf, err := ioutil.ReadFile(path)
 		if err != nil {
 			panic(err)
@@ -147,58 +136,113 @@ func Compile(dir string, prefix string) {
 			panic(err)
 		}
 
-		var meta map[string]string
+		compileFile(prefix, relative, buf)
+
+		return nil
+	})
+}
+
+// ---------------------------------------------------------------------------
+// buildReplacer builds the replacer to search/replace Document URLs in the documents.
+func buildReplacer() {
+	if guideReplacer != nil {
+		return
+	}
+	cfg, _ := config.Get()
+
+	var replacements []string
 
-		switch ext {
-		// The file may be in GFM, so convert to HTML and process any embedded metadata
-		case ".md":
-			// Chop off the extension
-			mdname := strings.TrimSuffix(relative, ext)
+	// Configure the replacer with key=value pairs, validated by config.Get
+	for i := range cfg.DocumentRewriteURL {
+		pair, _ := config.ParsePair(cfg.DocumentRewriteURL[i])
+		replacements = append(replacements, pair.From, pair.To)
+	}
+	guideReplacer = strings.NewReplacer(replacements...)
+}
 
-			buf, meta = ProcessMetadata(buf)
+// ---------------------------------------------------------------------------
+// compileFile compiles a template, markdown document or static file, given by its
+// name relative to the directory being compiled.
+func compileFile(prefix string, relative string, buf []byte) {
+	ext := ""
+	if strings.Index(relative, ".") != -1 {
+		ext = filepath.Ext(relative)
+	}
 
-			// This resource may be metadata tagged as a page section overlay..
-			if overlay, ok := meta["overlay"]; ok && strings.ToLower(overlay) == "true" {
+	var meta map[string]interface{}
 
-				// Chop markdown into sections
-				sections, headings := splitOnSection(string(buf))
+	switch ext {
+	// The file may be in GFM, so convert to HTML and process any embedded metadata
+	case ".md":
+		// Chop off the extension
+		mdname := strings.TrimSuffix(relative, ext)
 
-				if sections == nil {
-					logger.Errorf(nil, "  * Error no sections defined in overlay file %s\n", relative)
-					os.Exit(1)
-				}
+		buf, meta = compileMetadata(buf, relative)
 
-				for i, heading := range headings {
-					buf = ProcessMarkdown([]byte(sections[i]))
+		// This resource may be metadata tagged as a page section overlay..
+		if overlay := metadataString(meta["overlay"]); strings.ToLower(overlay) == "true" {
 
-					relative = filepath.Join(mdname, heading, "overlay.tmpl")
-					storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
-				}
-			} else {
-				buf = ProcessMarkdown(buf) // Convert markdown into HTML
+			// Chop markdown into sections
+			sections, headings := splitOnSection(string(buf))
 
-				relative = mdname + ".tmpl"
-				storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+			if sections == nil {
+				logger.Errorf(nil, "  * Error no sections defined in overlay file %s\n", relative)
+				os.Exit(1)
 			}
-		case ".tmpl":
-			buf, meta = ProcessMetadata(buf)
-			storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
 
-		case ".html":
-			logger.Errorf(nil, "  * Error - Refusing to process .html files. Expects HTML template fragments with .tmpl extension. File %s\n", relative)
-			os.Exit(1)
+			for i, heading := range headings {
+				relative = filepath.Join(mdname, heading, "overlay.tmpl")
+				doc, widgets := compileShortcodes([]byte(sections[i]), prefix, relative, meta, true)
+				buf = expandWidgets(ProcessMarkdown(doc), widgets)
+
+				storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+			}
+		} else {
+			relative = mdname + ".tmpl"
+			doc, widgets := compileShortcodes(buf, prefix, relative, meta, true)
+			buf = expandWidgets(ProcessMarkdown(doc), widgets) // Convert markdown into HTML
 
-		default:
 			storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
 		}
+	case ".tmpl":
+		buf, meta = compileMetadata(buf, relative)
+		doc, widgets := compileShortcodes(buf, prefix, relative, meta, false)
+		buf = expandWidgets(doc, widgets)
+		storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+
+	case ".html":
+		logger.Errorf(nil, "  * Error - Refusing to process .html files. Expects HTML template fragments with .tmpl extension. File %s\n", relative)
+		os.Exit(1)
+
+	default:
+		storeTemplate(prefix, relative, guideReplacer.Replace(string(buf)), meta)
+	}
+}
 
-		return nil
-	})
+// ---------------------------------------------------------------------------
+// CompileSource compiles the files of a theme directory or archive, as Compile does a
+// directory.
+func CompileSource(source *theme.Source, prefix string) {
+	if !source.IsArchive() {
+		Compile(source.Path, prefix)
+		return
+	}
+	buildReplacer()
+
+	logger.Debugf(nil, "- Scanning archive %s", source.Path)
+	compiling = source.Path
+	for _, name := range source.Files() {
+		buf, err := source.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		compileFile(prefix, name, buf)
+	}
 }
 
 // ---------------------------------------------------------------------------
 
-func storeTemplate(prefix string, name string, template string, meta map[string]string) {
+func storeTemplate(prefix string, name string, template string, meta map[string]interface{}) {
 
 	newname := filepath.ToSlash(filepath.Join(prefix, name))
 
@@ -206,13 +250,34 @@ func storeTemplate(prefix string, name string, template string, meta map[string]
 		logger.Debugf(nil, "  + Import %s", newname)
 		// Store the template, doing and search/replaces on the way
 		_bindata[newname] = []byte(template)
+		_sources[newname] = compiling
 		if len(meta) > 0 {
 			logger.Tracef(nil, "    + Adding metadata")
-			_metadata[newname] = meta
+			_metadata[newname] = metadataStrings(meta)
+
+			guide, err := newGuideMetadata(meta)
+			if err != nil {
+				logger.Errorf(nil, "  * Error in metadata of %s: %s\n", name, err)
+				os.Exit(1)
+			}
+			guide.URL = guideURL(newname)
+			_guideMetadata[newname] = guide
 		}
 	}
 }
 
+// ---------------------------------------------------------------------------
+// compileShortcodes resolves the cross-link shortcodes of a document, unless it is
+// overridden by one already compiled. markdown is true if the document is to be
+// converted from markdown.
+func compileShortcodes(doc []byte, prefix string, name string, meta map[string]interface{}, markdown bool) ([]byte, []string) {
+	newname := filepath.ToSlash(filepath.Join(prefix, name))
+	if _, ok := _bindata[newname]; ok {
+		return doc, nil
+	}
+	return resolveShortcodes(doc, newname, meta, markdown)
+}
+
 // ---------------------------------------------------------------------------
 // Returns rendered markdown
 func ProcessMarkdown(doc []byte) []byte {
@@ -226,8 +291,36 @@ func ProcessMarkdown(doc []byte) []byte {
 }
 
 // ---------------------------------------------------------------------------
-// Strips and processed metadata from markdown document
+// Strips and processed metadata from markdown document. Metadata is either YAML front
+// matter delimited by --- lines, or leading "Key: value" lines. Keys are lowercased.
 func ProcessMetadata(doc []byte) ([]byte, map[string]string) {
+	newdoc, meta, err := processMetadata(doc)
+	if err != nil {
+		logger.Errorf(nil, "  * Error in metadata: %s\n", err)
+		os.Exit(1)
+	}
+	return newdoc, metadataStrings(meta)
+}
+
+// ---------------------------------------------------------------------------
+
+func compileMetadata(doc []byte, relative string) ([]byte, map[string]interface{}) {
+	newdoc, meta, err := processMetadata(doc)
+	if err != nil {
+		logger.Errorf(nil, "  * Error in metadata of %s: %s\n", relative, err)
+		os.Exit(1)
+	}
+	return newdoc, meta
+}
+
+// ---------------------------------------------------------------------------
+
+func processMetadata(doc []byte) ([]byte, map[string]interface{}, error) {
+
+	if front, rest, ok := splitFrontMatter(doc); ok {
+		meta, err := parseFrontMatter(front)
+		return rest, meta, err
+	}
 
 	// Inspect the markdown src doc to see if it contains metadata
 	reader := bytes.NewReader(doc)
@@ -235,14 +328,14 @@ func ProcessMetadata(doc []byte) ([]byte, map[string]string) {
 	scanner.Split(bufio.ScanLines)
 
 	var newdoc string
-	metaData := make(map[string]string)
+	metaData := make(map[string]interface{})
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		splitLine := strings.Split(line, ":")
+		splitLine := strings.SplitN(line, ":", 2) // Values may contain colons, as in URLs and times
 
 		trimmed := strings.TrimSpace(splitLine[0])
-		if (len(splitLine) < 2) || (!unicode.IsLetter(rune(trimmed[0]))) { // Have we reached a non KEY: line? If so, we're done with the metadata.
+		if (len(splitLine) < 2) || len(trimmed) == 0 || (!unicode.IsLetter(rune(trimmed[0]))) { // Have we reached a non KEY: line? If so, we're done with the metadata.
 			if len(line) > 0 { // If the line is not empty, keep the contents
 				newdoc = newdoc + line + "\n"
 			}
@@ -255,16 +348,11 @@ func ProcessMetadata(doc []byte) ([]byte, map[string]string) {
 		}
 
 		// Else, deal with meta-data
-		metaValue := ""
-		if len(splitLine) > 1 {
-			metaValue = strings.TrimSpace(splitLine[1])
-		}
-
 		metaKey := strings.ToLower(splitLine[0])
-		metaData[metaKey] = metaValue
+		metaData[metaKey] = strings.TrimSpace(splitLine[1])
 	}
 
-	return []byte(newdoc), metaData
+	return []byte(newdoc), metaData, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -300,53 +388,18 @@ func splitOnSection(text string) ([]string, []string) {
 
 func CompileGFMMap() {
 
-	var mapfile string
-
-	cfg, _ := config.Get()
-
-	if len(cfg.AssetsDir) != 0 {
-		mapfile = filepath.Join(cfg.AssetsDir, "gfm.map")
-		logger.Tracef(nil, "Looking in assets dir for %s\n", mapfile)
-		if _, err := os.Stat(mapfile); os.IsNotExist(err) {
-			mapfile = ""
-		}
-	}
-	if len(mapfile) == 0 && len(cfg.ThemeDir) != 0 {
-		mapfile = filepath.Join(cfg.ThemeDir, cfg.Theme, "gfm.map")
-		logger.Tracef(nil, "Looking in theme dir for %s\n", mapfile)
-		if _, err := os.Stat(mapfile); os.IsNotExist(err) {
-			mapfile = ""
-		}
-	}
-	if len(mapfile) == 0 {
-		mapfile = filepath.Join(cfg.DefaultAssetsDir, "themes", cfg.Theme, "gfm.map")
-		logger.Tracef(nil, "Looking in default theme dir for %s\n", mapfile)
-		if _, err := os.Stat(mapfile); os.IsNotExist(err) {
-			mapfile = ""
-		}
-	}
-	if len(mapfile) == 0 {
-		mapfile = filepath.Join(cfg.DefaultAssetsDir, "/themes/default/gfm.map")
-		logger.Tracef(nil, "Looking in default theme for %s\n", mapfile)
-		if _, err := os.Stat(mapfile); os.IsNotExist(err) {
-			mapfile = ""
-		}
+	buf, mapfile, err := theme.DataFile("gfm.map")
+	if err != nil {
+		logger.Errorf(nil, "Error: %s", err)
+		return
 	}
-
 	if len(mapfile) == 0 {
 		logger.Tracef(nil, "No GFM HTML mapfile found\n")
 		return
 	}
 	logger.Tracef(nil, "Processing GFM HTML mapfile: %s\n", mapfile)
-	file, err := os.Open(mapfile)
-
-	if err != nil {
-		logger.Errorf(nil, "Error: %s", err)
-		return
-	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
 
 	for scanner.Scan() {
 		line := scanner.Text()
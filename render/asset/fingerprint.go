@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package asset
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/wix/dapperdox/config"
+)
+
+const hashLength = 12 // Hex digits of content hash in static asset URLs
+
+var (
+	fingerprints sync.Map // Static asset path->Content hashed path
+	integrities  sync.Map // Static asset path->Subresource Integrity value
+)
+
+// ---------------------------------------------------------------------------
+// StaticURL returns the URL a page should use for a static asset, such as
+// /css/style.css: its content hashed path, which may be cached indefinitely, under
+// static-url if that is given.
+func StaticURL(urlPath string) string {
+	cfg, _ := config.Get()
+	return strings.TrimSuffix(cfg.StaticURL, "/") + StaticPath(urlPath)
+}
+
+// ---------------------------------------------------------------------------
+// StaticPath returns the content hashed path of a static asset, or the path
+// unchanged if there is no such asset.
+func StaticPath(urlPath string) string {
+	if hashed, ok := fingerprints.Load(urlPath); ok {
+		return hashed.(string)
+	}
+	buf, err := Asset("assets/static" + urlPath)
+	if err != nil {
+		return urlPath
+	}
+	hashed := HashedPath(urlPath, buf)
+	fingerprints.Store(urlPath, hashed)
+	return hashed
+}
+
+// ---------------------------------------------------------------------------
+// HashedPath inserts a hash of an asset's content before the extension of its path,
+// so that /css/style.css becomes /css/style.<hash>.css
+func HashedPath(urlPath string, content []byte) string {
+	ext := path.Ext(urlPath)
+	return strings.TrimSuffix(urlPath, ext) + "." + ContentHash(content) + ext
+}
+
+// ---------------------------------------------------------------------------
+// ContentHash returns a short hash of an asset's content.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// ---------------------------------------------------------------------------
+// Integrity returns the Subresource Integrity value of a static asset, if
+// subresource-integrity is set and there is such an asset, or "".
+func Integrity(urlPath string) string {
+	cfg, _ := config.Get()
+	if !cfg.StaticIntegrity {
+		return ""
+	}
+	if integrity, ok := integrities.Load(urlPath); ok {
+		return integrity.(string)
+	}
+	buf, err := Asset("assets/static" + urlPath)
+	if err != nil {
+		return ""
+	}
+	sum := sha512.Sum384(buf)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	integrities.Store(urlPath, integrity)
+	return integrity
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,199 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package asset
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// GuideMetadata is the typed form of a guide's metadata, given either as YAML front
+// matter delimited by --- lines, or in the legacy "Key: value" form. Legacy list
+// values are comma separated.
+type GuideMetadata struct {
+	Title             string
+	Tags              []string
+	Authors           []string
+	LastReviewed      time.Time // Zero if not given
+	RelatedOperations []string  // operationIds, or "METHOD /path"
+	RedirectFrom      []string  // Old URLs of the guide, redirected to it
+	Draft             bool
+	Status            string                 // Status badge, such as beta or deprecated
+	URL               string                 // The guide's page, or "" if the asset is not a guide
+	Fields            map[string]interface{} // All metadata, keyed by lowercased name
+}
+
+var _guideMetadata = map[string]*GuideMetadata{}
+
+var frontMatterDelimiter = []byte("---")
+
+// ---------------------------------------------------------------------------
+// Guide returns the typed metadata of a compiled asset, or nil if it has none.
+func Guide(filename string) *GuideMetadata {
+	return _guideMetadata[filename]
+}
+
+// ---------------------------------------------------------------------------
+// HasTag returns true if the guide is tagged with tag, ignoring case.
+func (g *GuideMetadata) HasTag(tag string) bool {
+	for _, t := range g.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// splitFrontMatter returns the YAML front matter of doc and the remaining document,
+// or ok false if doc does not start with a --- line. A --- line that is never closed
+// is a markdown thematic break, not front matter.
+func splitFrontMatter(doc []byte) (front []byte, rest []byte, ok bool) {
+	doc = bytes.TrimPrefix(doc, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+
+	if !bytes.HasPrefix(doc, frontMatterDelimiter) {
+		return nil, doc, false
+	}
+	lines := bytes.SplitAfter(doc, []byte("\n"))
+	if len(bytes.TrimSpace(lines[0])) != len(frontMatterDelimiter) {
+		return nil, doc, false // A --- rule followed by text, not front matter
+	}
+	for i := 1; i < len(lines); i++ {
+		if trimmed := bytes.TrimSpace(lines[i]); bytes.Equal(trimmed, frontMatterDelimiter) || bytes.Equal(trimmed, []byte("...")) {
+			return bytes.Join(lines[1:i], nil), bytes.Join(lines[i+1:], nil), true
+		}
+	}
+	return nil, doc, false
+}
+
+// ---------------------------------------------------------------------------
+// parseFrontMatter decodes YAML front matter into a map with lowercased keys.
+func parseFrontMatter(front []byte) (map[string]interface{}, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(front, &raw); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		fields[strings.ToLower(fmt.Sprint(k))] = v
+	}
+	return fields, nil
+}
+
+// ---------------------------------------------------------------------------
+// metadataStrings flattens metadata to the string form returned by MetaData, with
+// lists comma separated.
+func metadataStrings(fields map[string]interface{}) map[string]string {
+	meta := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if list, ok := v.([]interface{}); ok {
+			meta[k] = strings.Join(stringList(list), ", ")
+		} else {
+			meta[k] = metadataString(v)
+		}
+	}
+	return meta
+}
+
+// ---------------------------------------------------------------------------
+// newGuideMetadata extracts the typed fields from metadata.
+func newGuideMetadata(fields map[string]interface{}) (*GuideMetadata, error) {
+	g := &GuideMetadata{
+		Title:             metadataString(fields["title"]),
+		Tags:              stringList(fields["tags"]),
+		Authors:           stringList(fields["authors"]),
+		RelatedOperations: stringList(fields["related-operations"]),
+		RedirectFrom:      stringList(fields["redirect-from"]),
+		Status:            strings.ToLower(metadataString(fields["status"])),
+		Fields:            fields,
+	}
+
+	switch draft := fields["draft"].(type) {
+	case nil:
+	case bool:
+		g.Draft = draft
+	case string:
+		g.Draft = strings.EqualFold(draft, "true") || strings.EqualFold(draft, "yes")
+	default:
+		return nil, fmt.Errorf("draft must be true or false, not '%v'", draft)
+	}
+
+	switch reviewed := fields["last-reviewed"].(type) {
+	case nil:
+	case time.Time:
+		g.LastReviewed = reviewed
+	case string:
+		t, err := time.Parse("2006-01-02", reviewed)
+		if err != nil {
+			return nil, fmt.Errorf("last-reviewed must be a YYYY-MM-DD date, not '%s'", reviewed)
+		}
+		g.LastReviewed = t
+	default:
+		return nil, fmt.Errorf("last-reviewed must be a YYYY-MM-DD date, not '%v'", reviewed)
+	}
+
+	for _, from := range g.RedirectFrom {
+		if !strings.HasPrefix(from, "/") {
+			return nil, fmt.Errorf("redirect-from URL '%s' must start with /", from)
+		}
+	}
+	return g, nil
+}
+
+// ---------------------------------------------------------------------------
+
+func metadataString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case time.Time:
+		return s.Format("2006-01-02")
+	}
+	return fmt.Sprint(v)
+}
+
+// stringList accepts a YAML list, or a comma separated string as given by legacy metadata.
+func stringList(v interface{}) []string {
+	var list []string
+	switch l := v.(type) {
+	case []interface{}:
+		for _, item := range l {
+			list = append(list, metadataString(item))
+		}
+	case nil:
+	default:
+		list = strings.Split(metadataString(l), ",")
+	}
+
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,357 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package asset
+
+// Guides and overlays refer to other pages with shortcodes, resolved when they are
+// compiled so that links follow the pages when their IDs change:
+//
+//	{{< ref "op:petstore/addPet" >}}           The URL of the page, for a markdown link target
+//	{{< link "op:petstore/addPet" >}}          A markdown link, titled with the page name
+//	{{< link "op:petstore/addPet" "Add one" >}} A markdown link with the given title
+//
+// In .tmpl guides, which are not converted from markdown, link gives an HTML link.
+//
+// Targets are one of:
+//
+//	spec:<spec-id>
+//	api:<spec-id>/<api-id>
+//	op:<spec-id>/<operationId>, or op:<spec-id>/<METHOD> <path>
+//	resource:<spec-id>/<resource-id or title>
+//	guide:[<spec-id>/]<path>
+//
+// and may end with a #fragment. A guide target whose first segment is a specification
+// ID is a guide of that specification. Unresolved targets are left as written, and
+// reported by CheckLinks. Shortcodes may also embed reference widgets, see widgets.go.
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/i18n"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/spec"
+)
+
+// Backlink is a guide that links to a page.
+type Backlink struct {
+	URL   string
+	Title string
+	Draft  bool   // Only to be listed in preview mode
+	Locale string // The locale the guide is written in
+}
+
+// Reference is the page a shortcode target refers to, and the parts of the
+// specification model behind it.
+type Reference struct {
+	URL           string // Without fragment
+	Title         string
+	Specification *spec.APISpecification
+	API           *spec.APIGroup
+	Method        *spec.Method
+	Resource      *spec.Resource
+	guide         string // Asset name of a guide target, checked once all assets are compiled
+}
+
+type link struct {
+	source string // Asset name of the linking document
+	target string
+	ref    *Reference
+	err    string // Why a resolved target cannot be used, as for a widget of the wrong kind
+}
+
+var shortcodeRegex = regexp.MustCompile(`\{\{<\s*([a-z]+)\s+"([^"]*)"(?:\s+"([^"]*)")?\s*>\}\}`)
+
+var _links []*link
+var _backlinks = map[string][]Backlink{} // Page URL->Guides linking to it
+
+// ---------------------------------------------------------------------------
+// Backlinks returns the guides in a locale that link to the page at url, ordered by
+// title, falling back to those in the default locale if there are none. Draft guides
+// are only included if drafts is true.
+func Backlinks(url string, drafts bool, locale string) []Backlink {
+	for _, l := range []string{locale, i18n.Default()} {
+		var backlinks []Backlink
+		for _, b := range _backlinks[strings.TrimSuffix(url, "/")] {
+			if b.Locale == l && (drafts || !b.Draft) {
+				backlinks = append(backlinks, b)
+			}
+		}
+		if len(backlinks) > 0 {
+			return backlinks
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// resolveShortcodes replaces the cross-link shortcodes of the document compiled to
+// the asset name, recording the links for backlinks and CheckLinks. Widgets are
+// replaced by placeholders, to be expanded by expandWidgets once the document has
+// been converted from markdown. Links are written as markdown if the document is.
+func resolveShortcodes(doc []byte, name string, meta map[string]interface{}, markdown bool) ([]byte, []string) {
+
+	var widgets []string
+
+	sourceURL := guideURL(name)
+	sourceTitle := metadataString(meta["title"])
+	if len(sourceTitle) == 0 {
+		sourceTitle = path.Base(sourceURL)
+	}
+	var sourceDraft bool
+	if guide, err := newGuideMetadata(meta); err == nil {
+		sourceDraft = guide.Draft
+	}
+	sourceLocale, _ := i18n.AssetLocale(name)
+
+	doc = shortcodeRegex.ReplaceAllFunc(doc, func(code []byte) []byte {
+		match := shortcodeRegex.FindSubmatch(code)
+		kind, target, arg := string(match[1]), string(match[2]), string(match[3])
+
+		_, isWidget := widgetKinds[kind]
+		if kind != "ref" && kind != "link" && !isWidget {
+			return code // Not one of ours
+		}
+
+		l := &link{source: name, target: target, ref: LookupReference(target)}
+		_links = append(_links, l)
+
+		if l.ref == nil {
+			return code
+		}
+		if isWidget {
+			if l.err = checkWidget(kind, l.ref, arg); len(l.err) > 0 {
+				return code
+			}
+		}
+
+		url := l.ref.URL
+		if len(sourceURL) > 0 && !hasBacklink(url, sourceURL) {
+			_backlinks[url] = append(_backlinks[url], Backlink{URL: sourceURL, Title: sourceTitle, Draft: sourceDraft, Locale: sourceLocale})
+			sort.SliceStable(_backlinks[url], func(i, j int) bool {
+				return strings.ToLower(_backlinks[url][i].Title) < strings.ToLower(_backlinks[url][j].Title)
+			})
+		}
+
+		if isWidget {
+			widgets = append(widgets, widgetTemplate(kind, target, arg))
+			return []byte(widgetPlaceholder(len(widgets) - 1))
+		}
+
+		if i := strings.Index(target, "#"); i >= 0 {
+			url += target[i:]
+		}
+		if kind == "ref" {
+			return []byte(url)
+		}
+		title := arg
+		if len(title) == 0 {
+			title = l.ref.Title
+		}
+		if !markdown {
+			return []byte(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(title)))
+		}
+		return []byte(fmt.Sprintf("[%s](%s)", title, url))
+	})
+
+	return doc, widgets
+}
+
+// ---------------------------------------------------------------------------
+// LookupReference returns the page a shortcode target refers to, or nil if there
+// is none. Guide targets are resolved by naming convention, and checked by CheckLinks.
+func LookupReference(target string) *Reference {
+
+	target = strings.SplitN(target, "#", 2)[0]
+
+	parts := strings.SplitN(target, ":", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+	kind, ref := parts[0], parts[1]
+
+	if kind == "guide" {
+		specID, page := "", strings.Trim(ref, "/")
+		if i := strings.Index(page, "/"); i > 0 {
+			if _, ok := spec.APISuite[page[:i]]; ok {
+				specID, page = page[:i], page[i+1:]
+			}
+		}
+		if len(specID) > 0 {
+			return &Reference{URL: "/" + specID + "/guides/" + page, Title: path.Base(page), Specification: spec.APISuite[specID], guide: "assets/templates/" + specID + "/templates/guides/" + page + ".tmpl"}
+		}
+		return &Reference{URL: "/guides/" + page, Title: path.Base(page), guide: "assets/templates/guides/" + page + ".tmpl"}
+	}
+
+	parts = strings.SplitN(ref, "/", 2)
+	specification, found := spec.APISuite[parts[0]]
+	if !found {
+		return nil
+	}
+	base := "/" + specification.ID
+
+	if kind == "spec" {
+		if len(parts) > 1 {
+			return nil
+		}
+		return &Reference{URL: base + "/reference", Title: specification.APIInfo.Title, Specification: specification}
+	}
+	if len(parts) < 2 {
+		return nil
+	}
+	ref = parts[1]
+
+	switch kind {
+	case "api":
+		for i := range specification.APIs {
+			api := &specification.APIs[i]
+			if api.ID == ref {
+				return &Reference{URL: base + "/reference/" + api.ID, Title: api.Name, Specification: specification, API: api}
+			}
+		}
+	case "op":
+		for i := range specification.APIs {
+			api := &specification.APIs[i]
+			for j := range api.Methods {
+				method := &api.Methods[j]
+				if matchOperation(method, ref) {
+					title := method.Name
+					if len(title) == 0 {
+						title = method.NavigationName
+					}
+					return &Reference{URL: base + "/reference/" + api.ID + "/" + method.ID, Title: title, Specification: specification, API: api, Method: method}
+				}
+			}
+		}
+	case "resource":
+		// Prefer the latest version of the resource
+		versions := []string{"latest"}
+		for version := range specification.ResourceList {
+			if version != "latest" {
+				versions = append(versions, version)
+			}
+		}
+		sort.Strings(versions[1:])
+
+		for _, version := range versions {
+			for id, resource := range specification.ResourceList[version] {
+				if id == ref || resource.Title == ref {
+					return &Reference{URL: base + "/resources/" + id, Title: resource.Title, Specification: specification, Resource: resource}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+
+func matchOperation(method *spec.Method, ref string) bool {
+	if len(method.OperationID) > 0 && method.OperationID == ref {
+		return true
+	}
+	parts := strings.Fields(ref)
+	return len(parts) == 2 && strings.EqualFold(parts[0], method.Method) && parts[1] == method.Path
+}
+
+// ---------------------------------------------------------------------------
+
+func hasBacklink(url, source string) bool {
+	for _, b := range _backlinks[url] {
+		if b.URL == source {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// GuideURLs returns the URLs of all compiled guides that are not drafts, sorted.
+func GuideURLs() []string {
+	var urls []string
+	for name := range _bindata {
+		if path.Ext(name) != ".tmpl" {
+			continue
+		}
+		if guide := _guideMetadata[name]; guide != nil && guide.Draft {
+			continue
+		}
+		if url := guideURL(name); len(url) > 0 {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// ---------------------------------------------------------------------------
+// DraftGuides returns the metadata of all compiled guides marked as drafts, ordered
+// by URL.
+func DraftGuides() []*GuideMetadata {
+	var drafts []*GuideMetadata
+	for _, guide := range _guideMetadata {
+		if guide.Draft && len(guide.URL) > 0 {
+			drafts = append(drafts, guide)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].URL < drafts[j].URL
+	})
+	return drafts
+}
+
+// ---------------------------------------------------------------------------
+// guideURL returns the URL of the guide compiled to the asset name, or "" if the
+// asset is not a guide. Guides in other than the default locale are prefixed by it.
+func guideURL(name string) string {
+	locale, name := i18n.AssetLocale(strings.TrimSuffix(name, path.Ext(name)))
+
+	prefix := ""
+	if locale != i18n.Default() {
+		prefix = "/" + locale
+	}
+	if strings.HasPrefix(name, "assets/templates/guides/") {
+		return prefix + strings.TrimPrefix(name, "assets/templates")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(name, "assets/templates/"), "/templates/guides/", 2)
+	if len(parts) == 2 && !strings.Contains(parts[0], "/") {
+		return prefix + "/" + parts[0] + "/guides/" + parts[1]
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// CheckLinks warns of every shortcode that could not be resolved, including those
+// to guides that do not exist. It must be called once all assets are compiled.
+func CheckLinks() {
+	for _, l := range _links {
+		switch {
+		case l.ref == nil:
+			logger.Warnf(nil, "Unresolved link '%s' in %s", l.target, l.source)
+		case len(l.err) > 0:
+			logger.Warnf(nil, "Unresolved link '%s' in %s: %s", l.target, l.source, l.err)
+		case len(l.ref.guide) > 0:
+			if _, ok := _bindata[l.ref.guide]; !ok {
+				logger.Warnf(nil, "Unresolved link '%s' in %s: there is no guide %s", l.target, l.source, l.ref.URL)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package asset
+
+// Guides embed live parts of the reference with widget shortcodes, rendered by the
+// same fragments/reference templates as the reference pages:
+//
+//	{{< params "op:petstore/addPet" >}}          The operation's parameter tables
+//	{{< params "op:petstore/addPet" "query" >}}  Only its path, query, header or form parameters
+//	{{< headers "op:petstore/addPet" "201" >}}   The headers of a response, or "default"
+//	{{< explorer "op:petstore/addPet" >}}        The API explorer for the operation
+//	{{< properties "resource:petstore/pet" >}}   A resource's property table
+//	{{< example "resource:petstore/pet" >}}      A resource's example JSON
+//
+// A widget shortcode becomes a template call on the "widget" function, so the
+// content is taken from the specification when the guide is rendered.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var widgetKinds = map[string]string{ // Widget->Reference part it needs
+	"params":     "operation",
+	"headers":    "operation",
+	"explorer":   "operation",
+	"properties": "resource",
+	"example":    "resource",
+}
+
+var paramLocations = []struct{ in, field, heading string }{
+	{"path", "PathParams", "Path parameters"},
+	{"query", "QueryParams", "Query parameters"},
+	{"header", "HeaderParams", "Request headers"},
+	{"form", "FormParams", "Form parameters"},
+}
+
+var widgetPlaceholderRegex = regexp.MustCompile(`(?:<p>)?DAPPERDOXWIDGET(\d+)END(?:</p>)?`)
+
+// ---------------------------------------------------------------------------
+// checkWidget returns why the widget cannot be rendered for ref, or "" if it can.
+func checkWidget(kind string, ref *Reference, arg string) string {
+	switch widgetKinds[kind] {
+	case "operation":
+		if ref.Method == nil {
+			return kind + " needs an operation target"
+		}
+	case "resource":
+		if ref.Resource == nil {
+			return kind + " needs a resource target"
+		}
+	}
+
+	switch kind {
+	case "params":
+		if len(arg) == 0 {
+			return ""
+		}
+		for _, l := range paramLocations {
+			if l.in == arg {
+				return ""
+			}
+		}
+		return fmt.Sprintf("unknown parameter location '%s'", arg)
+	case "headers":
+		if arg == "default" {
+			if ref.Method.DefaultResponse == nil {
+				return "the operation has no default response"
+			}
+			return ""
+		}
+		status, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Sprintf("headers needs a response status, not '%s'", arg)
+		}
+		if _, ok := ref.Method.Responses[status]; !ok {
+			return fmt.Sprintf("the operation has no %d response", status)
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// widgetTemplate returns the template text that renders a widget.
+func widgetTemplate(kind, target, arg string) string {
+	var t string
+
+	switch kind {
+	case "params":
+		for _, l := range paramLocations {
+			if len(arg) > 0 && arg != l.in {
+				continue
+			}
+			heading := ""
+			if len(arg) == 0 {
+				heading = `<h3 class="sub-sub-header">` + l.heading + `</h3>`
+			}
+			t += fmt.Sprintf(`[: if .Method.%s :]%s[: template "fragments/reference/params" .Method.%s :][: end :]`, l.field, heading, l.field)
+		}
+	case "headers":
+		t = `[: with .Response :][: template "fragments/reference/response_headers" . :][: end :]`
+	case "explorer":
+		t = `[: template "fragments/explorer" . :]`
+	case "properties":
+		t = `[: template "fragments/reference/resource_table" . :]`
+	case "example":
+		t = `[: if .Resource.Example :]<pre><code>[: .Resource.Example :]</code></pre>[: end :]`
+	}
+	return fmt.Sprintf(`<div class="referenceWidget">[: with widget $ %q %q :]%s[: end :]</div>`, target, arg, t)
+}
+
+// ---------------------------------------------------------------------------
+
+func widgetPlaceholder(n int) string {
+	return fmt.Sprintf("DAPPERDOXWIDGET%dEND", n)
+}
+
+// ---------------------------------------------------------------------------
+// expandWidgets replaces the widget placeholders left by resolveShortcodes, along
+// with any paragraph markdown has wrapped them in.
+func expandWidgets(doc []byte, widgets []string) []byte {
+	if len(widgets) == 0 {
+		return doc
+	}
+	return widgetPlaceholderRegex.ReplaceAllFunc(doc, func(placeholder []byte) []byte {
+		n, _ := strconv.Atoi(string(widgetPlaceholderRegex.FindSubmatch(placeholder)[1]))
+		if n >= len(widgets) {
+			return placeholder
+		}
+		return []byte(strings.TrimSpace(widgets[n]))
+	})
+}
@@ -0,0 +1,195 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package cache
+
+// The page cache keeps the rendered output of pages, by route, version and locale,
+// so that each is only rendered once. Pages are served with an ETag and
+// Last-Modified, and conditional requests for unchanged pages get a 304. Pages in
+// preview mode depend on more than their URL, so are never cached.
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/i18n"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+)
+
+type page struct {
+	status   int
+	header   http.Header
+	body     []byte
+	etag     string
+	modified time.Time
+}
+
+var (
+	pages = make(map[string]*page)
+	mutex sync.RWMutex
+)
+
+// ---------------------------------------------------------------------------
+// Handler serves pages rendered by h from the cache, rendering and caching those
+// not yet in it. Only successfully rendered pages are cached, and not those h
+// marks with NoStore.
+func Handler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		cfg, _ := config.Get()
+		if !cfg.PageCache || publish.Preview(req) {
+			h(w, req)
+			return
+		}
+
+		key := Key(req)
+		mutex.RLock()
+		p, ok := pages[key]
+		mutex.RUnlock()
+
+		if !ok {
+			rec := httptest.NewRecorder()
+			h(rec, req)
+
+			p = &page{
+				status:   rec.Code,
+				header:   rec.Header(),
+				body:     rec.Body.Bytes(),
+				modified: time.Now().UTC().Truncate(time.Second),
+			}
+			sum := sha1.Sum(p.body)
+			p.etag = `"` + hex.EncodeToString(sum[:])[:20] + `"`
+
+			if p.status == http.StatusOK && !strings.Contains(p.header.Get("Cache-Control"), "no-store") {
+				logger.Tracef(req, "Caching page %s", key)
+				mutex.Lock()
+				pages[key] = p
+				mutex.Unlock()
+			}
+		}
+		serve(w, req, p)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// NoStore marks the page being written as one not to cache, such as one for a
+// version that does not exist, whose key would otherwise add an entry per request.
+func NoStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// ---------------------------------------------------------------------------
+// Key returns the cache key of the page requested: its path, version and locale.
+func Key(req *http.Request) string {
+	return req.URL.Path + "?v=" + req.URL.Query().Get("v") + "#" + i18n.Locale(req)
+}
+
+// ---------------------------------------------------------------------------
+// serve writes a page, or 304 if the request's conditions show the client has it.
+func serve(w http.ResponseWriter, req *http.Request, p *page) {
+	for name, values := range p.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	if p.status != http.StatusOK {
+		w.WriteHeader(p.status)
+		w.Write(p.body)
+		return
+	}
+
+	w.Header().Set("ETag", p.etag)
+	w.Header().Set("Last-Modified", p.modified.Format(http.TimeFormat))
+
+	if notModified(req, p) {
+		for _, name := range []string{"Content-Type", "Content-Length"} {
+			w.Header().Del(name)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(p.status)
+	w.Write(p.body)
+}
+
+// ---------------------------------------------------------------------------
+// notModified returns true if the request's If-None-Match, or failing that its
+// If-Modified-Since, matches the page.
+func notModified(req *http.Request, p *page) bool {
+	if match := req.Header.Get("If-None-Match"); len(match) > 0 {
+		for _, etag := range strings.Split(match, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == p.etag || etag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil {
+		return !p.modified.After(since)
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Purge empties the cache, so that every page is rendered afresh.
+func Purge() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(pages) > 0 {
+		logger.Debugf(nil, "Purging %d cached pages", len(pages))
+	}
+	pages = make(map[string]*page)
+}
+
+// ---------------------------------------------------------------------------
+// Prerender renders the pages at uris through handler, in each locale, so that
+// they are cached before they are first requested.
+func Prerender(handler http.Handler, uris []string) {
+	cfg, _ := config.Get()
+	if !cfg.PageCache {
+		return
+	}
+	handler = i18n.Handler(handler)
+
+	start := time.Now()
+	count := 0
+	for _, locale := range i18n.Locales() {
+		prefix := ""
+		if locale != i18n.Default() {
+			prefix = "/" + locale
+		}
+		for _, uri := range uris {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", prefix+uri, nil))
+			if w.Code != http.StatusOK {
+				logger.Warnf(nil, "Pre-rendering %s returned %d", prefix+uri, w.Code)
+				continue
+			}
+			count++
+		}
+	}
+	logger.Infof(nil, "Pre-rendered %d pages in %s", count, time.Since(start))
+}
+
+// ---------------------------------------------------------------------------
+// end
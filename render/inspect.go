@@ -0,0 +1,237 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+// The author inspector shows, at the foot of each page, how the page was rendered:
+// the templates it is built from and the assets they were compiled from, the
+// overlays looked for, the data available to templates and any template errors.
+// It is only shown to authors in preview mode, and only if author-inspect is set.
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"text/template/parse"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/render/asset"
+	"github.com/unrolled/render"
+)
+
+// Inspection records how a page was rendered.
+type Inspection struct {
+	Page      string
+	Templates []InspectedTemplate
+	Overlays  []OverlayLookup
+	Data      []DataKey
+	Errors    []string
+}
+
+// InspectedTemplate is a template used by the page, at the depth it is included.
+type InspectedTemplate struct {
+	Name      string
+	Depth     int
+	Source    string // Directory or archive the template was compiled from
+	Recursive bool   // Included by itself, so not followed further
+	Missing   bool
+}
+
+// OverlayLookup is the search for an overlay, in priority order. The winner is the
+// first candidate found.
+type OverlayLookup struct {
+	Name       string
+	Candidates []OverlayCandidate
+	Winner     string
+}
+
+// OverlayCandidate is a template that would overlay the page, had it existed.
+type OverlayCandidate struct {
+	Template string
+	Source   string
+	Hit      bool
+}
+
+// DataKey is a member of the template data map.
+type DataKey struct {
+	Name string
+	Type string
+}
+
+const templateDir = "assets/templates/"
+
+// ----------------------------------------------------------------------------------------
+// newInspection returns an inspection for the request's page, if the author
+// inspector is to be shown, or nil.
+func newInspection(req *http.Request) *Inspection {
+	cfg, _ := config.Get()
+	if !cfg.AuthorInspect || !publish.Preview(req) {
+		return nil
+	}
+	return &Inspection{}
+}
+
+// ----------------------------------------------------------------------------------------
+// inspectionOf returns the inspection of a template data map, or nil.
+func inspectionOf(data interface{}) *Inspection {
+	if datamap, ok := data.(map[string]interface{}); ok {
+		inspection, _ := datamap["Inspector"].(*Inspection)
+		return inspection
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------------------
+// inspectHTML renders a page as HTML does, completing its inspection beforehand. If
+// the page fails to render, the inspection is rendered in its place.
+func inspectHTML(w http.ResponseWriter, status int, name string, binding interface{}, inspection *Inspection, htmlOpt ...render.HTMLOptions) {
+	inspection.Page = name
+	inspection.Templates = nil
+	layout := "layout"
+	if len(htmlOpt) > 0 {
+		layout = htmlOpt[0].Layout
+	}
+	if len(layout) > 0 {
+		inspection.inspectTemplate(layout, 0, nil)
+	}
+	inspection.inspectTemplate(name, 0, nil)
+
+	inspection.Data = nil
+	for key, value := range binding.(map[string]interface{}) {
+		if key != "Inspector" {
+			inspection.Data = append(inspection.Data, DataKey{Name: key, Type: fmt.Sprintf("%T", value)})
+		}
+	}
+	sort.Slice(inspection.Data, func(i, j int) bool { return inspection.Data[i].Name < inspection.Data[j].Name })
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	b := newResponseBuffer()
+	Render.HTML(b, status, name, binding, htmlOpt...)
+
+	if b.status == http.StatusInternalServerError && status != http.StatusInternalServerError {
+		logger.Tracef(nil, "Inspector: page %s failed to render: %s", name, b.buf.String())
+		inspection.Errors = append(inspection.Errors, fmt.Sprintf("%s: %s", name, bytes.TrimSpace(b.buf.Bytes())))
+		Render.HTML(w, http.StatusInternalServerError, "author_inspector", inspection, render.HTMLOptions{Layout: ""})
+		return
+	}
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.buf.Bytes())
+}
+
+// ----------------------------------------------------------------------------------------
+// inspectTemplate records a template and those it includes, depth first. stack
+// holds the templates including it.
+func (i *Inspection) inspectTemplate(name string, depth int, stack []string) {
+	t := &InspectedTemplate{Name: name, Depth: depth, Source: asset.Source(templateDir + name + ".tmpl")}
+	for _, s := range stack {
+		if s == name {
+			t.Recursive = true
+		}
+	}
+	tmpl := TemplateLookup(name)
+	t.Missing = tmpl == nil || tmpl.Tree == nil
+	i.Templates = append(i.Templates, *t)
+
+	if t.Recursive || t.Missing {
+		return
+	}
+	stack = append(stack, name)
+	for _, include := range includedTemplates(tmpl.Tree.Root) {
+		i.inspectTemplate(include, depth+1, stack)
+	}
+}
+
+// ----------------------------------------------------------------------------------------
+// includedTemplates returns the names of the templates a template parse tree
+// includes, in order.
+func includedTemplates(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, child := range n.Nodes {
+				names = append(names, includedTemplates(child)...)
+			}
+		}
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	case *parse.IfNode:
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	case *parse.RangeNode:
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	case *parse.WithNode:
+		names = append(names, includedTemplates(n.List)...)
+		names = append(names, includedTemplates(n.ElseList)...)
+	}
+	return names
+}
+
+// ----------------------------------------------------------------------------------------
+// lookupOverlay records the search for an overlay and its result.
+func (i *Inspection) lookupOverlay(name string, candidates []string, winner string) {
+	lookup := OverlayLookup{Name: name, Winner: winner}
+	for _, candidate := range candidates {
+		lookup.Candidates = append(lookup.Candidates, OverlayCandidate{
+			Template: candidate,
+			Source:   asset.Source(templateDir + candidate + ".tmpl"),
+			Hit:      TemplateLookup(candidate) != nil,
+		})
+	}
+	i.Overlays = append(i.Overlays, lookup)
+}
+
+// ----------------------------------------------------------------------------------------
+// renderOverlay renders an overlay, recording rather than showing a template error.
+func (i *Inspection) renderOverlay(overlay string, data interface{}) template.HTML {
+	b := newResponseBuffer()
+	Render.HTML(b, http.StatusOK, overlay, data, render.HTMLOptions{Layout: ""})
+	if b.status != http.StatusOK {
+		i.Errors = append(i.Errors, fmt.Sprintf("%s: %s", overlay, bytes.TrimSpace(b.buf.Bytes())))
+		return ""
+	}
+	return template.HTML(b.buf.String())
+}
+
+// ----------------------------------------------------------------------------------------
+// responseBuffer is a ResponseWriter that keeps the response, so that it can be
+// checked before it is sent.
+type responseBuffer struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}, status: http.StatusOK}
+}
+
+func (b *responseBuffer) Header() http.Header            { return b.header }
+func (b *responseBuffer) WriteHeader(status int)         { b.status = status }
+func (b *responseBuffer) Write(data []byte) (int, error) { return b.buf.Write(data) }
+
+// ----------------------------------------------------------------------------------------
+// end
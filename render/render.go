@@ -26,10 +26,16 @@ import (
 
 	//"github.com/davecgh/go-spew/spew"
 	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/i18n"
 	"github.com/wix/dapperdox/logger"
 	"github.com/wix/dapperdox/navigation"
+	"github.com/wix/dapperdox/publish"
+	"github.com/wix/dapperdox/record"
 	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/render/cache"
+	"github.com/wix/dapperdox/sdk"
 	"github.com/wix/dapperdox/spec"
+	"github.com/wix/dapperdox/theme"
 	"github.com/ian-kent/htmlform"
 	"github.com/unrolled/render"
 )
@@ -41,17 +47,21 @@ var Render *render.Render
 type GuideType []*navigation.NavigationNode
 type overlayPathList []string
 
-var guides = map[string]GuideType{} // Guides are per specification-id, or 'top-level'
+var guides = map[string]map[string]GuideType{} // Guides are per specification-id, or 'top-level', then per locale
 
 // Vars is a map of variables
 type Vars map[string]interface{}
 
+// The variable holding the request a page is rendered for, for widgets of guides
+const requestVar = "request"
+
 var counter int
 
 // ----------------------------------------------------------------------------------------
 
 func Register() {
 	Render = New()
+	cache.Purge() // Pages rendered by the previous templates are stale
 }
 
 // ----------------------------------------------------------------------------------------
@@ -67,28 +77,27 @@ func New() *render.Render {
 	if len(cfg.AssetsDir) != 0 {
 		asset.Compile(cfg.AssetsDir+"/templates", "assets/templates")
 		asset.Compile(cfg.AssetsDir+"/static", "assets/static")
-		asset.Compile(cfg.AssetsDir+"/themes/"+cfg.Theme, "assets")
+		asset.Compile(cfg.AssetsDir+"/sdk", "assets/sdk")
 		compileSections(cfg.AssetsDir)
+		compileLocales(cfg.AssetsDir)
 	}
 
-	// Import custom theme from custom directory (if defined)
-	if len(cfg.Theme) != 0 {
-		dir := cfg.DefaultAssetsDir + "/themes"
-		if len(cfg.ThemeDir) != 0 {
-			dir = cfg.ThemeDir
+	// Import the theme, then each theme it is layered over
+	for _, t := range theme.Chain() {
+		for _, source := range t.Sources {
+			asset.CompileSource(source, "assets")
 		}
-		asset.Compile(dir+"/"+cfg.Theme, "assets")
-	}
-
-	if cfg.Theme != "default" {
-		// The default theme underpins all others
-		asset.Compile(cfg.DefaultAssetsDir+"/themes/default", "assets")
 	}
 
 	// Fallback to local templates directory
 	asset.Compile(cfg.DefaultAssetsDir+"/templates", "assets/templates")
 	// Fallback to local static directory
 	asset.Compile(cfg.DefaultAssetsDir+"/static", "assets/static")
+	// Fallback to local SDK templates directory
+	asset.Compile(cfg.DefaultAssetsDir+"/sdk", "assets/sdk")
+
+	// All guides are now known, so links to them can be checked
+	asset.CheckLinks()
 
 	return render.New(render.Options{
 		Asset:      asset.Asset,
@@ -108,10 +117,18 @@ func New() *render.Render {
 			"counter_set":   func(a int) int { counter = a; return counter },
 			"counter_add":   func(a int) int { counter += a; return counter },
 			"mod":           func(a int, m int) int { return a % m },
+			"add":           func(a int, b int) int { return a + b },
 			"safehtml":      func(s string) template.HTML { return template.HTML(s) },
 			"haveTemplate":  func(n string) *template.Template { return TemplateLookup(n) },
 			"overlay":       func(n string, d ...interface{}) template.HTML { return overlay(n, d) },
 			"getAssetPaths": func(s string, d ...interface{}) []string { return getAssetPaths(s, d) },
+			"widget":        widget,
+			"localised":     localisedOverlay,
+			"asset":         asset.StaticURL,
+			"integrity":     asset.Integrity,
+			"example":       func(id string, m spec.Method, status int) *record.Example { return record.Promoted(id, &m, status) },
+			"sdkDownloads":  sdk.Downloads,
+			"sdkFunctions":  func(id string, m spec.Method) []sdk.Link { return sdk.Functions(id, &m) },
 		}},
 	})
 }
@@ -133,6 +150,22 @@ func compileSectionPart(assetsDir string, spec *spec.APISpecification, part stri
 	asset.Compile(assetsDir+"/sections/"+stem, prefix+stem)
 }
 
+// ----------------------------------------------------------------------------------------
+// compileLocales compiles the templates and sections trees of each locale other than
+// the default, to be found by their localised names (see i18n.AssetName).
+func compileLocales(assetsDir string) {
+	for _, locale := range i18n.Locales()[1:] {
+		logger.Debugf(nil, "- Locale assets for '%s'", locale)
+		localeDir := assetsDir + "/" + i18n.AssetDir + "/" + locale
+
+		asset.Compile(localeDir+"/templates", "assets/templates/"+i18n.AssetDir+"/"+locale)
+		for _, specification := range spec.APISuite {
+			stem := specification.ID + "/templates"
+			asset.Compile(localeDir+"/sections/"+stem, "assets/templates/"+i18n.AssetName(stem, locale))
+		}
+	}
+}
+
 // ----------------------------------------------------------------------------------------
 type HTMLWriter struct {
 	h *bufio.Writer
@@ -160,33 +193,58 @@ func overlay(name string, data []interface{}) template.HTML { // TODO Will be sp
 		return ""
 	}
 
-	overlayName := overlayPaths(name, datamap)
-
 	var b bytes.Buffer
-	var overlay string
+	overlay := findOverlay(name, datamap)
 
-	// Look for an overlay file in declaration order.... Highest priority is first.
-	for _, overlay = range overlayName {
-		logger.Tracef(nil, "Overlay: Does '%s' exist?\n", overlay)
-		if TemplateLookup(overlay) != nil {
-			break
-		}
-		overlay = ""
+	inspection := inspectionOf(datamap)
+	if inspection != nil {
+		inspection.lookupOverlay(name, overlayPaths(name, datamap), overlay)
 	}
 
 	if overlay != "" {
 		logger.Tracef(nil, "Applying overlay '%s'\n", overlay)
 		writer := HTMLWriter{h: bufio.NewWriter(&b)}
 
-		r := New()
 		// data is a single item array (though I've not figured out why yet!)
-		r.HTML(writer, http.StatusOK, overlay, data[0], render.HTMLOptions{Layout: ""})
+		if inspection != nil {
+			return inspection.renderOverlay(overlay, data[0])
+		}
+		Render.HTML(writer, http.StatusOK, overlay, data[0], render.HTMLOptions{Layout: ""})
 		writer.Flush()
 	}
 
 	return template.HTML(b.String())
 }
 
+// ----------------------------------------------------------------------------------------
+// findOverlay returns the template of the highest priority overlay for the page
+// described by datamap, or "" if there is none.
+func findOverlay(name string, datamap map[string]interface{}) string {
+	// Look for an overlay file in declaration order.... Highest priority is first.
+	for _, overlay := range overlayPaths(name, datamap) {
+		logger.Tracef(nil, "Overlay: Does '%s' exist?\n", overlay)
+		if TemplateLookup(overlay) != nil {
+			return overlay
+		}
+	}
+	return ""
+}
+
+// ----------------------------------------------------------------------------------------
+// localisedOverlay returns true if the page's overlay is a translation for the page's
+// locale, in which case it replaces the description given by the specification.
+func localisedOverlay(name string, data interface{}) bool {
+	datamap, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	locale, _ := datamap["Locale"].(string)
+	if locale == i18n.Default() {
+		return false
+	}
+	return strings.HasPrefix(findOverlay(name, datamap), i18n.AssetName("", locale))
+}
+
 // ----------------------------------------------------------------------------------------
 
 func overlayPaths(name string, datamap map[string]interface{}) []string {
@@ -217,13 +275,38 @@ func overlayPaths(name string, datamap map[string]interface{}) []string {
 		getSpecificationSummaryPaths(name, &overlayName, datamap)
 	}
 
-	return overlayName
+	locale, _ := datamap["Locale"].(string)
+	return localisePaths(overlayName, locale)
+}
+
+// ----------------------------------------------------------------------------------------
+// localisePaths precedes each overlay path with its version for the locale, so that
+// a translation is preferred at each level of specificity. Author debug file paths
+// are mapped to the locale's directory of assets-dir.
+func localisePaths(paths []string, locale string) []string {
+	if locale == i18n.Default() {
+		return paths
+	}
+	localised := make([]string, 0, 2*len(paths))
+	for _, path := range paths {
+		switch {
+		case strings.HasPrefix(path, "assets/"):
+			localised = append(localised, "assets/"+i18n.AssetName(strings.TrimPrefix(path, "assets/"), locale))
+		default:
+			localised = append(localised, i18n.AssetName(path, locale))
+		}
+		localised = append(localised, path)
+	}
+	return localised
 }
 
 // ----------------------------------------------------------------------------------------
 // HTML is an alias to github.com/unrolled/render.Render.HTML
 func HTML(w http.ResponseWriter, status int, name string, binding interface{}, htmlOpt ...render.HTMLOptions) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if inspection := inspectionOf(binding); inspection != nil {
+		inspectHTML(w, status, name, binding, inspection, htmlOpt...)
+		return
+	}
 	Render.HTML(w, status, name, binding, htmlOpt...)
 }
 
@@ -241,8 +324,19 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	}
 
 	cfg, _ := config.Get()
+	m[requestVar] = req
 	m["Config"] = cfg
 	m["APISuite"] = spec.APISuite
+	m["Categories"] = spec.Categories
+	m["CategoryList"] = spec.CategoryList()
+	preview := publish.Preview(req)
+	m["Preview"] = preview
+	locale := i18n.Locale(req)
+	m["Locale"] = locale
+	m["Alternates"] = i18n.Alternates(req)
+	if inspection := newInspection(req); inspection != nil {
+		m["Inspector"] = inspection
+	}
 
 	// If we have a multiple specifications or are forcing a parent "root" page for the single specification
 	// then set MultipleSpecs to true to enable navigation back to the root page.
@@ -251,14 +345,14 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	}
 
 	if apiSpec == nil {
-		m["NavigationGuides"] = guides[""] // Global guides
+		m["NavigationGuides"] = guides[""][locale] // Global guides
 		m["SpecPath"] = ""
 
 		return m
 	}
 
 	// Per specification defaults
-	m["NavigationGuides"] = guides[apiSpec.ID]
+	m["NavigationGuides"] = guides[apiSpec.ID][locale]
 
 	m["ID"] = apiSpec.ID
 	m["SpecPath"] = "/" + apiSpec.ID
@@ -267,17 +361,27 @@ func DefaultVars(req *http.Request, apiSpec *spec.APISpecification, m Vars) map[
 	m["Resources"] = apiSpec.ResourceList
 	m["Info"] = apiSpec.APIInfo
 	m["SpecURL"] = apiSpec.URL
+	m["Environments"] = apiSpec.Environments
+	m["Category"] = apiSpec.CategoryNode()
+	m["Deprecations"] = apiSpec.Deprecations()
+	if req != nil {
+		m["MentionedIn"] = asset.Backlinks(req.URL.Path, preview, locale)
+	}
+	m["Unpublished"] = m["Unpublished"] == true || !apiSpec.Published()
 
 	return m
 }
 
 // ----------------------------------------------------------------------------------------
-func SetGuidesNavigation(apiSpec *spec.APISpecification, guidesnav *[]*navigation.NavigationNode) {
+func SetGuidesNavigation(apiSpec *spec.APISpecification, locale string, guidesnav *[]*navigation.NavigationNode) {
 	id := ""
 	if apiSpec != nil {
 		id = apiSpec.ID
 	}
-	guides[id] = *guidesnav
+	if guides[id] == nil {
+		guides[id] = make(map[string]GuideType)
+	}
+	guides[id][locale] = *guidesnav
 }
 
 // ----------------------------------------------------------------------------------------
@@ -285,6 +389,14 @@ func SetGuidesNavigation(apiSpec *spec.APISpecification, guidesnav *[]*navigatio
 func getAssetPaths(name string, data []interface{}) []string {
 	datamap := data[0].(map[string]interface{})
 
+	locale, _ := datamap["Locale"].(string)
+	return localisePaths(pageAssetPaths(datamap), locale)
+}
+
+// ----------------------------------------------------------------------------------------
+
+func pageAssetPaths(datamap map[string]interface{}) []string {
+
 	var paths []string
 
 	if _, ok := datamap["API"]; ok {
@@ -0,0 +1,67 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package render
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/asset"
+)
+
+// ----------------------------------------------------------------------------------------
+// widget returns the template data for a reference widget embedded in a guide, the
+// same as the reference page of its target would have for the request the guide's
+// page, page, is rendered for. arg is the response status of a headers widget. The
+// target was checked when the guide was compiled, so is only missing if the
+// specifications have since changed.
+func widget(page interface{}, target string, arg string) map[string]interface{} {
+	ref := asset.LookupReference(target)
+	if ref == nil || ref.Specification == nil {
+		logger.Warnf(nil, "Reference widget target '%s' not found", target)
+		return nil
+	}
+
+	vars := Vars{"Widget": true}
+	if ref.API != nil {
+		vars["API"] = *ref.API
+		vars["LatestVersion"] = ref.API.CurrentVersion
+	}
+	if ref.Method != nil {
+		vars["Title"] = ref.Method.Name
+		vars["Method"] = *ref.Method
+
+		if arg == "default" && ref.Method.DefaultResponse != nil {
+			vars["Response"] = *ref.Method.DefaultResponse
+		} else if status, err := strconv.Atoi(arg); err == nil {
+			if response, ok := ref.Method.Responses[status]; ok {
+				vars["Response"] = response
+			}
+		}
+	}
+	if ref.Resource != nil {
+		vars["Title"] = ref.Resource.Title
+		vars["Resource"] = ref.Resource
+	}
+	var req *http.Request
+	if datamap, ok := page.(map[string]interface{}); ok {
+		req, _ = datamap[requestVar].(*http.Request)
+	}
+	return DefaultVars(req, ref.Specification, vars)
+}
@@ -0,0 +1,333 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package sdk
+
+// An SDK is generated from the language's templates, compiled from the sdk/<language>
+// directory of assets-dir, the theme and the themes it is layered over, and
+// default-assets-dir. Each template produces the file of the same name, less any .tpl
+// suffix, which keeps templates of source files from being taken for source. Names
+// may contain [id.<case>], the specification's ID, and [api.<case>], the API's name,
+// cased by pascal, camel, snake or kebab; a template whose name contains an API
+// produces a file for each API. Templates whose names start with a single _ only
+// define templates for the others to use, and a _reserved.txt file lists the words
+// of the language that names may not be.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/asset"
+)
+
+// TemplatePrefix is the asset name prefix of the templates of each language.
+const TemplatePrefix = "assets/sdk/"
+
+const (
+	reservedFile  = "_reserved.txt"
+	markDelim     = "\x00"
+	markSeparator = "\x1f"
+)
+
+// Names of the specification and the API in template names, and the cases of them
+var (
+	placeholderRegex = regexp.MustCompile(`\[(id|api)\.(pascal|camel|snake|kebab)\]`)
+	cases            = map[string]func(string) string{"pascal": pascal, "camel": camel, "snake": snake, "kebab": kebab}
+)
+
+// data is what each template is executed with: the specification's model, and the
+// API of a file produced for each API.
+type data struct {
+	*Specification
+	API *API
+}
+
+// ---------------------------------------------------------------------------
+// templates returns the asset names of the templates of a language, sorted.
+func templates(language string) []string {
+	prefix := TemplatePrefix + language + "/"
+	var names []string
+	for _, name := range asset.AssetNames() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// generate executes the templates of a language with the model of a specification,
+// returning the files of its SDK. It fails if there are none, as when the templates
+// are all of APIs and the specification has none.
+func generate(language string, s *Specification) (*Generated, error) {
+	prefix := TemplatePrefix + language + "/"
+	reserved := make(map[string]bool)
+
+	root := template.New(language).Delims("[:", ":]").Funcs(funcs(reserved))
+
+	var outputs []string
+	for _, name := range templates(language) {
+		buf, _ := asset.Asset(name)
+		relative := strings.TrimPrefix(name, prefix)
+
+		switch {
+		case path.Base(relative) == reservedFile:
+			for _, word := range strings.Fields(string(buf)) {
+				reserved[word] = true
+			}
+		case helper(relative):
+			if _, err := root.New(relative).Parse(string(buf)); err != nil {
+				return nil, err
+			}
+		default:
+			if _, err := root.New(relative).Parse(string(buf)); err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, relative)
+		}
+	}
+
+	g := &Generated{
+		Specification: s.ID,
+		Language:      language,
+		Version:       s.Version,
+		sources:       make(map[string][]byte),
+		functions:     make(map[string]Link),
+	}
+	for _, name := range outputs {
+		file := strings.TrimSuffix(name, ".tpl")
+
+		if !strings.Contains(file, "[api.") {
+			if err := g.execute(root, name, fileName(file, s, nil), data{Specification: s}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for i := range s.APIs {
+			api := &s.APIs[i]
+			if err := g.execute(root, name, fileName(file, s, api), data{Specification: s, API: api}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(g.Files) == 0 {
+		return nil, fmt.Errorf("the %s templates produce no files", language)
+	}
+	sort.Strings(g.Files)
+	return g, nil
+}
+
+// helper returns true if a template only defines templates for others to use, as
+// those named with a leading _ do, though not a leading __, such as Python's
+// __init__.py.
+func helper(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(base, "_") && !strings.HasPrefix(base, "__")
+}
+
+// fileName returns the name of a file produced by a template, with the names of the
+// specification and API in it cased as it gives.
+func fileName(name string, s *Specification, api *API) string {
+	return placeholderRegex.ReplaceAllStringFunc(name, func(placeholder string) string {
+		m := placeholderRegex.FindStringSubmatch(placeholder)
+		if m[1] == "api" {
+			return cases[m[2]](api.Name)
+		}
+		return cases[m[2]](s.ID)
+	})
+}
+
+// execute executes a template, storing its output as a file of the SDK, and the
+// lines of the functions marked in it.
+func (g *Generated) execute(root *template.Template, name string, file string, d data) error {
+	var buf bytes.Buffer
+	if err := root.ExecuteTemplate(&buf, name, d); err != nil {
+		return err
+	}
+
+	// Take out the marks, noting the lines they are on
+	type mark struct {
+		key  string
+		name string
+		line int // Index in lines
+	}
+	var marks []mark
+	lines := strings.Split(buf.String(), "\n")
+	for i, line := range lines {
+		for strings.Contains(line, markDelim) {
+			start := strings.Index(line, markDelim)
+			end := strings.Index(line[start+1:], markDelim)
+			if end < 0 {
+				break
+			}
+			if m := strings.SplitN(line[start+1:start+1+end], markSeparator, 2); len(m) == 2 {
+				marks = append(marks, mark{key: m[0], name: m[1], line: i})
+			}
+			line = line[:start] + line[start+end+2:]
+		}
+		lines[i] = line
+	}
+	source := []byte(strings.Join(lines, "\n"))
+
+	// Go sources are formatted as gofmt would, which may move the marked lines
+	if path.Ext(file) == ".go" {
+		if formatted, err := format.Source(source); err == nil {
+			source = formatted
+		} else {
+			logger.Warnf(nil, "The %s SDK file %s of %s is not valid Go: %s", g.Language, file, g.Specification, err)
+		}
+	}
+	sourceLines := strings.Split(string(source), "\n")
+	for _, m := range marks {
+		if _, ok := g.functions[m.key]; ok {
+			continue
+		}
+		link := Link{Language: g.Language, Name: m.name, File: file, Line: m.line + 1, URL: g.URL(file)}
+		text := strings.Join(strings.Fields(lines[m.line]), " ")
+		for i := range sourceLines {
+			if strings.Join(strings.Fields(sourceLines[i]), " ") == text {
+				link.Line = i + 1
+				break
+			}
+		}
+		g.functions[m.key] = link
+	}
+
+	g.Files = append(g.Files, file)
+	g.sources[file] = source
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// funcs returns the functions of the templates of a language whose reserved words
+// are those given.
+func funcs(reserved map[string]bool) template.FuncMap {
+	return template.FuncMap{
+		"pascal":   pascal,
+		"camel":    camel,
+		"snake":    snake,
+		"kebab":    kebab,
+		"constant": func(s string) string { return strings.ToUpper(snake(s)) },
+		"lc":       strings.ToLower,
+		"uc":       strings.ToUpper,
+		"join":     strings.Join,
+		"replace":  func(s, old, new string) string { return strings.Replace(s, old, new, -1) },
+		"quote":    quote,
+		"safe": func(name string) string {
+			if reserved[name] {
+				return name + "_"
+			}
+			return name
+		},
+		// mark marks the line the function of an operation, given by its key, is
+		// defined on, for method pages to link to by name
+		"mark": func(key string, name string) string {
+			return markDelim + key + markSeparator + name + markDelim
+		},
+	}
+}
+
+// quote returns a string as a double quoted literal, which Go, TypeScript and Python
+// all read.
+func quote(s string) string {
+	buf, _ := json.Marshal(s)
+	return string(buf)
+}
+
+// ---------------------------------------------------------------------------
+// words splits a name into its words, at punctuation and changes of case, so that
+// list-animals, listAnimals, ListAnimals and list_animals are all list and animals.
+func words(s string) []string {
+	var list []string
+	var word []rune
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				list = append(list, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			previous := word[len(word)-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && next) {
+				list = append(list, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		list = append(list, string(word))
+	}
+	return list
+}
+
+func pascal(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return identifier(b.String())
+}
+
+func camel(s string) string {
+	p := pascal(s)
+	for i, r := range p {
+		if !unicode.IsUpper(r) {
+			if i == 0 {
+				return p
+			}
+			return strings.ToLower(p[:i]) + p[i:]
+		}
+	}
+	return strings.ToLower(p)
+}
+
+func snake(s string) string {
+	return identifier(strings.ToLower(strings.Join(words(s), "_")))
+}
+
+func kebab(s string) string {
+	return strings.ToLower(strings.Join(words(s), "-"))
+}
+
+// identifier returns a name, that of an identifier if it starts with a digit.
+func identifier(s string) string {
+	if len(s) == 0 || unicode.IsDigit(rune(s[0])) {
+		return "_" + s
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,427 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package sdk
+
+// The model is what the language templates are executed with: a specification's APIs
+// and their operations, as functions with typed parameters and results, and the
+// resources of its ResourceList, as models with typed fields. Names are as the
+// specification gives them, for the templates to case as their language does.
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wix/dapperdox/spec"
+)
+
+// Specification is the model of a specification's SDK.
+type Specification struct {
+	ID          string
+	Title       string
+	Description string
+	Version     string // Of the SDK, from info.version
+	BaseURL     string // scheme://host of the specification's operations
+	Security    []Scheme
+	APIs        []API
+	Models      []Model
+}
+
+// Scheme is a security scheme that clients may authenticate with.
+type Scheme struct {
+	Name          string
+	Type          string // apiKey, basic or oauth2
+	ParamName     string // Of an apiKey
+	ParamLocation string // header or query, of an apiKey
+}
+
+// API is an APIGroup, whose operations are the functions of one client.
+type API struct {
+	ID          string
+	Name        string
+	Description string
+	Functions   []Function
+}
+
+// Function is an operation of an API.
+type Function struct {
+	Key         string // Verb and path, which identify the operation within the specification
+	ID          string
+	Name        string // The operationId, or else the ID
+	Summary     string
+	Description string
+	Method      string // Upper case
+	Path        string // With {parameters}, including the base path
+	PathParams  []Param
+	Query       []Param
+	Headers     []Param
+	Form        []Param
+	Body        *Param
+	ContentType string // The first type the operation consumes, if any
+	Result      *Type  // Body of the first success response, if any
+	Deprecated  bool
+}
+
+// Param is a parameter of a function.
+type Param struct {
+	Name             string // As sent
+	Description      string
+	Required         bool
+	CollectionFormat string // Of an array
+	Enum             []string
+	Type             Type
+}
+
+// Model is an object resource.
+type Model struct {
+	Name        string
+	Description string
+	Fields      []Field
+}
+
+// Field is a property of a model.
+type Field struct {
+	Name        string // As sent
+	Description string
+	Required    bool
+	ReadOnly    bool
+	Enum        []string
+	Type        Type
+}
+
+// Type is the type of a parameter, field or result. Kind is one of string, integer,
+// number, boolean, file, array, map, model or any. Format is as the specification
+// gives it, such as int64 or date-time, Item is the type of an array's items or a
+// map's values, and Model the name of a model.
+type Type struct {
+	Kind   string
+	Format string
+	Item   *Type
+	Model  string
+}
+
+// Formats, by the kind of value they format. Other formats are of strings.
+var formatKinds = map[string]string{
+	"integer": "integer",
+	"int32":   "integer",
+	"int64":   "integer",
+	"number":  "number",
+	"float":   "number",
+	"double":  "number",
+	"boolean": "boolean",
+	"file":    "file",
+	"object":  "any",
+	"string":  "string",
+}
+
+var (
+	tagRegex   = regexp.MustCompile(`<[^>]*>`)
+	spaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// builder builds the model of a specification, naming each model resource once.
+type builder struct {
+	models    map[string]*Model // Name->Model
+	resources map[string]string // Resource key->Name of its model
+	order     []string
+}
+
+// ---------------------------------------------------------------------------
+// newSpecification returns the model of the current version of a specification.
+func newSpecification(specification *spec.APISpecification) *Specification {
+	b := &builder{models: make(map[string]*Model), resources: make(map[string]string)}
+
+	s := &Specification{
+		ID:          specification.ID,
+		Title:       specification.APIInfo.Title,
+		Description: text(specification.APIInfo.Description),
+		Version:     version(specification),
+	}
+
+	var names []string
+	for name := range specification.SecurityDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		scheme := specification.SecurityDefinitions[name]
+		s.Security = append(s.Security, Scheme{
+			Name:          name,
+			Type:          scheme.Type,
+			ParamName:     scheme.ParamName,
+			ParamLocation: scheme.ParamLocation,
+		})
+	}
+
+	cased := make(map[string]bool) // The names of the APIs, as their files and clients case them
+	for _, group := range specification.APIs {
+		if len(s.BaseURL) == 0 && group.URL != nil {
+			s.BaseURL = group.URL.Scheme + "://" + group.URL.Host
+		}
+
+		// The listed resources first, as those of responses are preferred to those of
+		// request bodies, which lack read only properties
+		resources := specification.ResourceList[group.CurrentVersion]
+		var ids []string
+		for id := range resources {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			b.resourceType(resources[id], "")
+		}
+
+		// APIs whose names case alike, such as Pets and pets, would share files, so
+		// later ones are numbered as models are
+		name := group.Name
+		for i := 2; cased[snake(name)]; i++ {
+			name = group.Name + " " + strconv.Itoa(i)
+		}
+		cased[snake(name)] = true
+
+		api := API{ID: group.ID, Name: name}
+		if group.Info != nil {
+			api.Description = text(group.Info.Description)
+		}
+		for i := range group.Methods {
+			api.Functions = append(api.Functions, b.function(&group.Methods[i]))
+		}
+		s.APIs = append(s.APIs, api)
+	}
+
+	for _, name := range b.order {
+		s.Models = append(s.Models, *b.models[name])
+	}
+	return s
+}
+
+// OperationKey returns the key of an operation, by which its function is found.
+func OperationKey(method *spec.Method) string {
+	return strings.ToUpper(method.Method) + " " + method.Path
+}
+
+// ---------------------------------------------------------------------------
+
+func (b *builder) function(method *spec.Method) Function {
+	f := Function{
+		Key:         OperationKey(method),
+		ID:          method.ID,
+		Name:        method.OperationID,
+		Summary:     method.Name,
+		Description: text(method.Description),
+		Method:      strings.ToUpper(method.Method),
+		Path:        method.Path,
+		PathParams:  b.params(method.PathParams),
+		Query:       b.params(method.QueryParams),
+		Headers:     b.params(method.HeaderParams),
+		Form:        b.params(method.FormParams),
+		Deprecated:  method.Deprecation != nil,
+	}
+	if len(f.Name) == 0 {
+		f.Name = method.ID
+	}
+	if len(method.Consumes) > 0 {
+		f.ContentType = method.Consumes[0]
+	}
+	if method.BodyParam != nil {
+		body := b.param(*method.BodyParam)
+		if method.BodyParam.Resource != nil {
+			body.Type = b.resourceType(method.BodyParam.Resource, "")
+		}
+		f.Body = &body
+	}
+
+	var statuses []int
+	for status := range method.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		response := method.Responses[status]
+		if status < 200 || status >= 300 {
+			continue
+		}
+		switch {
+		case response.Body != nil && response.Body != response.Resource && len(response.Body.Type) == 1 && strings.ToLower(response.Body.Type[0]) == "array" && response.Resource != nil:
+			// An array of the response's resource
+			item := b.resourceType(response.Resource, "")
+			f.Result = &Type{Kind: "array", Item: &item}
+		case response.Body != nil:
+			t := b.resourceType(response.Body, "")
+			f.Result = &t
+		case response.Resource != nil:
+			t := b.resourceType(response.Resource, "")
+			f.Result = &t
+		}
+		break
+	}
+	return f
+}
+
+// RequiresParams returns true if any of a function's query, header and form
+// parameters are required.
+func (f Function) RequiresParams() bool {
+	for _, params := range [][]Param{f.Query, f.Headers, f.Form} {
+		for _, p := range params {
+			if p.Required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *builder) params(params []spec.Parameter) []Param {
+	var list []Param
+	for _, p := range params {
+		list = append(list, b.param(p))
+	}
+	return list
+}
+
+func (b *builder) param(p spec.Parameter) Param {
+	return Param{
+		Name:             p.Name,
+		Description:      text(p.Description),
+		Required:         p.Required,
+		CollectionFormat: p.CollectionFormat,
+		Enum:             p.Enum,
+		Type:             typeOf(p.Type),
+	}
+}
+
+// ---------------------------------------------------------------------------
+// typeOf returns the type of a parameter, field or header given its spec type, the
+// kind of a value or, of an array or map, the kinds of it and its values.
+func typeOf(types []string) Type {
+	if len(types) == 0 {
+		return Type{Kind: "any"}
+	}
+	switch kind := strings.ToLower(types[0]); kind {
+	case "array", "map":
+		item := Type{Kind: "any"}
+		if len(types) > 1 {
+			item = typeOf(types[1:])
+		}
+		return Type{Kind: kind, Item: &item}
+	default:
+		t := Type{Kind: "string", Format: types[0]}
+		if k, ok := formatKinds[kind]; ok {
+			t.Kind = k
+		}
+		if t.Format == t.Kind || kind == "object" {
+			t.Format = ""
+		}
+		return t
+	}
+}
+
+// resourceType returns the type of a resource, adding the models of it and its
+// properties. An object's model is named by its title, or else by name, the name of
+// the property it is the object of.
+func (b *builder) resourceType(r *spec.Resource, name string) Type {
+	if len(r.Type) == 0 {
+		return Type{Kind: "any"}
+	}
+	switch strings.ToLower(r.Type[0]) {
+	case "array", "map":
+		item := Type{Kind: "any"}
+		switch {
+		case len(r.Type) > 1 && strings.ToLower(r.Type[1]) != "object":
+			item = typeOf(r.Type[1:])
+		case len(r.Type) > 1 || len(r.Properties) > 0:
+			// The resource's properties are those of its items
+			item = b.objectType(r, name)
+		}
+		return Type{Kind: strings.ToLower(r.Type[0]), Item: &item}
+	case "object":
+		return b.objectType(r, name)
+	}
+	return typeOf(r.Type)
+}
+
+// objectType returns the type of an object resource: a model of its properties, a
+// map given by additionalProperties, or else any. Models are of resources, by their
+// place in the specification, and a model whose name is already that of another
+// resource's is numbered, such as Item 2.
+func (b *builder) objectType(r *spec.Resource, name string) Type {
+	if additional, ok := r.Properties["<key>"]; ok && len(r.Properties) == 1 {
+		return b.resourceType(additional, name)
+	}
+	if len(r.Properties) == 0 {
+		return Type{Kind: "map", Item: &Type{Kind: "any"}}
+	}
+	key := strings.Join(append(append([]string{}, r.FQNS...), r.ID), ".")
+	if existing, ok := b.resources[key]; ok {
+		return Type{Kind: "model", Model: existing}
+	}
+
+	if len(r.Title) > 0 {
+		name = r.Title
+	}
+	if len(name) == 0 {
+		name = r.ID
+	}
+	for i, base := 2, name; b.models[name] != nil; i++ {
+		name = base + " " + strconv.Itoa(i)
+	}
+	b.resources[key] = name
+
+	m := &Model{Name: name, Description: text(r.Description)}
+	if m.Description == r.Title {
+		m.Description = ""
+	}
+	b.models[name] = m
+	b.order = append(b.order, name)
+
+	var properties []string
+	for property := range r.Properties {
+		if property != "<key>" {
+			properties = append(properties, property)
+		}
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		p := r.Properties[property]
+		description := text(p.Description)
+		if description == p.Title {
+			description = "" // Descriptions default to titles
+		}
+		m.Fields = append(m.Fields, Field{
+			Name:        property,
+			Description: description,
+			Required:    p.Required,
+			ReadOnly:    p.ReadOnly,
+			Enum:        p.Enum,
+			Type:        b.resourceType(p, name+" "+property),
+		})
+	}
+	return Type{Kind: "model", Model: name}
+}
+
+// ---------------------------------------------------------------------------
+// text returns the plain text of an HTML description, on one line, for comments.
+func text(description string) string {
+	s := html.UnescapeString(tagRegex.ReplaceAllString(description, " "))
+	return strings.TrimSpace(spaceRegex.ReplaceAllString(s, " "))
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,272 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package sdk
+
+// Client SDKs are generated at startup, in each language given by sdk, from the
+// current version of each specification. Archives of them are served as downloads
+// named by the specification's info.version, and are kept in sdk-dir, if given, so
+// that those of earlier versions may still be downloaded. Method pages link to the
+// function of each SDK that calls their operation.
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/spec"
+)
+
+// Unversioned is the version of the SDKs of a specification without an info.version.
+const Unversioned = "unversioned"
+
+// Characters of an info.version that may not be in the name of an archive, and
+// the versions that archives are named by
+var (
+	versionRegex        = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	archiveVersionRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// Generated is the SDK generated in a language for the current version of a
+// specification.
+type Generated struct {
+	Specification string
+	Language      string
+	Version       string
+	Files         []string // Sorted
+
+	sources   map[string][]byte
+	archive   []byte
+	functions map[string]Link // Operation key->Function
+}
+
+// Link is the function of an SDK that calls an operation.
+type Link struct {
+	Language string
+	Name     string // As the SDK calls it
+	File     string
+	Line     int
+	URL      string // Of the line in the SDK source page
+}
+
+// Download is the archives of the SDKs of a specification in a language.
+type Download struct {
+	Language string
+	Versions []string // The current version, then earlier versions kept in sdk-dir, latest first
+}
+
+// Specification ID->Language->SDK
+var generated = make(map[string]map[string]*Generated)
+
+// ---------------------------------------------------------------------------
+// Register generates the SDKs of each specification in each language given by sdk,
+// keeping their archives in sdk-dir if it is given.
+func Register() {
+	cfg, _ := config.Get()
+	if len(cfg.SDK) == 0 {
+		return
+	}
+	logger.Debugln(nil, "generating SDKs")
+
+	for _, language := range cfg.SDK {
+		if len(templates(language)) == 0 {
+			logger.Errorf(nil, "Error: No templates for the %s SDK, which are looked for in the sdk/%s directory of the theme and assets", language, language)
+			os.Exit(1)
+		}
+	}
+
+	for _, specification := range spec.APISuite {
+		model := newSpecification(specification)
+		generated[specification.ID] = make(map[string]*Generated)
+
+		for _, language := range cfg.SDK {
+			g, err := generate(language, model)
+			if err == nil {
+				g.archive, err = g.zip()
+			}
+			if err == nil && len(cfg.SDKDir) > 0 {
+				err = g.keep(cfg.SDKDir)
+			}
+			if err != nil {
+				logger.Errorf(nil, "Error generating the %s SDK of %s: %s", language, specification.ID, err)
+				os.Exit(1)
+			}
+			logger.Tracef(nil, "+ %s SDK %s of %s: %d files", language, g.Version, specification.ID, len(g.Files))
+			generated[specification.ID][language] = g
+		}
+	}
+}
+
+// version returns the version of a specification's SDKs.
+func version(specification *spec.APISpecification) string {
+	v := strings.Trim(versionRegex.ReplaceAllString(specification.APIInfo.Version, "-"), "-.")
+	if len(v) == 0 {
+		return Unversioned
+	}
+	return v
+}
+
+// ---------------------------------------------------------------------------
+// Get returns the SDK of a specification in a language, or nil if none is generated.
+func Get(specification string, language string) *Generated {
+	return generated[specification][language]
+}
+
+// ---------------------------------------------------------------------------
+// Downloads returns the archives of the SDKs of a specification, by language.
+func Downloads(specification string) []Download {
+	cfg, _ := config.Get()
+
+	var downloads []Download
+	for _, language := range cfg.SDK {
+		g := Get(specification, language)
+		if g == nil {
+			continue
+		}
+		d := Download{Language: language, Versions: []string{g.Version}}
+		for _, v := range kept(specification, language) {
+			if v != g.Version {
+				d.Versions = append(d.Versions, v)
+			}
+		}
+		downloads = append(downloads, d)
+	}
+	return downloads
+}
+
+// kept returns the versions of the archives of an SDK kept in sdk-dir, latest first.
+func kept(specification string, language string) []string {
+	cfg, _ := config.Get()
+	if len(cfg.SDKDir) == 0 {
+		return nil
+	}
+	infos, _ := ioutil.ReadDir(filepath.Join(cfg.SDKDir, specification, language))
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+
+	var versions []string
+	for _, info := range infos {
+		v := strings.TrimSuffix(info.Name(), ".zip")
+		if !info.IsDir() && v != info.Name() && archiveVersionRegex.MatchString(v) {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// ---------------------------------------------------------------------------
+// Archive returns the archive of a version of the SDK of a specification in a
+// language, from sdk-dir if it is not the current version.
+func Archive(specification string, language string, version string) ([]byte, bool) {
+	cfg, _ := config.Get()
+
+	g := Get(specification, language)
+	if g == nil || !archiveVersionRegex.MatchString(version) {
+		return nil, false
+	}
+	if version == g.Version {
+		return g.archive, true
+	}
+	if len(cfg.SDKDir) == 0 {
+		return nil, false
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(cfg.SDKDir, specification, language, version+".zip"))
+	return buf, err == nil
+}
+
+// ArchiveName returns the file name an archive is downloaded as.
+func ArchiveName(specification string, language string, version string) string {
+	return specification + "-" + language + "-" + version + ".zip"
+}
+
+// ---------------------------------------------------------------------------
+// Functions returns the function of each SDK of a specification that calls a
+// method's operation.
+func Functions(specification string, method *spec.Method) []Link {
+	cfg, _ := config.Get()
+
+	var links []Link
+	for _, language := range cfg.SDK {
+		if g := Get(specification, language); g != nil {
+			if link, ok := g.functions[OperationKey(method)]; ok {
+				links = append(links, link)
+			}
+		}
+	}
+	return links
+}
+
+// ---------------------------------------------------------------------------
+// Path returns the path of the source page of an SDK, where its files are listed.
+func (g *Generated) Path() string {
+	return fmt.Sprintf("/%s/sdk/%s/%s", g.Specification, g.Language, g.Version)
+}
+
+// URL returns the URL of a file of an SDK on its source page.
+func (g *Generated) URL(file string) string {
+	return g.Path() + "/" + file
+}
+
+// Source returns the source of a file of an SDK.
+func (g *Generated) Source(file string) ([]byte, bool) {
+	buf, ok := g.sources[file]
+	return buf, ok
+}
+
+// ---------------------------------------------------------------------------
+// zip returns an archive of an SDK's files, beneath a directory named as the
+// archive is.
+func (g *Generated) zip() ([]byte, error) {
+	dir := strings.TrimSuffix(ArchiveName(g.Specification, g.Language, g.Version), ".zip")
+	modified := time.Now()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, file := range g.Files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: dir + "/" + file, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(g.sources[file]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// keep writes an SDK's archive to sdk-dir, replacing any of the same version.
+func (g *Generated) keep(dir string) error {
+	dir = filepath.Join(dir, g.Specification, g.Language)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, g.Version+".zip"), g.archive, 0644)
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,210 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package sdk
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/spec"
+)
+
+func TestCases(t *testing.T) {
+	tests := []struct {
+		in     string
+		words  []string
+		pascal string
+		camel  string
+		snake  string
+		kebab  string
+	}{
+		{"pet", []string{"pet"}, "Pet", "pet", "pet", "pet"},
+		{"findPetsByStatus", []string{"find", "Pets", "By", "Status"}, "FindPetsByStatus", "findPetsByStatus", "find_pets_by_status", "find-pets-by-status"},
+		{"get-pet-by-id", []string{"get", "pet", "by", "id"}, "GetPetById", "getPetById", "get_pet_by_id", "get-pet-by-id"},
+		{"Swagger Petstore", []string{"Swagger", "Petstore"}, "SwaggerPetstore", "swaggerPetstore", "swagger_petstore", "swagger-petstore"},
+		{"HTTPServer", []string{"HTTP", "Server"}, "HttpServer", "httpServer", "http_server", "http-server"},
+		{"api_key", []string{"api", "key"}, "ApiKey", "apiKey", "api_key", "api-key"},
+		{"v2Users", []string{"v2", "Users"}, "V2Users", "v2Users", "v2_users", "v2-users"},
+		{"2fa code", []string{"2fa", "code"}, "_2faCode", "_2faCode", "_2fa_code", "2fa-code"},
+		{"", nil, "_", "_", "_", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			if w := words(test.in); !reflect.DeepEqual(w, test.words) {
+				t.Errorf("words: got %q, want %q", w, test.words)
+			}
+			if s := pascal(test.in); s != test.pascal {
+				t.Errorf("pascal: got %q, want %q", s, test.pascal)
+			}
+			if s := camel(test.in); s != test.camel {
+				t.Errorf("camel: got %q, want %q", s, test.camel)
+			}
+			if s := snake(test.in); s != test.snake {
+				t.Errorf("snake: got %q, want %q", s, test.snake)
+			}
+			if s := kebab(test.in); s != test.kebab {
+				t.Errorf("kebab: got %q, want %q", s, test.kebab)
+			}
+		})
+	}
+}
+
+// petstore loads the example petstore specification.
+func petstore(t *testing.T) *spec.APISpecification {
+	cfg, _ := config.Get()
+	cfg.DefaultAssetsDir = "../assets"
+	spec.LoadStatusCodes()
+
+	srv := httptest.NewServer(http.FileServer(http.Dir("../examples/specifications/petstore")))
+	defer srv.Close()
+
+	specification := &spec.APISpecification{}
+	if err := specification.Load(srv.URL+"/swagger.json", ""); err != nil {
+		t.Fatal(err)
+	}
+	return specification
+}
+
+func TestGenerate(t *testing.T) {
+	asset.Compile("../assets/themes/default/sdk", "assets/sdk")
+	s := newSpecification(petstore(t))
+
+	var models []string
+	for _, m := range s.Models {
+		models = append(models, m.Name)
+	}
+	if want := []string{"ApiResponse", "Order", "Pet", "Category", "Tag", "User"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("got models %v, want %v", models, want)
+	}
+
+	g, err := generate("go", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{"README.md", "access_to_petstore_orders.go", "client.go", "everything_about_your_pets.go", "go.mod", "models.go", "operations_about_user.go"}
+	if !reflect.DeepEqual(g.Files, files) {
+		t.Errorf("got files %v, want %v", g.Files, files)
+	}
+
+	if len(g.functions) != 20 {
+		t.Errorf("got %d functions, want one for each of the 20 operations", len(g.functions))
+	}
+	link, ok := g.functions["GET /v2/pet/{petId}"]
+	if !ok {
+		t.Fatal("no function for GET /v2/pet/{petId}")
+	}
+	if link.Name != "client.EverythingAboutYourPets.GetPetById" || link.File != "everything_about_your_pets.go" {
+		t.Errorf("got function %s in %s", link.Name, link.File)
+	}
+	if lines := strings.Split(string(g.sources[link.File]), "\n"); link.Line < 1 || link.Line > len(lines) || !strings.Contains(lines[link.Line-1], "GetPetById(") {
+		t.Errorf("line %d of %s is not the function GetPetById", link.Line, link.File)
+	}
+
+	fset := token.NewFileSet()
+	for _, file := range g.Files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		if _, err := parser.ParseFile(fset, file, g.sources[file], 0); err != nil {
+			t.Errorf("%s is not valid Go: %s", file, err)
+		}
+	}
+}
+
+// Models of different resources with the same title are numbered, rather than the
+// fields of all but the first being lost.
+func TestModelNames(t *testing.T) {
+	item := func(field string) *spec.Resource {
+		return &spec.Resource{ID: "item", Title: "Item", Type: []string{"object"}, Properties: map[string]*spec.Resource{
+			field: {ID: field, Type: []string{"string"}},
+		}}
+	}
+	cartItem, orderItem := item("sku"), item("quantity")
+	cartItem.FQNS, orderItem.FQNS = []string{"cart"}, []string{"order"}
+
+	specification := &spec.APISpecification{
+		ID:   "shop",
+		APIs: spec.APISet{{ID: "orders", Name: "Orders"}},
+		ResourceList: map[string]map[string]*spec.Resource{"": {
+			"cart":  {ID: "cart", Title: "Cart", Type: []string{"object"}, Properties: map[string]*spec.Resource{"item": cartItem}},
+			"order": {ID: "order", Title: "Order", Type: []string{"object"}, Properties: map[string]*spec.Resource{"item": orderItem}},
+		}},
+	}
+	s := newSpecification(specification)
+
+	fields := make(map[string]string)
+	for _, m := range s.Models {
+		for _, f := range m.Fields {
+			fields[m.Name+"."+f.Name] = f.Type.Model
+		}
+	}
+	want := map[string]string{"Cart.item": "Item", "Item.sku": "", "Order.item": "Item 2", "Item 2.quantity": ""}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got model fields %v, want %v", fields, want)
+	}
+}
+
+func TestAPINames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "[api.snake].txt.tpl"), []byte(`[: .API.Name :]`), 0644)
+	asset.Compile(dir, "assets/sdk/apis")
+
+	s := newSpecification(&spec.APISpecification{
+		ID:   "zoo",
+		APIs: spec.APISet{{ID: "pets", Name: "Pets"}, {ID: "pets-2", Name: "pets"}},
+	})
+	g, err := generate("apis", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pets.txt", "pets_2.txt"}; !reflect.DeepEqual(g.Files, want) {
+		t.Errorf("got files %v, want %v", g.Files, want)
+	}
+	if source := string(g.sources["pets_2.txt"]); source != "pets 2" {
+		t.Errorf("got pets_2.txt %q, want %q", source, "pets 2")
+	}
+}
+
+func TestGenerateNoFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "_helpers.tpl"), []byte(`[: define "name" :][: .ID :][: end :]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "[api.snake].txt.tpl"), []byte(`[: .API.Name :]`), 0644)
+	asset.Compile(dir, "assets/sdk/none")
+
+	if _, err := generate("none", &Specification{ID: "empty"}); err == nil || !strings.Contains(err.Error(), "no files") {
+		t.Errorf("got %v, want an error for there being no files", err)
+	}
+}
@@ -0,0 +1,341 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package security
+
+// The security policy sets the Content-Security-Policy, framing, referrer and CORS
+// headers of responses by the class of route they are served from, and decides
+// which requests are subject to CSRF protection.
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/asset"
+)
+
+// Classes of route, as named by cors-origin
+const (
+	Pages  = "pages"
+	Specs  = "specs"
+	Static = "static"
+	Proxy  = "proxy"
+	Mock   = "mock"
+)
+
+// Classes lists the classes of route.
+var Classes = []string{Pages, Specs, Static, Proxy, Mock}
+
+// CSRFCookieName is the cookie holding the CSRF token. It is readable by the
+// explorer, which returns it in the CSRFHeader of state-changing requests.
+const CSRFCookieName = "csrf_token"
+
+// CSRFHeader is the request header carrying the CSRF token.
+const CSRFHeader = "X-CSRF-Token"
+
+var (
+	routes   = make(map[string]string) // Path->class
+	prefixes = make(map[string]string) // Path prefix->class
+	mutex    sync.RWMutex
+
+	contentPolicy string // Content-Security-Policy of pages, built by Register
+)
+
+// Template tags that load a script or stylesheet, and the site they load it from
+var (
+	tagRegex      = regexp.MustCompile(`<(script|link)\b[^>]*>`)
+	externalRegex = regexp.MustCompile(`\b(?:src|href)="((?:https?:)?//[^/"]+)`)
+)
+
+// ---------------------------------------------------------------------------
+// Route classes the route at path.
+func Route(class string, path string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	routes[path] = class
+}
+
+// ---------------------------------------------------------------------------
+// RoutePrefix classes the routes beneath prefix.
+func RoutePrefix(class string, prefix string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	prefixes[prefix] = class
+}
+
+// ---------------------------------------------------------------------------
+// Class returns the class of the route a request is for. Routes not otherwise
+// classed are pages.
+func Class(req *http.Request) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if class, ok := routes[req.URL.Path]; ok {
+		return class
+	}
+	longest, class := 0, Pages
+	for prefix, c := range prefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) && len(prefix) > longest {
+			longest, class = len(prefix), c
+		}
+	}
+	return class
+}
+
+// ---------------------------------------------------------------------------
+// Register builds the Content-Security-Policy of pages, allowing the scripts and
+// stylesheets that the compiled templates load from other sites. It must be called
+// after the templates are compiled (see render.Register).
+func Register() {
+	cfg, _ := config.Get()
+
+	switch cfg.ContentPolicy {
+	case "none":
+		contentPolicy = ""
+	case "":
+		contentPolicy = defaultPolicy()
+	default:
+		contentPolicy = cfg.ContentPolicy
+	}
+	if len(contentPolicy) > 0 && !strings.Contains(contentPolicy, "frame-ancestors") {
+		contentPolicy = strings.TrimSuffix(strings.TrimSpace(contentPolicy), ";") + "; frame-ancestors " + strings.Join(frameAncestors(), " ")
+	}
+	logger.Debugf(nil, "Content-Security-Policy: %s", contentPolicy)
+}
+
+// ---------------------------------------------------------------------------
+// defaultPolicy returns a Content-Security-Policy allowing what the templates and
+// static-url load. Pages have inline scripts and styles, category logos and guide
+// images may come from anywhere, and the explorer calls whatever APIs the
+// specifications describe.
+func defaultPolicy() string {
+	cfg, _ := config.Get()
+
+	scripts := map[string]bool{"'self'": true, "'unsafe-inline'": true}
+	styles := map[string]bool{"'self'": true, "'unsafe-inline'": true}
+
+	for _, name := range asset.AssetNames() {
+		if !strings.HasPrefix(name, "assets/templates/") {
+			continue
+		}
+		buf, _ := asset.Asset(name)
+		for _, tag := range tagRegex.FindAllStringSubmatch(string(buf), -1) {
+			m := externalRegex.FindStringSubmatch(tag[0])
+			if m == nil {
+				continue
+			}
+			source := m[1]
+			if strings.HasPrefix(source, "//") {
+				source = "https:" + source
+			}
+			switch {
+			case tag[1] == "script":
+				scripts[source] = true
+			case strings.Contains(tag[0], "stylesheet"):
+				styles[source] = true
+			}
+		}
+	}
+	if u, err := url.Parse(cfg.StaticURL); err == nil && len(u.Host) > 0 {
+		scripts[u.Scheme+"://"+u.Host] = true
+		styles[u.Scheme+"://"+u.Host] = true
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src " + sources(scripts),
+		"style-src " + sources(styles),
+		"img-src * data: blob:",
+		"font-src 'self' data: https:",
+		"connect-src *",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}, "; ")
+}
+
+func sources(set map[string]bool) string {
+	var list []string
+	for source := range set {
+		list = append(list, source)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+// ---------------------------------------------------------------------------
+// frameAncestors returns the origins allowed to frame pages, defaulting to 'self'.
+func frameAncestors() []string {
+	cfg, _ := config.Get()
+	if len(cfg.FrameAncestors) == 0 {
+		return []string{"'self'"}
+	}
+	return cfg.FrameAncestors
+}
+
+// ---------------------------------------------------------------------------
+// corsOrigins returns the origins allowed cross-origin access to a class of route.
+// Without any configured, specifications, static assets and the mock may be used by
+// anyone.
+func corsOrigins(class string) []string {
+	cfg, _ := config.Get()
+
+	if len(cfg.CORSOrigin) == 0 {
+		if class == Specs || class == Static || class == Mock {
+			return []string{"*"}
+		}
+		return nil
+	}
+	var origins []string
+	for _, v := range cfg.CORSOrigin {
+		// CORSOrigin values have been validated by config.Get
+		if pair, _ := config.ParsePair(v); pair.From == class {
+			origins = append(origins, strings.TrimSuffix(pair.To, "/"))
+		}
+	}
+	return origins
+}
+
+// ---------------------------------------------------------------------------
+// HasCORS returns true if a CORS policy is configured for a class of route.
+func HasCORS(class string) bool {
+	return len(corsOrigins(class)) > 0
+}
+
+// ---------------------------------------------------------------------------
+// CORS sets in header the CORS response headers that the policy of a class of route
+// gives the request's origin, if any.
+func CORS(class string, req *http.Request, header http.Header) {
+	origin := req.Header.Get("Origin")
+	if len(origin) == 0 {
+		return
+	}
+	for _, allowed := range corsOrigins(class) {
+		switch allowed {
+		case "*":
+			header.Set("Access-Control-Allow-Origin", "*")
+			return
+		case origin:
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Add("Vary", "Origin")
+			return
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CSRF returns true if a request is subject to CSRF protection. Pages are, so that
+// readers are given a token, as are state-changing proxied requests, unless
+// proxy-no-csrf is set. Other proxied requests are not, so that proxied responses
+// never set the token's cookie. Specifications, static assets and the mock change
+// nothing, and are used by other sites.
+func CSRF(req *http.Request) bool {
+	cfg, _ := config.Get()
+
+	switch Class(req) {
+	case Pages:
+		return true
+	case Proxy:
+		switch req.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			return false
+		}
+		return !cfg.ProxyNoCSRF
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// CSRFCookie returns the cookie the CSRF token is given to readers in. It is not
+// HttpOnly, so that the explorer can read it.
+func CSRFCookie() http.Cookie {
+	return http.Cookie{
+		Name:     CSRFCookieName,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Handler sets the security headers of the request's class of route, and answers
+// CORS preflight requests for classes that have a CORS policy. Proxied responses
+// are left to the proxy, which applies any CORS policy over the service's own.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg, _ := config.Get()
+		class := Class(req)
+
+		if req.Method == "OPTIONS" && len(req.Header.Get("Access-Control-Request-Method")) > 0 && HasCORS(class) {
+			preflight(class, w, req)
+			return
+		}
+		if class == Proxy {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		CORS(class, req, header)
+
+		if class == Pages {
+			if len(contentPolicy) > 0 {
+				header.Set("Content-Security-Policy", contentPolicy)
+			}
+			// X-Frame-Options, for browsers without frame-ancestors, can only express these
+			switch ancestors := frameAncestors(); {
+			case len(ancestors) == 1 && ancestors[0] == "'self'":
+				header.Set("X-Frame-Options", "SAMEORIGIN")
+			case len(ancestors) == 1 && ancestors[0] == "'none'":
+				header.Set("X-Frame-Options", "DENY")
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// preflight answers a CORS preflight request. Proxied and mock routes allow any
+// method and request header, as the service behind them decides what it accepts.
+func preflight(class string, w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	CORS(class, req, header)
+
+	if len(header.Get("Access-Control-Allow-Origin")) > 0 {
+		if class == Proxy || class == Mock {
+			header.Set("Access-Control-Allow-Methods", req.Header.Get("Access-Control-Request-Method"))
+			if headers := req.Header.Get("Access-Control-Request-Headers"); len(headers) > 0 {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+		} else {
+			header.Set("Access-Control-Allow-Methods", "GET, HEAD")
+		}
+		header.Set("Access-Control-Max-Age", "600")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------------------------------------------------------------
+// end
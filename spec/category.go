@@ -0,0 +1,237 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/shurcooL/github_flavored_markdown"
+)
+
+// Category is a node of the category tree. Specifications are filed under the
+// category whose ID matches their x-category, or configuration file category.
+type Category struct {
+	ID             string
+	Name           string
+	Description    string // HTML, rendered from markdown
+	Color          string // Inherited from the parent if not configured
+	Logo           string // Inherited from the parent if not configured
+	Depth          int                 // 0 for a root category
+	Parent         *Category           // nil for a root category
+	Children       []*Category         // In display order
+	Specifications []*APISpecification // Filed directly under this category, ordered by title
+	Order          int                 // Configured sibling order
+}
+
+// Categories is the root of the category tree, in display order.
+var Categories []*Category
+
+var categoryIndex map[string]*Category
+
+// The tree used when none is configured, matching the original fixed suites.
+var defaultCategories = []config.CategoryConfig{
+	{ID: "business-service", Name: "Business API", Color: "#733ca6", Logo: "https://s3.amazonaws.com/wixplorer-readme-images/business-logo-white.svg"},
+	{ID: "core", Name: "Core API", Color: "#4596e0", Logo: "https://s3.amazonaws.com/wixplorer-readme-images/core-logo-white.svg"},
+	{ID: "client-frameworks", Name: "Client Frameworks", Color: "#b13dac", Logo: "https://s3.amazonaws.com/wixplorer-readme-images/editor-logo-white.svg"},
+}
+
+// -----------------------------------------------------------------------------
+// GetCategory returns the category with the given ID, or nil.
+func GetCategory(id string) *Category {
+	return categoryIndex[id]
+}
+
+// -----------------------------------------------------------------------------
+// CategoryList returns the whole category tree flattened depth first, in display
+// order, for building lists such as filter menus.
+func CategoryList() []*Category {
+	var list []*Category
+	var walk func([]*Category)
+	walk = func(categories []*Category) {
+		for _, c := range categories {
+			list = append(list, c)
+			walk(c.Children)
+		}
+	}
+	walk(Categories)
+	return list
+}
+
+// -----------------------------------------------------------------------------
+// CategoryNode returns the category the specification is filed under, or nil if
+// it is uncategorised.
+func (c *APISpecification) CategoryNode() *Category {
+	return categoryIndex[c.Category]
+}
+
+// -----------------------------------------------------------------------------
+// Path returns the category's ancestors and itself, from the root down.
+func (c *Category) Path() []*Category {
+	var path []*Category
+	for n := c; n != nil; n = n.Parent {
+		path = append([]*Category{n}, path...)
+	}
+	return path
+}
+
+// -----------------------------------------------------------------------------
+// AllSpecifications returns the specifications filed under the category or any
+// of its descendants.
+func (c *Category) AllSpecifications() []*APISpecification {
+	specs := append([]*APISpecification{}, c.Specifications...)
+	for _, child := range c.Children {
+		specs = append(specs, child.AllSpecifications()...)
+	}
+	return specs
+}
+
+// -----------------------------------------------------------------------------
+// HasStatus returns true if any specification filed under the category or its
+// descendants has the given x-status.
+func (c *Category) HasStatus(status string) bool {
+	for _, s := range c.AllSpecifications() {
+		if s.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Listed returns the specifications filed directly under the category that belong
+// in a section of the specification list: those with the dev status when soon is
+// true, otherwise the rest. Unpublished specifications are only listed in preview.
+func (c *Category) Listed(soon bool, preview bool) []*APISpecification {
+	var specs []*APISpecification
+	for _, s := range c.Specifications {
+		if (s.Status == "dev") == soon && (preview || s.Published()) {
+			specs = append(specs, s)
+		}
+	}
+	return specs
+}
+
+// -----------------------------------------------------------------------------
+// HasListed returns true if Listed would return a specification for the category
+// or any of its descendants.
+func (c *Category) HasListed(soon bool, preview bool) bool {
+	if len(c.Listed(soon, preview)) > 0 {
+		return true
+	}
+	for _, child := range c.Children {
+		if child.HasListed(soon, preview) {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Contains returns true if the category is, or is an ancestor of, the category id.
+func (c *Category) Contains(id string) bool {
+	for n := categoryIndex[id]; n != nil; n = n.Parent {
+		if n == c {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// IDs returns the IDs of the category and its ancestors, space separated, for
+// use as an HTML data attribute by filters that match on any level of the tree.
+func (c *Category) IDs() string {
+	var ids []string
+	for _, n := range c.Path() {
+		ids = append(ids, n.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+// -----------------------------------------------------------------------------
+// buildCategories builds the category tree from configuration, and files the
+// specifications under it. A category referenced by a specification but not
+// configured is added to the root, so that no specification is lost.
+func buildCategories(specifications []*APISpecification) {
+	cfg, _ := config.Get()
+
+	tree := cfg.Categories()
+	if len(tree) == 0 {
+		tree = defaultCategories
+	}
+
+	categoryIndex = make(map[string]*Category)
+	Categories = addCategories(tree, nil)
+
+	for _, s := range specifications {
+		if len(s.Category) == 0 {
+			continue
+		}
+		category, ok := categoryIndex[s.Category]
+		if !ok {
+			logger.Warnf(nil, "Specification %s category '%s' is not configured, so adding it to the category tree", s.URL, s.Category)
+			category = &Category{ID: s.Category, Name: s.Category}
+			categoryIndex[s.Category] = category
+			Categories = append(Categories, category)
+		}
+		category.Specifications = append(category.Specifications, s)
+	}
+
+	for _, category := range categoryIndex {
+		sort.SliceStable(category.Specifications, func(i, j int) bool {
+			return strings.ToLower(category.Specifications[i].APIInfo.Title) < strings.ToLower(category.Specifications[j].APIInfo.Title)
+		})
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func addCategories(tree []config.CategoryConfig, parent *Category) []*Category {
+	var categories []*Category
+
+	for _, c := range tree {
+		category := &Category{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: string(github_flavored_markdown.Markdown([]byte(c.Description))),
+			Color:       c.Color,
+			Logo:        c.Logo,
+			Order:       c.Order,
+			Parent:      parent,
+		}
+		if parent != nil {
+			category.Depth = parent.Depth + 1
+			if len(category.Color) == 0 {
+				category.Color = parent.Color
+			}
+			if len(category.Logo) == 0 {
+				category.Logo = parent.Logo
+			}
+		}
+		categoryIndex[c.ID] = category
+		category.Children = addCategories(c.Categories, category)
+		categories = append(categories, category)
+	}
+
+	// Order by the configured order, falling back to the order they were given in
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Order < categories[j].Order })
+	return categories
+}
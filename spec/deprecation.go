@@ -0,0 +1,190 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Deprecation records that an operation, parameter, header or schema property is
+// deprecated. Operations are deprecated by the standard deprecated member, and
+// everything else by the x-deprecated extension, as Swagger 2.0 has no deprecated
+// member for them. Giving x-deprecated-since or x-sunset also marks it deprecated.
+type Deprecation struct {
+	Since       string // x-deprecated-since: the version or date it was deprecated
+	Sunset      string // x-sunset: the date it is to be removed
+	Replacement string // x-replaced-by: what to use instead
+}
+
+// DeprecatedItem is an entry of the deprecation report of a specification.
+type DeprecatedItem struct {
+	Kind        string // "operation", "parameter", "header", "resource" or "property"
+	Name        string
+	Operation   string // "METHOD /path" of the operation a parameter or header belongs to
+	URL         string // The page that documents the item
+	Deprecation *Deprecation
+}
+
+// -----------------------------------------------------------------------------
+// newDeprecation returns the deprecation given by the deprecated member, if any,
+// and the extensions, or nil if the item is not deprecated.
+func newDeprecation(deprecated bool, ext spec.Extensions) *Deprecation {
+	if flag, ok := ext["x-deprecated"].(bool); ok {
+		deprecated = deprecated || flag
+	}
+	d := &Deprecation{
+		Since:       extensionString(ext["x-deprecated-since"]),
+		Sunset:      extensionString(ext["x-sunset"]),
+		Replacement: extensionString(ext["x-replaced-by"]),
+	}
+	if !deprecated && len(d.Since) == 0 && len(d.Sunset) == 0 {
+		return nil
+	}
+	return d
+}
+
+func extensionString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// -----------------------------------------------------------------------------
+// Deprecations returns every deprecated element of the specification: operations
+// with their parameters and response headers, in navigation order, followed by
+// resources and their properties, ordered by version and ID.
+func (c *APISpecification) Deprecations() []DeprecatedItem {
+	return c.deprecations
+}
+
+// -----------------------------------------------------------------------------
+// findDeprecations builds the list returned by Deprecations. It must be called
+// once the specification's ID is final, as the list holds page URLs.
+func (c *APISpecification) findDeprecations() []DeprecatedItem {
+	items := make([]DeprecatedItem, 0)
+	base := "/" + c.ID
+
+	for _, api := range c.APIs {
+		for _, method := range api.Methods {
+			url := base + "/reference/" + api.ID + "/" + method.ID
+			operation := strings.ToUpper(method.Method) + " " + method.Path
+
+			if method.Deprecation != nil {
+				items = append(items, DeprecatedItem{Kind: "operation", Name: method.Name, Operation: operation, URL: url, Deprecation: method.Deprecation})
+			}
+			for _, p := range method.Parameters() {
+				if p.Deprecation != nil {
+					items = append(items, DeprecatedItem{Kind: "parameter", Name: p.Name, Operation: operation, URL: url, Deprecation: p.Deprecation})
+				}
+			}
+			for _, response := range method.AllResponses() {
+				for _, h := range response.Headers {
+					if h.Deprecation != nil {
+						items = append(items, DeprecatedItem{Kind: "header", Name: h.Name, Operation: operation, URL: url, Deprecation: h.Deprecation})
+					}
+				}
+			}
+		}
+	}
+
+	var versions []string
+	for version := range c.ResourceList {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	for _, version := range versions {
+		var ids []string
+		for id := range c.ResourceList[version] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			resource := c.ResourceList[version][id]
+			url := base + "/resources/" + id
+			if version != "latest" {
+				url += "?v=" + version
+			}
+			if resource.Deprecation != nil {
+				items = append(items, DeprecatedItem{Kind: "resource", Name: resource.Title, URL: url, Deprecation: resource.Deprecation})
+			}
+			items = append(items, deprecatedProperties(resource, url)...)
+		}
+	}
+	return items
+}
+
+func deprecatedProperties(resource *Resource, url string) []DeprecatedItem {
+	var names []string
+	for name := range resource.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []DeprecatedItem
+	for _, name := range names {
+		property := resource.Properties[name]
+		if property.Deprecation != nil {
+			fqn := strings.Join(append(append([]string{}, property.FQNS...), property.ID), ".")
+			items = append(items, DeprecatedItem{Kind: "property", Name: fqn, URL: url, Deprecation: property.Deprecation})
+		}
+		items = append(items, deprecatedProperties(property, url)...)
+	}
+	return items
+}
+
+// -----------------------------------------------------------------------------
+// Parameters returns all of the method's request parameters, body last.
+func (m *Method) Parameters() []Parameter {
+	var params []Parameter
+	params = append(params, m.PathParams...)
+	params = append(params, m.QueryParams...)
+	params = append(params, m.HeaderParams...)
+	params = append(params, m.FormParams...)
+	if m.BodyParam != nil {
+		params = append(params, *m.BodyParam)
+	}
+	return params
+}
+
+// -----------------------------------------------------------------------------
+// AllResponses returns the method's responses in status code order, followed by
+// the default response.
+func (m *Method) AllResponses() []*Response {
+	var statuses []int
+	for status := range m.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	var responses []*Response
+	for _, status := range statuses {
+		response := m.Responses[status]
+		responses = append(responses, &response)
+	}
+	if m.DefaultResponse != nil {
+		responses = append(responses, m.DefaultResponse)
+	}
+	return responses
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package spec
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/wix/dapperdox/logger"
+	"github.com/go-openapi/spec"
+)
+
+// IDExtension is the vendor extension that gives a specification, tag, path,
+// operation or schema an explicit URL identifier, in place of the one derived
+// from its title, summary or operation ID. An explicit ID is never changed to
+// resolve a collision: a duplicate is an error.
+const IDExtension = "x-dapperdox-id"
+
+// -----------------------------------------------------------------------------
+// extensionID returns the x-dapperdox-id value of a set of extensions, if given.
+// An ID that is not usable as a URL path segment is fatal.
+func extensionID(ext spec.Extensions, where string) (string, bool) {
+	id, ok := ext[IDExtension].(string)
+	if !ok || len(id) == 0 {
+		return "", false
+	}
+	if strings.Contains(id, "/") || url.PathEscape(id) != id {
+		logger.Errorf(nil, "Error: %s %s '%s' is not a valid URL path segment.", where, IDExtension, id)
+		os.Exit(1)
+	}
+	return id, true
+}
+
+// -----------------------------------------------------------------------------
+// uniqueID returns id, or if it is already taken the first free id-2, id-3 etc.
+// Disambiguation is deterministic provided that IDs are claimed in a stable order.
+func uniqueID(id string, taken func(string) bool) string {
+	candidate := id
+	for n := 2; taken(candidate); n++ {
+		candidate = fmt.Sprintf("%s-%d", id, n)
+	}
+	return candidate
+}
+
+// -----------------------------------------------------------------------------
+// assignSpecificationIDs makes the IDs of the loaded specifications unique. Explicit
+// IDs (from configuration or x-dapperdox-id) are claimed first, in load order, then
+// derived IDs are disambiguated around them.
+func assignSpecificationIDs(specifications []*APISpecification) error {
+	ids := make(map[string]*APISpecification)
+
+	for _, s := range specifications {
+		if s.Sources["id"] != SourceConfig && s.Sources["id"] != SourceExtension {
+			continue
+		}
+		if other, ok := ids[s.ID]; ok {
+			return fmt.Errorf("specifications %s and %s both have the explicit ID '%s'", other.URL, s.URL, s.ID)
+		}
+		ids[s.ID] = s
+	}
+
+	for _, s := range specifications {
+		if s.Sources["id"] == SourceConfig || s.Sources["id"] == SourceExtension {
+			continue
+		}
+		id := uniqueID(s.ID, func(id string) bool { _, ok := ids[id]; return ok })
+		if id != s.ID {
+			logger.Warnf(nil, "Specification %s ID '%s' is already in use, so using '%s'. Give it an explicit %s to choose another.", s.URL, s.ID, id, IDExtension)
+			s.ID = id
+		}
+		ids[s.ID] = s
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// assignAPIGroupID gives api a unique ID within the specification, unless it has
+// an explicit one.
+func (c *APISpecification) assignAPIGroupID(api *APIGroup, explicit bool) {
+	taken := func(id string) bool {
+		for _, a := range c.APIs {
+			if a.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	if explicit {
+		if taken(api.ID) {
+			logger.Errorf(nil, "Error: Specification %s has more than one API with %s '%s'.", c.URL, IDExtension, api.ID)
+			os.Exit(1)
+		}
+		return
+	}
+	if id := uniqueID(api.ID, taken); id != api.ID {
+		logger.Warnf(nil, "API '%s' ID '%s' is already in use in specification %s, so using '%s'.", api.Name, api.ID, c.URL, id)
+		api.ID = id
+	}
+}
+
+// -----------------------------------------------------------------------------
+// methodID gives a method a unique ID within its API, unless it has an explicit one.
+func methodID(api *APIGroup, id string, explicit bool, path, methodname string) string {
+	taken := func(id string) bool {
+		for _, m := range api.Methods {
+			if m.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	if explicit {
+		if taken(id) {
+			logger.Errorf(nil, "Error: %s %s has %s '%s', which is already used by another operation of API '%s'.", strings.ToUpper(methodname), path, IDExtension, id, api.Name)
+			os.Exit(1)
+		}
+		return id
+	}
+	unique := uniqueID(id, taken)
+	if unique != id {
+		logger.Warnf(nil, "%s %s ID '%s' is already in use in API '%s', so using '%s'.", strings.ToUpper(methodname), path, id, api.Name, unique)
+	}
+	return unique
+}
+
+// -----------------------------------------------------------------------------
+// resourceID returns the ID under which resource is listed for version. Two
+// differently titled models that derive the same ID (for example "Pet" and "pet")
+// are disambiguated, while the same model seen from several methods shares one.
+// An explicit ID already held by a differently titled model is fatal.
+func (c *APISpecification) resourceID(resource *Resource, version string) string {
+	if resource.explicitID {
+		if existing, ok := c.ResourceList[version][resource.ID]; ok && existing.Title != resource.Title {
+			logger.Errorf(nil, "Error: Resource '%s' has %s '%s', which is already used by resource '%s' in specification %s.", resource.Title, IDExtension, resource.ID, existing.Title, c.URL)
+			os.Exit(1)
+		}
+		return resource.ID
+	}
+	id := uniqueID(resource.ID, func(id string) bool {
+		existing, ok := c.ResourceList[version][id]
+		return ok && existing.Title != resource.Title
+	})
+	if id != resource.ID {
+		logger.Warnf(nil, "Resource '%s' ID '%s' is already in use by '%s', so using '%s'.", resource.Title, resource.ID, c.ResourceList[version][resource.ID].Title, id)
+	}
+	return id
+}
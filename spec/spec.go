@@ -46,17 +46,39 @@ type APISpecification struct {
 	Visible  bool
 	Approved  bool
 
+	BasePath            string                   // Prefixing the path of every operation, or empty if none does
+	Environments        []config.Environment     // Explorer target environments, from the configuration file
+	Sources             map[string]SettingSource // Setting name (id, title, category, ...)->Where its value came from
 	SecurityDefinitions map[string]SecurityScheme
 	DefaultSecurity     map[string]Security
 	ResourceList        map[string]map[string]*Resource // Version->ResourceName->Resource
 	APIVersions         map[string]APISet               // Version->APISet
+
+	deprecations []DeprecatedItem
 }
 
+// SettingSource records where a per-specification setting took its value from.
+type SettingSource string
+
+const (
+	SourceDefault       SettingSource = "default"       // Not set anywhere, so the built in default
+	SourceSpecification SettingSource = "specification" // A standard member of the specification, such as info.title
+	SourceExtension     SettingSource = "extension"     // An x- vendor extension in the specification
+	SourceConfig        SettingSource = "config"        // The specification's configuration file section
+)
+
 var APISuite map[string]*APISpecification
 var BusinessSuite map[string]*APISpecification
 var NoCategorySuite map[string]*APISpecification
 var CoreSuite map[string]*APISpecification
 
+// Published returns true if the specification is shown to readers who are not in
+// preview mode: it is approved, or approval is not required.
+func (c *APISpecification) Published() bool {
+	cfg, _ := config.Get()
+	return c.Approved || !cfg.RequireApproval
+}
+
 // GetByName returns an API by name
 func (c *APISpecification) GetByName(name string) *APIGroup {
 	for _, a := range c.APIs {
@@ -82,6 +104,8 @@ type APISet []APIGroup
 type Info struct {
 	Title       string
 	Description string
+	Logo        string // Logo image URL
+	Version     string // info.version of the specification
 }
 
 // APIGroup parents all grouped API methods (Grouping controlled by tagging, if used, or by method path otherwise)
@@ -99,6 +123,8 @@ type APIGroup struct {
 	Produces               []string
 	MainResource           MainResource
 	Readmes                []string
+	Key                    string // Stable identity of the group within its specification ("tag:name" or "path:/path")
+	Status                 string // x-status of the tag or path, such as beta or deprecated
 }
 
 type Version struct {
@@ -136,6 +162,7 @@ type Method struct {
 	Description     string
 	Method          string
 	OperationName   string
+	OperationID     string // As given by the specification, possibly empty
 	NavigationName  string
 	Path            string
 	Consumes        []string
@@ -151,6 +178,8 @@ type Method struct {
 	Security        map[string]Security
 	APIGroup        *APIGroup
 	SortKey         string
+	Status          string       // x-status of the operation, such as beta or deprecated
+	Deprecation     *Deprecation // nil unless deprecated
 }
 
 // Parameter represents an API method parameter
@@ -163,14 +192,16 @@ type Parameter struct {
 	Required                    bool
 	Type                        []string
 	Enum                        []string
-	Resource                    *Resource // For "in body" parameters
+	Resource                    *Resource    // For "in body" parameters
+	Deprecation                 *Deprecation // nil unless deprecated
 }
 
 // Response represents an API method response
 type Response struct {
 	Description       string
 	StatusDescription string
-	Resource          *Resource
+	Resource          *Resource // Shared by every method returning a resource of the same ID
+	Body              *Resource // The response's own resource, which may differ from Resource, such as by being an array of it
 	Headers           []Header
 }
 
@@ -196,7 +227,9 @@ type Resource struct {
 	ExcludeFromOperations []string
 	Methods               map[string]*Method
 	Enum                  []string
+	Deprecation           *Deprecation // nil unless deprecated
 	origin                ResourceOrigin
+	explicitID            bool // ID was given by x-dapperdox-id
 }
 type MainResource struct {
 	Resource Resource
@@ -212,6 +245,7 @@ type Header struct {
 	Default                     string
 	Required                    bool
 	Enum                        []string
+	Deprecation                 *Deprecation // nil unless deprecated
 }
 
 // -----------------------------------------------------------------------------
@@ -285,6 +319,8 @@ func LoadSpecifications(specHost string, collapse bool) error {
 		logger.Tracef(nil, "Serving specifications from %s\n", specHost)
 	}
 
+	var loaded []*APISpecification
+
 	for _, specLocation := range cfg.SpecFilename {
 
 		var ok bool
@@ -303,6 +339,21 @@ func LoadSpecifications(specHost string, collapse bool) error {
 			//specification.ID = "api"
 		}
 
+		if !ok || !collapse {
+			loaded = append(loaded, specification)
+		}
+	}
+
+	// Specifications sharing an ID would overwrite each other, so make them unique
+	if err = assignSpecificationIDs(loaded); err != nil {
+		return err
+	}
+
+	buildCategories(loaded)
+
+	// The fixed suites are kept for themes that predate the category tree
+	for _, specification := range loaded {
+		specification.deprecations = specification.findDeprecations()
 		APISuite[specification.ID] = specification
 		if specification.Category == "core" {
 			CoreSuite[specification.ID] = specification
@@ -311,12 +362,26 @@ func LoadSpecifications(specHost string, collapse bool) error {
 		} else {
 			NoCategorySuite[specification.ID] = specification
 		}
-
 	}
 
 	return nil
 }
 
+// -----------------------------------------------------------------------------
+// getLogo returns the image URL of an info.x-logo extension, which may either be
+// the URL itself or, as popularised by ReDoc, an object with a url member.
+func getLogo(extension interface{}) string {
+	switch logo := extension.(type) {
+	case string:
+		return logo
+	case map[string]interface{}:
+		if u, ok := logo["url"].(string); ok {
+			return u
+		}
+	}
+	return ""
+}
+
 // -----------------------------------------------------------------------------
 // Load loads API specs from the supplied host (usually local!)
 func (c *APISpecification) Load(specLocation string, specHost string) error {
@@ -339,6 +404,9 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 	if basePathLen == 1 && basePath[0] == '/' {
 		basePathLen = 0
 	}
+	if basePathLen > 0 {
+		c.BasePath = basePath
+	}
 
 	scheme := "http"
 	if apispec.Schemes != nil {
@@ -350,69 +418,137 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 		return err
 	}
 
-	c.APIInfo.Description = string(github_flavored_markdown.Markdown([]byte(apispec.Info.Description)))
+	// The specification's configuration file section, if any, overrides its extensions
+	cfg, _ := config.Get()
+	section := cfg.SpecSection(specLocation)
+	if section == nil {
+		section = &config.SpecConfig{}
+	}
+	c.Sources = make(map[string]SettingSource)
+
 	c.APIInfo.Title = apispec.Info.Title
+	c.Sources["title"] = SourceSpecification
+	if len(section.Title) > 0 {
+		c.APIInfo.Title = section.Title
+		c.Sources["title"] = SourceConfig
+	}
 
 	if len(c.APIInfo.Title) == 0 {
 		logger.Errorf(nil, "Error: Specification %s does not have a info.title member.\n", c.URL)
 		os.Exit(1)
 	}
 
+	description := apispec.Info.Description
+	c.Sources["description"] = SourceSpecification
+	if len(section.Description) > 0 {
+		description = section.Description
+		c.Sources["description"] = SourceConfig
+	}
+	c.APIInfo.Description = string(github_flavored_markdown.Markdown([]byte(description)))
+
+	c.APIInfo.Version = apispec.Info.Version
+	c.APIInfo.Logo = ""
+	c.Sources["logo"] = SourceDefault
+	if logo := getLogo(apispec.Info.Extensions["x-logo"]); len(logo) > 0 {
+		c.APIInfo.Logo = logo
+		c.Sources["logo"] = SourceExtension
+	}
+	if len(section.Logo) > 0 {
+		c.APIInfo.Logo = section.Logo
+		c.Sources["logo"] = SourceConfig
+	}
+
 	logger.Tracef(nil, "Parse OpenAPI specification '%s'\n", c.APIInfo.Title)
 
 	c.ID = TitleToKebab(c.APIInfo.Title)
+	c.Sources["id"] = c.Sources["title"]
+	if id, ok := extensionID(apispec.Extensions, "Specification "+c.URL); ok {
+		c.ID = id
+		c.Sources["id"] = SourceExtension
+	}
+	if len(section.ID) > 0 {
+		c.ID = section.ID
+		c.Sources["id"] = SourceConfig
+	}
 
 	c.getSecurityDefinitions(apispec)
 	c.getDefaultSecurity(apispec)
 
 	methodNavByName := false // Should methods in the navigation be presented by type (GET, POST) or name (string)?
+	c.Sources["navigateMethodsByName"] = SourceDefault
 	if byname, ok := apispec.Extensions["x-navigateMethodsByName"].(bool); ok {
 		methodNavByName = byname
+		c.Sources["navigateMethodsByName"] = SourceExtension
+	}
+	if section.NavigateMethodsByName != nil {
+		methodNavByName = *section.NavigateMethodsByName
+		c.Sources["navigateMethodsByName"] = SourceConfig
 	}
 
-	var category string
-	var gotCategory bool
-
-	if category, gotCategory = apispec.Extensions["x-category"].(string); gotCategory {
+	c.Category = ""
+	c.Sources["category"] = SourceDefault
+	if category, ok := apispec.Extensions["x-category"].(string); ok {
 		c.Category = category
-		logger.Infof(nil, "Setting category to %s", category)
-	} else {
-		c.Category = ""
-		logger.Infof(nil, "Setting category to EMPTY")
-
+		c.Sources["category"] = SourceExtension
 	}
+	if len(section.Category) > 0 {
+		c.Category = section.Category
+		c.Sources["category"] = SourceConfig
+	}
+	logger.Infof(nil, "Setting category to '%s' from %s", c.Category, c.Sources["category"])
 
-	var status string
-	var gotStatus bool
-
-	if status, gotStatus = apispec.Extensions["x-status"].(string); gotStatus {
+	c.Status = ""
+	c.Sources["status"] = SourceDefault
+	if status, ok := apispec.Extensions["x-status"].(string); ok {
 		c.Status = status
-		logger.Infof(nil, "Setting status to %s", status)
+		c.Sources["status"] = SourceExtension
 	}
+	if len(section.Status) > 0 {
+		c.Status = section.Status
+		c.Sources["status"] = SourceConfig
+	}
+	logger.Infof(nil, "Setting status to '%s' from %s", c.Status, c.Sources["status"])
 
-
+	c.Visible = true
+	c.Sources["visible"] = SourceDefault
 	if visible, ok := apispec.Extensions["x-visible"].(bool); ok {
 		c.Visible = visible
-	}else {
-		c.Visible = true
+		c.Sources["visible"] = SourceExtension
+	}
+	if section.Visible != nil {
+		c.Visible = *section.Visible
+		c.Sources["visible"] = SourceConfig
 	}
 
+	c.Approved = false
+	c.Sources["approved"] = SourceDefault
 	if approved, ok := apispec.Extensions["x-approved"].(bool); ok {
 		c.Approved = approved
-	}else {
-		c.Approved = false
+		c.Sources["approved"] = SourceExtension
 	}
+	if section.Approved != nil {
+		c.Approved = *section.Approved
+		c.Sources["approved"] = SourceConfig
+	}
+
+	c.Environments = section.Environments
 
 	var methodSortBy []string
+	c.Sources["sortMethodsBy"] = SourceDefault
 	if sortByList, ok := apispec.Extensions["x-sortMethodsBy"].([]interface{}); ok {
 		for _, sortBy := range sortByList {
-			keyname := sortBy.(string)
+			keyname, _ := sortBy.(string)
 			if _, ok := sortTypes[keyname]; !ok {
-				logger.Errorf(nil, "Error: Invalid x-sortBy value %s\n", keyname)
+				logger.Errorf(nil, "Error: Invalid x-sortBy value %v\n", sortBy)
 			} else {
 				methodSortBy = append(methodSortBy, keyname)
 			}
 		}
+		c.Sources["sortMethodsBy"] = SourceExtension
+	}
+	if len(section.SortMethodsBy) > 0 {
+		methodSortBy = section.SortMethodsBy // Validated with the configuration
+		c.Sources["sortMethodsBy"] = SourceConfig
 	}
 
 	//logger.Printf(nil, "DUMP OF ENTIRE SWAGGER SPEC\n")
@@ -427,6 +563,7 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 		var ok bool
 
 		var api *APIGroup
+		var explicitID bool
 		groupingByTag := false
 
 		if tag.Name != "" {
@@ -451,7 +588,13 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 				MethodSortBy:           methodSortBy,
 				Consumes:               apispec.Consumes,
 				Produces:               apispec.Produces,
+				Key:                    "tag:" + tag.Name,
+			}
+			if id, ok := extensionID(tag.Extensions, "Tag "+tag.Name); ok {
+				api.ID = id
+				explicitID = true
 			}
+			api.Status, _ = tag.Extensions["x-status"].(string)
 			var readmes = make([]string, 0)
 			//var gotReadmes bool
 
@@ -467,7 +610,16 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 			}
 		}
 
-		for path, pathItem := range document.Analyzer.AllPaths() {
+		// Visit paths in a stable order, so that any ID disambiguation is deterministic
+		allPaths := document.Analyzer.AllPaths()
+		pathNames := make([]string, 0, len(allPaths))
+		for path := range allPaths {
+			pathNames = append(pathNames, path)
+		}
+		sort.Strings(pathNames)
+
+		for _, path := range pathNames {
+			pathItem := allPaths[path]
 			logger.Tracef(nil, "    In path loop...\n")
 
 			if basePathLen > 0 {
@@ -486,7 +638,9 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 					Consumes:               apispec.Consumes,
 					Produces:               apispec.Produces,
 					Readmes:                make([]string, 0),
+					Key:                    "path:" + path,
 				}
+				api.Status, _ = pathItem.Extensions["x-status"].(string)
 			}
 
 			var ver string
@@ -498,6 +652,15 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 			c.getMethods(tag, api, &api.Methods, &pathItem, path, ver) // Current version
 			//c.getVersions(tag, api, pathItem.Versions, path)           // All versions
 
+			if !groupingByTag {
+				if id, ok := extensionID(pathItem.Extensions, "Path "+path); ok {
+					api.ID = id
+					explicitID = true
+				} else {
+					explicitID = false
+				}
+			}
+
 			api.MainResource.DisplayName = tag.Name
 
 			var messageName string
@@ -520,6 +683,7 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 			// If API was populated (will not be if tags do not match), add to set
 			if !groupingByTag && len(api.Methods) > 0 {
 				logger.Tracef(nil, "    + Adding %s\n", name)
+				c.assignAPIGroupID(api, explicitID)
 				sort.Sort(SortMethods(api.Methods))
 				c.APIs = append(c.APIs, *api) // All APIs (versioned within)
 			}
@@ -527,6 +691,7 @@ func (c *APISpecification) Load(specLocation string, specHost string) error {
 
 		if groupingByTag && len(api.Methods) > 0 {
 			logger.Tracef(nil, "    + Adding %s\n", name)
+			c.assignAPIGroupID(api, explicitID)
 			sort.Sort(SortMethods(api.Methods))
 			c.APIs = append(c.APIs, *api) // All APIs (versioned within)
 		}
@@ -749,8 +914,11 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		operationName = opname
 	}
 
-	// Construct an ID for the Method. Choose from operation ID, x-operationName, summary and lastly method name.
-	id := o.ID // OperationID
+	// Construct an ID for the Method. Choose from x-dapperdox-id, operation ID, x-operationName, summary and lastly method name.
+	id, explicitID := extensionID(o.Extensions, strings.ToUpper(methodname)+" "+path)
+	if !explicitID {
+		id = CamelToKebab(o.ID) // OperationID
+	}
 	if id == "" {
 		// No ID, use x-operationName, if we have it...
 		if gotOpname {
@@ -771,7 +939,7 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 	sortkey := api.getMethodSortKey(path, methodname, operationName, navigationName, o.Summary)
 
 	method := &Method{
-		ID:             CamelToKebab(id),
+		ID:             methodID(api, id, explicitID, path, methodname),
 		Name:           o.Summary,
 		Description:    string(github_flavored_markdown.Markdown([]byte(o.Description))),
 		Method:         methodname,
@@ -779,9 +947,13 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 		Responses:      make(map[int]Response),
 		NavigationName: navigationName,
 		OperationName:  operationName,
+		OperationID:    o.ID,
 		APIGroup:       api,
 		SortKey:        sortkey,
 	}
+	method.Status, _ = o.Extensions["x-status"].(string)
+	method.Deprecation = newDeprecation(o.Deprecated, o.Extensions)
+
 	if len(o.Consumes) > 0 {
 		method.Consumes = o.Consumes
 	} else {
@@ -822,6 +994,7 @@ func (c *APISpecification) processMethod(api *APIGroup, pathItem *spec.PathItem,
 			In:          param.In,
 			Description: string(github_flavored_markdown.Markdown([]byte(param.Description))),
 			Required:    param.Required,
+			Deprecation: newDeprecation(false, param.Extensions),
 		}
 		p.setType(param)
 		p.setEnums(param)
@@ -893,21 +1066,25 @@ func (c *APISpecification) buildResponse(resp *spec.Response, method *Method, ve
 	var response *Response
 
 	if resp != nil {
-		var vres *Resource
+		var vres, body *Resource
 		if resp.Schema != nil {
 			r, example_json := c.resourceFromSchema(resp.Schema, method, nil, false)
 
 			if r != nil {
 				r.Schema = jsonResourceToString(example_json, r.Type[0])
 				r.origin = MethodResponse
+				body = r
 				vres = c.crossLinkMethodAndResource(r, method, version)
 			}
 		}
 		response = &Response{
 			Description: string(github_flavored_markdown.Markdown([]byte(resp.Description))),
 			Resource:    vres,
+			Body:        body,
+		}
+		if vres != nil {
+			method.Resources = append(method.Resources, vres) // Add the resource to the method which uses it
 		}
-		method.Resources = append(method.Resources, response.Resource) // Add the resource to the method which uses it
 
 		response.compileHeaders(resp)
 	}
@@ -923,6 +1100,7 @@ func (c *APISpecification) crossLinkMethodAndResource(resource *Resource, method
 	if _, ok := c.ResourceList[version]; !ok {
 		c.ResourceList[version] = make(map[string]*Resource)
 	}
+	resource.ID = c.resourceID(resource, version)
 
 	// Look for a pre-declared resource with the response ID, and use that or create the first one...
 	var resFound bool
@@ -1033,6 +1211,7 @@ func (r *Response) compileHeaders(sr *spec.Response) {
 		header := &Header{
 			Description: string(github_flavored_markdown.Markdown([]byte(params.Description))),
 			Name:        name,
+			Deprecation: newDeprecation(false, params.Extensions),
 		}
 
 		htype := getType(params)
@@ -1229,6 +1408,11 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	}
 
 	id := TitleToKebab(s.Title)
+	explicitID := false
+	if xid, ok := extensionID(s.Extensions, "Model "+s.Title); ok && len(fqNS) == 0 {
+		id = xid
+		explicitID = true
+	}
 
 	if len(fqNS) == 0 && id == "" {
 		logger.Errorf(nil, "Error: %s %s references a model definition that does not have a title member.", strings.ToUpper(method.Method), method.Path)
@@ -1286,6 +1470,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 		Type:        s.Type,
 		Properties:  make(map[string]*Resource),
 		FQNS:        resourceFQNS,
+		explicitID:  explicitID,
 	}
 
 	if s.Example != nil {
@@ -1303,6 +1488,7 @@ func (c *APISpecification) resourceFromSchema(s *spec.Schema, method *Method, fq
 	}
 
 	r.ReadOnly = original_s.ReadOnly
+	r.Deprecation = newDeprecation(false, original_s.Extensions)
 	if ops, ok := original_s.Extensions["x-excludeFromOperations"].([]interface{}); ok && isRequestResource {
 		// Mark resource property as being excluded from operations with this name.
 		// This filtering only takes effect in a request body, just like readOnly, so when isRequestResource is true
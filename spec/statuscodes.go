@@ -19,9 +19,9 @@ package spec
 
 import (
 	"bufio"
-	"github.com/wix/dapperdox/config"
+	"bytes"
 	"github.com/wix/dapperdox/logger"
-	"os"
+	"github.com/wix/dapperdox/theme"
 	"regexp"
 	"strconv"
 )
@@ -30,55 +30,20 @@ var statusMapSplit = regexp.MustCompile(",")
 var StatusCodes map[int]string
 
 func LoadStatusCodes() {
-	var statusfile string
-
-	cfg, _ := config.Get()
-
-	if len(cfg.AssetsDir) != 0 {
-		statusfile = cfg.AssetsDir + "/status_codes.csv"
-		logger.Tracef(nil, "Looking in assets dir for %s\n", statusfile)
-		if _, err := os.Stat(statusfile); os.IsNotExist(err) {
-			statusfile = ""
-		}
-	}
-	if len(statusfile) == 0 && len(cfg.ThemeDir) != 0 {
-		statusfile = cfg.ThemeDir + "/" + cfg.Theme + "/status_codes.csv"
-		logger.Tracef(nil, "Looking in theme dir for %s\n", statusfile)
-		if _, err := os.Stat(statusfile); os.IsNotExist(err) {
-			statusfile = ""
-		}
-	}
-	if len(statusfile) == 0 {
-		statusfile = cfg.DefaultAssetsDir + "/themes/" + cfg.Theme + "/status_codes.csv"
-		logger.Tracef(nil, "Looking in default theme dir for %s\n", statusfile)
-		if _, err := os.Stat(statusfile); os.IsNotExist(err) {
-			statusfile = ""
-		}
-	}
-	if len(statusfile) == 0 {
-		statusfile = cfg.DefaultAssetsDir + "/themes/default/status_codes.csv"
-		logger.Tracef(nil, "Looking in default theme %s\n", statusfile)
-		if _, err := os.Stat(statusfile); os.IsNotExist(err) {
-			statusfile = ""
-		}
+	buf, statusfile, err := theme.DataFile("status_codes.csv")
+	if err != nil {
+		logger.Errorf(nil, "Error: %s", err)
+		return
 	}
-
 	if len(statusfile) == 0 {
 		logger.Tracef(nil, "No status code map file found.")
 		return
 	}
 	logger.Tracef(nil, "Processing HTTP status code file: %s\n", statusfile)
-	file, err := os.Open(statusfile)
-
-	if err != nil {
-		logger.Errorf(nil, "Error: %s", err)
-		return
-	}
-	defer file.Close()
 
 	StatusCodes = make(map[int]string)
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
 
 	for scanner.Scan() {
 		line := scanner.Text()
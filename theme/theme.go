@@ -0,0 +1,348 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package theme
+
+// A theme is a directory, or a zip archive, of templates and static files layered
+// over those of its parent theme. A theme may describe itself, and name its parent,
+// with a theme.json manifest. Without one, a theme's parent is the default theme.
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+)
+
+const (
+	ManifestName = "theme.json"
+	DefaultName  = "default"
+	NoParent     = "none" // Parent of a theme that is not layered over another
+)
+
+// Manifest describes a theme, from its theme.json.
+type Manifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Parent      string `json:"parent"`
+}
+
+// Theme is a theme of the chain being rendered, with the sources of its files in
+// priority order.
+type Theme struct {
+	Name     string
+	Manifest *Manifest
+	Sources  []*Source
+}
+
+// Source is a directory or zip archive holding a theme's files.
+type Source struct {
+	Path  string
+	files map[string][]byte // Contents of an archive, by slash separated name
+}
+
+var chain []*Theme
+
+// ---------------------------------------------------------------------------
+// Chain returns the configured theme followed by its ancestors, ending with the
+// theme that has no parent. An invalid chain is fatal.
+func Chain() []*Theme {
+	if chain == nil {
+		var err error
+		if chain, err = Load(); err != nil {
+			logger.Errorf(nil, "Error loading theme: %s", err)
+			os.Exit(1)
+		}
+	}
+	return chain
+}
+
+// ---------------------------------------------------------------------------
+// Load finds the configured theme and each of its ancestors.
+func Load() ([]*Theme, error) {
+	cfg, _ := config.Get()
+
+	name := cfg.Theme
+	if len(name) == 0 {
+		name = DefaultName
+	}
+
+	var themes []*Theme
+	seen := make(map[string]bool)
+	for len(name) > 0 && name != NoParent {
+		if seen[name] {
+			return nil, fmt.Errorf("theme '%s' is its own ancestor", name)
+		}
+		seen[name] = true
+
+		t, err := Find(name)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debugf(nil, "- Theme '%s' from %s", t.Name, t.Sources[0].Path)
+		themes = append(themes, t)
+		name = t.Parent()
+	}
+	return themes, nil
+}
+
+// ---------------------------------------------------------------------------
+// Find locates a theme by name, in the themes directory of assets-dir, theme-dir,
+// then the themes directory of default-assets-dir. A theme found in more than one
+// has the files of the first take precedence.
+func Find(name string) (*Theme, error) {
+	if strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		return nil, fmt.Errorf("'%s' is not a valid theme name", name)
+	}
+
+	t := &Theme{Name: name}
+	for _, dir := range directories() {
+		base := filepath.Join(dir, name)
+		if info, err := os.Stat(base); err == nil && info.IsDir() {
+			t.Sources = append(t.Sources, &Source{Path: base})
+		} else if _, err := os.Stat(base + ".zip"); err == nil {
+			source, err := openArchive(base+".zip", name)
+			if err != nil {
+				return nil, err
+			}
+			t.Sources = append(t.Sources, source)
+		}
+	}
+	if len(t.Sources) == 0 {
+		return nil, fmt.Errorf("theme '%s' not found in %s", name, strings.Join(directories(), ", "))
+	}
+
+	for _, source := range t.Sources {
+		buf, err := source.ReadFile(ManifestName)
+		if err != nil {
+			continue
+		}
+		t.Manifest = &Manifest{}
+		if err = json.Unmarshal(buf, t.Manifest); err != nil {
+			return nil, fmt.Errorf("invalid %s of theme '%s' in %s: %s", ManifestName, name, source.Path, err)
+		}
+		if len(t.Manifest.Name) > 0 && t.Manifest.Name != name {
+			return nil, fmt.Errorf("%s in %s names theme '%s', not '%s'", ManifestName, source.Path, t.Manifest.Name, name)
+		}
+		break
+	}
+	return t, nil
+}
+
+// ---------------------------------------------------------------------------
+// directories returns the directories searched for themes, in priority order.
+func directories() []string {
+	cfg, _ := config.Get()
+
+	var dirs []string
+	if len(cfg.AssetsDir) != 0 {
+		dirs = append(dirs, filepath.Join(cfg.AssetsDir, "themes"))
+	}
+	if len(cfg.ThemeDir) != 0 {
+		dirs = append(dirs, cfg.ThemeDir)
+	}
+	return append(dirs, filepath.Join(cfg.DefaultAssetsDir, "themes"))
+}
+
+// ---------------------------------------------------------------------------
+// Parent returns the name of the theme this one is layered over, or "" if none.
+func (t *Theme) Parent() string {
+	if t.Manifest != nil && len(t.Manifest.Parent) > 0 {
+		if t.Manifest.Parent == NoParent {
+			return ""
+		}
+		return t.Manifest.Parent
+	}
+	if t.Name == DefaultName {
+		return ""
+	}
+	return DefaultName
+}
+
+// ---------------------------------------------------------------------------
+// Files returns the slash separated names of the files of all the theme's sources,
+// sorted.
+func (t *Theme) Files() []string {
+	found := make(map[string]bool)
+	var files []string
+	for _, source := range t.Sources {
+		for _, name := range source.Files() {
+			if !found[name] {
+				found[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ---------------------------------------------------------------------------
+// ReadFile returns the content of a theme file, from the first source that has it.
+func (t *Theme) ReadFile(name string) ([]byte, *Source, error) {
+	for _, source := range t.Sources {
+		if buf, err := source.ReadFile(name); err == nil {
+			return buf, source, nil
+		}
+	}
+	return nil, nil, os.ErrNotExist
+}
+
+// ---------------------------------------------------------------------------
+// openArchive reads a zip archive theme. Its files may either be at the top level
+// of the archive, or all within a directory named after the theme.
+func openArchive(filename string, name string) (*Source, error) {
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening theme archive %s: %s", filename, err)
+	}
+	defer archive.Close()
+
+	root := name + "/"
+	for _, f := range archive.File {
+		if !strings.HasPrefix(f.Name, root) {
+			root = ""
+			break
+		}
+	}
+
+	source := &Source{Path: filename, files: make(map[string][]byte)}
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, root) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from theme archive %s: %s", f.Name, filename, err)
+		}
+		buf, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from theme archive %s: %s", f.Name, filename, err)
+		}
+		source.files[path.Clean(strings.TrimPrefix(f.Name, root))] = buf
+	}
+	return source, nil
+}
+
+// ---------------------------------------------------------------------------
+// IsArchive returns whether the source is a zip archive, rather than a directory.
+func (s *Source) IsArchive() bool {
+	return s.files != nil
+}
+
+// ---------------------------------------------------------------------------
+// Files returns the slash separated names of the source's files, sorted. Hidden
+// files and directories are skipped.
+func (s *Source) Files() []string {
+	var files []string
+	if s.IsArchive() {
+		for name := range s.files {
+			if !hidden(name) {
+				files = append(files, name)
+			}
+		}
+	} else {
+		filepath.Walk(s.Path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info == nil {
+				return nil
+			}
+			relative, _ := filepath.Rel(s.Path, p)
+			relative = filepath.ToSlash(relative)
+			if relative != "." && hidden(relative) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				files = append(files, relative)
+			}
+			return nil
+		})
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ---------------------------------------------------------------------------
+// ReadFile returns the content of a file of the source, by slash separated name.
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	if s.IsArchive() {
+		if buf, ok := s.files[path.Clean(name)]; ok {
+			return buf, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	return ioutil.ReadFile(filepath.Join(s.Path, filepath.FromSlash(name)))
+}
+
+// ---------------------------------------------------------------------------
+// Location describes where a file of the source is, for messages.
+func (s *Source) Location(name string) string {
+	if s.IsArchive() {
+		return s.Path + ":" + name
+	}
+	return filepath.Join(s.Path, filepath.FromSlash(name))
+}
+
+// ---------------------------------------------------------------------------
+// DataFile returns the content of a data file, such as gfm.map, and where it was
+// found, from assets-dir or else the nearest theme of the chain that has it. The
+// location is "" if no theme has it.
+func DataFile(name string) ([]byte, string, error) {
+	cfg, _ := config.Get()
+
+	if len(cfg.AssetsDir) != 0 {
+		filename := filepath.Join(cfg.AssetsDir, name)
+		logger.Tracef(nil, "Looking in assets dir for %s\n", filename)
+		if buf, err := ioutil.ReadFile(filename); err == nil {
+			return buf, filename, nil
+		} else if !os.IsNotExist(err) {
+			return nil, filename, err
+		}
+	}
+	for _, t := range Chain() {
+		logger.Tracef(nil, "Looking in theme '%s' for %s\n", t.Name, name)
+		if buf, source, err := t.ReadFile(name); err == nil {
+			return buf, source.Location(name), nil
+		}
+	}
+	return nil, "", nil
+}
+
+// ---------------------------------------------------------------------------
+func hidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package themecheck
+
+// The theme check compiles the theme chain, as for serving, and reports templates of
+// a theme that override no template of the themes it is layered over, and templates
+// that include a template that does not exist.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wix/dapperdox/config"
+	"github.com/wix/dapperdox/logger"
+	"github.com/wix/dapperdox/render/asset"
+	"github.com/wix/dapperdox/theme"
+)
+
+// Problem is a template found wanting by the check.
+type Problem struct {
+	Template string // Template name
+	Location string // File the template was compiled from
+	Reason   string
+}
+
+const templatePrefix = "assets/templates/"
+
+var templateRegex = regexp.MustCompile(`\[:-?\s*template\s+"([^"]+)"`)
+
+// ---------------------------------------------------------------------------
+// Check returns the problems found with the templates of the theme chain. The
+// templates must already have been compiled (see render.Register).
+func Check() []Problem {
+	chain := theme.Chain()
+
+	// Includes of missing templates, noting every template that is included
+	used := make(map[string]bool)
+	var problems []Problem
+	for _, name := range templateNames() {
+		buf, _ := asset.Asset(templatePrefix + name + ".tmpl")
+		for _, m := range templateRegex.FindAllStringSubmatch(string(buf), -1) {
+			used[m[1]] = true
+			if _, err := asset.Asset(templatePrefix + m[1] + ".tmpl"); err != nil {
+				problems = append(problems, Problem{
+					Template: name,
+					Location: compiledFrom(chain, name),
+					Reason:   fmt.Sprintf("includes template '%s', which does not exist", m[1]),
+				})
+			}
+		}
+	}
+
+	// The templates of a theme should override those of the themes beneath it, or be
+	// new templates that are included by others.
+	cfg, _ := config.Get()
+	known := make(map[string]bool)
+	for _, name := range templateFiles(&theme.Source{Path: cfg.DefaultAssetsDir}) {
+		known[name] = true
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		var names []string
+		for _, source := range chain[i].Sources {
+			names = append(names, templateFiles(source)...)
+		}
+		for _, name := range names {
+			if i < len(chain)-1 && !known[name] && !used[name] {
+				problems = append(problems, Problem{
+					Template: name,
+					Location: location(chain[i:i+1], name),
+					Reason:   fmt.Sprintf("overrides no template of theme '%s', and is not included by any", chain[i+1].Name),
+				})
+			}
+		}
+		for _, name := range names {
+			known[name] = true
+		}
+	}
+	return problems
+}
+
+// ---------------------------------------------------------------------------
+// Run checks the theme chain and prints the problems found, returning the exit
+// status of the check.
+func Run() int {
+	logger.Infof(nil, "Checking theme")
+
+	problems := Check()
+
+	for _, p := range problems {
+		fmt.Printf("%s (%s)\n    %s\n", p.Template, p.Location, p.Reason)
+	}
+	fmt.Printf("%d theme problems found\n", len(problems))
+
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// ---------------------------------------------------------------------------
+// templateNames returns the names of the compiled templates, sorted.
+func templateNames() []string {
+	var names []string
+	for _, name := range asset.AssetNames() {
+		if strings.HasPrefix(name, templatePrefix) && strings.HasSuffix(name, ".tmpl") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(name, templatePrefix), ".tmpl"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// templateFiles returns the names of the templates a theme source, or assets
+// directory, provides.
+func templateFiles(source *theme.Source) []string {
+	var names []string
+	for _, file := range source.Files() {
+		if strings.HasPrefix(file, "templates/") && strings.HasSuffix(file, ".tmpl") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(file, "templates/"), ".tmpl"))
+		}
+	}
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// compiledFrom returns the file a template is compiled from: that of assets-dir, or
+// else of the first theme of the chain to have it. Templates compiled from
+// elsewhere, such as from guides or the default assets, are described as such.
+func compiledFrom(chain []*theme.Theme, name string) string {
+	cfg, _ := config.Get()
+	if len(cfg.AssetsDir) != 0 {
+		filename := filepath.Join(cfg.AssetsDir, "templates", filepath.FromSlash(name)+".tmpl")
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	if file := location(chain, name); len(file) > 0 {
+		return file
+	}
+	return "assets"
+}
+
+// ---------------------------------------------------------------------------
+// location returns the file of a template of the first of the themes to have it,
+// or "" if none do.
+func location(themes []*theme.Theme, name string) string {
+	file := "templates/" + name + ".tmpl"
+	for _, t := range themes {
+		for _, source := range t.Sources {
+			if _, err := source.ReadFile(file); err == nil {
+				return source.Location(file)
+			}
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,467 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package validate
+
+// Validation checks requests, and the values in them, against the operations of the
+// loaded specifications that they are for.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wix/dapperdox/spec"
+)
+
+// Problem is a way in which a request, or value, departs from its documentation.
+type Problem struct {
+	Location string `json:"location"` // Such as query.limit or body.pets[0].name
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Location + ": " + p.Message
+}
+
+var (
+	templates = make(map[string]*regexp.Regexp) // Path template->Regular expression matching it
+	mutex     sync.RWMutex
+
+	paramRegex = regexp.MustCompile(`\{([^}/]+)\}`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// ---------------------------------------------------------------------------
+// Match returns the operation of a specification whose verb and path template match
+// a request, and the values of its path parameters, or nil if none does. Where
+// several templates match, that with the fewest parameters wins, so that /pets/mine
+// is preferred to /pets/{id}.
+func Match(specification *spec.APISpecification, verb string, path string) (*spec.Method, map[string]string) {
+	var match *spec.Method
+	var values []string
+	fewest := -1
+
+	for a := range specification.APIs {
+		api := &specification.APIs[a]
+		for m := range api.Methods {
+			method := &api.Methods[m]
+			if !strings.EqualFold(method.Method, verb) {
+				continue
+			}
+			v := templateRegex(method.Path).FindStringSubmatch(path)
+			if v == nil {
+				continue
+			}
+			if fewest < 0 || len(v) < fewest {
+				match, values, fewest = method, v[1:], len(v)
+			}
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	params := make(map[string]string)
+	for i, name := range paramRegex.FindAllStringSubmatch(match.Path, -1) {
+		params[name[1]] = values[i]
+	}
+	return match, params
+}
+
+func templateRegex(template string) *regexp.Regexp {
+	mutex.RLock()
+	re, ok := templates[template]
+	mutex.RUnlock()
+	if ok {
+		return re
+	}
+
+	var expr bytes.Buffer
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range paramRegex.FindAllStringIndex(template, -1) {
+		expr.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		expr.WriteString("([^/]+)")
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(template[last:]))
+	expr.WriteString("/?$")
+
+	re = regexp.MustCompile(expr.String())
+	mutex.Lock()
+	templates[template] = re
+	mutex.Unlock()
+	return re
+}
+
+// ---------------------------------------------------------------------------
+// Request checks a request against its operation: the presence, type and enumerated
+// values of its parameters, and its body against the body's schema. The body is read
+// and replaced, so that the request may still be forwarded.
+func Request(method *spec.Method, req *http.Request, pathParams map[string]string) []Problem {
+	var problems []Problem
+
+	for _, p := range method.PathParams {
+		problems = append(problems, parameter(p, "path", valuesOf(pathParams[p.Name]))...)
+	}
+	query := req.URL.Query()
+	for _, p := range method.QueryParams {
+		problems = append(problems, parameter(p, "query", query[p.Name])...)
+	}
+	for _, p := range method.HeaderParams {
+		problems = append(problems, parameter(p, "header", req.Header[http.CanonicalHeaderKey(p.Name)])...)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if len(method.FormParams) > 0 {
+		form, files := formValues(req, body)
+		for _, p := range method.FormParams {
+			if len(p.Type) > 0 && p.Type[len(p.Type)-1] == "file" {
+				if p.Required && !files[p.Name] {
+					problems = append(problems, Problem{"form." + p.Name, "is required"})
+				}
+				continue
+			}
+			problems = append(problems, parameter(p, "form", form[p.Name])...)
+		}
+	}
+
+	if p := method.BodyParam; p != nil {
+		switch {
+		case len(bytes.TrimSpace(body)) == 0:
+			if p.Required {
+				problems = append(problems, Problem{"body", "is required"})
+			}
+		case isJSON(req.Header.Get("Content-Type")):
+			var value interface{}
+			if err := json.Unmarshal(body, &value); err != nil {
+				problems = append(problems, Problem{"body", fmt.Sprintf("is not valid JSON: %s", err)})
+			} else if p.Resource != nil {
+				problems = append(problems, Value(p.Resource, value, "body")...)
+			}
+		}
+	}
+	return problems
+}
+
+func valuesOf(value string) []string {
+	if len(value) == 0 {
+		return nil
+	}
+	return []string{value}
+}
+
+// formValues parses a copy of a request's form, returning its values and the names
+// of its files.
+func formValues(req *http.Request, body []byte) (map[string][]string, map[string]bool) {
+	r := req.Clone(req.Context())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		return nil, nil
+	}
+	files := make(map[string]bool)
+	if r.MultipartForm != nil {
+		for name := range r.MultipartForm.File {
+			files[name] = true
+		}
+		r.MultipartForm.RemoveAll()
+	}
+	return r.PostForm, files
+}
+
+// ---------------------------------------------------------------------------
+// Response checks a response to an operation: that its status is documented, the
+// types of its documented headers, and its body against the response's resource.
+func Response(method *spec.Method, status int, header http.Header, body []byte) []Problem {
+	response, ok := method.Responses[status]
+	if !ok {
+		if method.DefaultResponse == nil {
+			return []Problem{{"status", fmt.Sprintf("%d is not documented", status)}}
+		}
+		response = *method.DefaultResponse
+	}
+
+	var problems []Problem
+	for _, h := range response.Headers {
+		location := "header." + h.Name
+		value := header.Get(h.Name)
+		switch {
+		case len(value) == 0:
+			if h.Required {
+				problems = append(problems, Problem{location, "is required"})
+			}
+		case len(h.Type) > 0:
+			problems = append(problems, scalar(h.Type[len(h.Type)-1], h.Enum, value, location)...)
+		}
+	}
+
+	if response.Body == nil || status == http.StatusNoContent {
+		return problems
+	}
+	switch {
+	case len(bytes.TrimSpace(body)) == 0:
+		problems = append(problems, Problem{"body", "is missing"})
+	case isJSON(header.Get("Content-Type")):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			problems = append(problems, Problem{"body", fmt.Sprintf("is not valid JSON: %s", err)})
+		} else {
+			problems = append(problems, Value(response.Body, value, "body")...)
+		}
+	}
+	return problems
+}
+
+// ---------------------------------------------------------------------------
+// parameter checks the values given for a parameter, splitting those of an array by
+// its collection format.
+func parameter(p spec.Parameter, in string, values []string) []Problem {
+	location := in + "." + p.Name
+	if len(values) == 0 {
+		if p.Required {
+			return []Problem{{location, "is required"}}
+		}
+		return nil
+	}
+	if len(p.Type) == 0 {
+		return nil
+	}
+
+	if p.Type[0] == "array" {
+		var items []string
+		if p.CollectionFormat == "multi" {
+			items = values
+		} else {
+			separator := map[string]string{"ssv": " ", "tsv": "\t", "pipes": "|"}[p.CollectionFormat]
+			if len(separator) == 0 {
+				separator = ","
+			}
+			items = strings.Split(values[0], separator)
+		}
+		var problems []Problem
+		for i, item := range items {
+			problems = append(problems, scalar(p.Type[len(p.Type)-1], p.Enum, item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+		return problems
+	}
+	return scalar(p.Type[0], p.Enum, values[0], location)
+}
+
+// scalar checks the string form of a primitive value, as given in a path, query,
+// header or form.
+func scalar(t string, enum []string, value string, location string) []Problem {
+	var err error
+	switch BaseType(t) {
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		if value != "true" && value != "false" {
+			err = fmt.Errorf("not true or false")
+		}
+	default:
+		err = format(t, value)
+	}
+	if err != nil {
+		return []Problem{{location, fmt.Sprintf("'%s' is not a valid %s", value, t)}}
+	}
+	return checkEnum(enum, value, location)
+}
+
+func checkEnum(enum []string, value string, location string) []Problem {
+	if len(enum) == 0 {
+		return nil
+	}
+	for _, e := range enum {
+		if e == value {
+			return nil
+		}
+	}
+	return []Problem{{location, fmt.Sprintf("'%s' is not one of %s", value, strings.Join(enum, ", "))}}
+}
+
+// ---------------------------------------------------------------------------
+// Value checks a decoded JSON value against a resource: its type, the presence of
+// required properties, and enumerated values. Properties not documented are allowed.
+func Value(resource *spec.Resource, value interface{}, location string) []Problem {
+	if resource == nil || len(resource.Type) == 0 {
+		return nil
+	}
+
+	switch resource.Type[0] {
+	case "object":
+		return object(resource, value, location)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return []Problem{{location, "is not an array"}}
+		}
+		var problems []Problem
+		for i, item := range list {
+			problems = append(problems, element(resource, item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+		return problems
+	case "map":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return []Problem{{location, "is not an object"}}
+		}
+		var problems []Problem
+		for key, item := range m {
+			problems = append(problems, element(resource, item, location+"."+key)...)
+		}
+		return problems
+	}
+	return primitive(resource.Type[0], resource.Enum, value, location)
+}
+
+// element checks a member of an array or map resource, whose type is the second of
+// the resource's types, or else an object of the resource's properties.
+func element(resource *spec.Resource, value interface{}, location string) []Problem {
+	if len(resource.Type) > 1 && resource.Type[1] != "object" {
+		return primitive(resource.Type[1], resource.Enum, value, location)
+	}
+	return object(resource, value, location)
+}
+
+func object(resource *spec.Resource, value interface{}, location string) []Problem {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return []Problem{{location, "is not an object"}}
+	}
+	var problems []Problem
+	for name, property := range resource.Properties {
+		if name == "<key>" {
+			// Additional properties, the values of a map
+			for key, item := range m {
+				if _, documented := resource.Properties[key]; !documented {
+					problems = append(problems, Value(property, item, location+"."+key)...)
+				}
+			}
+			continue
+		}
+		item, present := m[name]
+		switch {
+		case !present || item == nil:
+			if property.Required {
+				problems = append(problems, Problem{location + "." + name, "is required"})
+			}
+		default:
+			problems = append(problems, Value(property, item, location+"."+name)...)
+		}
+	}
+	return problems
+}
+
+// primitive checks a decoded JSON value of a primitive type.
+func primitive(t string, enum []string, value interface{}, location string) []Problem {
+	var ok bool
+	var s string
+	switch BaseType(t) {
+	case "integer":
+		var n float64
+		n, ok = value.(float64)
+		ok = ok && n == float64(int64(n))
+		s = fmt.Sprintf("%v", value)
+	case "number":
+		_, ok = value.(float64)
+		s = fmt.Sprintf("%v", value)
+	case "boolean":
+		_, ok = value.(bool)
+		s = fmt.Sprintf("%v", value)
+	default:
+		s, ok = value.(string)
+		if ok && format(t, s) != nil {
+			return []Problem{{location, fmt.Sprintf("'%s' is not a valid %s", s, t)}}
+		}
+	}
+	if !ok {
+		return []Problem{{location, fmt.Sprintf("%s is not a %s", describe(value), t)}}
+	}
+	return checkEnum(enum, s, location)
+}
+
+func describe(value interface{}) string {
+	buf, _ := json.Marshal(value)
+	if len(buf) > 40 {
+		return string(buf[:37]) + "..."
+	}
+	return string(buf)
+}
+
+// ---------------------------------------------------------------------------
+// BaseType returns the JSON type of a resource or parameter type, which is its
+// format, if it has one, such as int64 or date-time.
+func BaseType(t string) string {
+	switch t {
+	case "integer", "int32", "int64":
+		return "integer"
+	case "number", "float", "double":
+		return "number"
+	case "boolean", "object", "array", "map", "file":
+		return t
+	}
+	return "string"
+}
+
+// format checks a string against those of its formats that are well defined.
+func format(t string, s string) error {
+	var err error
+	switch t {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(s)
+	case "uuid":
+		if !uuidRegex.MatchString(s) {
+			err = fmt.Errorf("not a UUID")
+		}
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// isJSON returns true if a content type is JSON, or not given.
+func isJSON(contentType string) bool {
+	if len(contentType) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// ---------------------------------------------------------------------------
+// end
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2016-2017 dapperdox.com 
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package validate
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wix/dapperdox/spec"
+)
+
+func TestValue(t *testing.T) {
+	pet := &spec.Resource{
+		Type: []string{"object"},
+		Properties: map[string]*spec.Resource{
+			"name":   {Type: []string{"string"}, Required: true},
+			"age":    {Type: []string{"integer"}},
+			"status": {Type: []string{"string"}, Enum: []string{"available", "sold"}},
+			"born":   {Type: []string{"date"}},
+		},
+	}
+	tags := &spec.Resource{Type: []string{"array", "string"}}
+
+	tests := []struct {
+		name     string
+		resource *spec.Resource
+		value    interface{}
+		problems []Problem
+	}{
+		{"valid object", pet, map[string]interface{}{"name": "Rex", "age": 3.0, "status": "sold"}, nil},
+		{"undocumented property", pet, map[string]interface{}{"name": "Rex", "colour": "brown"}, nil},
+		{"missing required", pet, map[string]interface{}{"age": 3.0}, []Problem{{"body.name", "is required"}}},
+		{"null required", pet, map[string]interface{}{"name": nil}, []Problem{{"body.name", "is required"}}},
+		{"not an object", pet, "Rex", []Problem{{"body", "is not an object"}}},
+		{"fractional integer", pet, map[string]interface{}{"name": "Rex", "age": 3.5}, []Problem{{"body.age", "3.5 is not a integer"}}},
+		{"not in enum", pet, map[string]interface{}{"name": "Rex", "status": "lost"}, []Problem{{"body.status", "'lost' is not one of available, sold"}}},
+		{"bad format", pet, map[string]interface{}{"name": "Rex", "born": "yesterday"}, []Problem{{"body.born", "'yesterday' is not a valid date"}}},
+		{"array", tags, []interface{}{"a", 1.0}, []Problem{{"body[1]", "1 is not a string"}}},
+		{"not an array", tags, "a", []Problem{{"body", "is not an array"}}},
+		{"no resource", nil, 1.0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problems := Value(test.resource, test.value, "body")
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("got %v, want %v", problems, test.problems)
+			}
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	method := &spec.Method{
+		PathParams:   []spec.Parameter{{Name: "id", Required: true, Type: []string{"integer"}}},
+		QueryParams:  []spec.Parameter{{Name: "tags", Type: []string{"array", "string"}, CollectionFormat: "csv", Enum: []string{"a", "b"}}},
+		HeaderParams: []spec.Parameter{{Name: "X-Trace", Required: true, Type: []string{"string"}}},
+		BodyParam: &spec.Parameter{Name: "body", Required: true, Resource: &spec.Resource{
+			Type:       []string{"object"},
+			Properties: map[string]*spec.Resource{"name": {Type: []string{"string"}, Required: true}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		path     map[string]string
+		query    url.Values
+		header   http.Header
+		body     string
+		problems []Problem
+	}{
+		{"valid", map[string]string{"id": "1"}, url.Values{"tags": {"a,b"}}, http.Header{"X-Trace": {"t"}}, `{"name":"Rex"}`, nil},
+		{"bad path", map[string]string{"id": "x"}, nil, http.Header{"X-Trace": {"t"}}, `{"name":"Rex"}`, []Problem{{"path.id", "'x' is not a valid integer"}}},
+		{"bad query", map[string]string{"id": "1"}, url.Values{"tags": {"a,c"}}, http.Header{"X-Trace": {"t"}}, `{"name":"Rex"}`, []Problem{{"query.tags[1]", "'c' is not one of a, b"}}},
+		{"missing header", map[string]string{"id": "1"}, nil, nil, `{"name":"Rex"}`, []Problem{{"header.X-Trace", "is required"}}},
+		{"missing body", map[string]string{"id": "1"}, nil, http.Header{"X-Trace": {"t"}}, "", []Problem{{"body", "is required"}}},
+		{"invalid body", map[string]string{"id": "1"}, nil, http.Header{"X-Trace": {"t"}}, `{}`, []Problem{{"body.name", "is required"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/pets/1?"+test.query.Encode(), strings.NewReader(test.body))
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+			problems := Request(method, req, test.path)
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("got %v, want %v", problems, test.problems)
+			}
+		})
+	}
+}